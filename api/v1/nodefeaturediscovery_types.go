@@ -18,8 +18,12 @@ package v1
 
 import (
 	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkq/config"
 )
 
 // NodeFeatureDiscoverySpec defines the desired state of NodeFeatureDiscovery
@@ -28,7 +32,11 @@ type NodeFeatureDiscoverySpec struct {
 	Operand OperandSpec `json:"operand"`
 
 	// Instance name. Used to separate annotation namespaces for
-	// multiple parallel deployments.
+	// multiple parallel deployments. The names of the operand
+	// resources are suffixed with it so that the deployments don't
+	// collide.
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?)?$`
+	// +kubebuilder:validation:MaxLength=32
 	// +optional
 	Instance string `json:"instance"`
 
@@ -36,8 +44,193 @@ type NodeFeatureDiscoverySpec struct {
 	// worker.
 	// +optional
 	WorkerConfig ConfigMap `json:"workerConfig"`
+
+	// LabelWhiteList is a regular expression used by nfd-master to
+	// filter the feature labels it publishes. Labels not matching
+	// it are dropped.
+	// https://kubernetes-sigs.github.io/node-feature-discovery/v0.8/advanced/master-commandline-reference.html#-label-whitelist
+	// +optional
+	LabelWhiteList string `json:"labelWhiteList,omitempty"`
+
+	// MasterConfig describes the configuration file of nfd-master
+	// +optional
+	MasterConfig MasterConfig `json:"masterConfig,omitempty"`
+
+	// ExtraLabelNs is the list of label namespaces, in addition to the
+	// default feature.node.kubernetes.io, nfd-master is allowed to
+	// publish labels in. When the namespace of the NodeFeatureDiscovery
+	// object carries the nfd.kubernetes.io/allowed-label-ns annotation,
+	// only the label namespaces it lists may be used.
+	// https://kubernetes-sigs.github.io/node-feature-discovery/v0.8/advanced/master-commandline-reference.html#-extra-label-ns
+	// +optional
+	ExtraLabelNs []string `json:"extraLabelNs,omitempty"`
+
+	// DenyLabelNs is the list of label namespaces nfd-master refuses
+	// to publish labels in, e.g. to keep third party hooks from
+	// labelling nodes under arbitrary namespaces. Wildcards, like
+	// "*.example.com", are supported.
+	// https://kubernetes-sigs.github.io/node-feature-discovery/v0.10/advanced/master-commandline-reference.html#-deny-label-ns
+	// +optional
+	DenyLabelNs []string `json:"denyLabelNs,omitempty"`
+
+	// ResourceLabels is the list of feature labels nfd-master
+	// advertises as extended resources instead of labels, e.g.
+	// "vendor.io/feature-1"
+	// https://kubernetes-sigs.github.io/node-feature-discovery/v0.8/advanced/master-commandline-reference.html#-resource-labels
+	// +optional
+	ResourceLabels []string `json:"resourceLabels,omitempty"`
+
+	// FeatureGates enables or disables NFD features, e.g.
+	// "NodeFeatureAPI". They are passed to both nfd-master and
+	// nfd-worker.
+	// +optional
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+
+	// EnableTaints lets nfd-master taint the nodes according to the
+	// NodeFeatureRules. When turned off, the taints previously set by
+	// nfd-master are removed from the nodes. [defaults to false]
+	// +optional
+	EnableTaints bool `json:"enableTaints,omitempty"`
+
+	// EnableNodeFeatureAPI makes nfd-worker publish the features it
+	// discovers in NodeFeature objects, which nfd-master watches,
+	// instead of sending them to nfd-master over gRPC. The NodeFeature
+	// and NodeFeatureRule CRDs are installed by the operator, and the
+	// nfd-master Service is removed unless nfd-topology-updater, which
+	// still uses gRPC, is enabled. [defaults to false]
+	// https://kubernetes-sigs.github.io/node-feature-discovery/v0.12/usage/customization-guide.html#nodefeature-custom-resource
+	// +optional
+	EnableNodeFeatureAPI bool `json:"enableNodeFeatureApi,omitempty"`
+
+	// ManageNamespace lets the operator create and modify the namespace
+	// of the operands. When false, the namespace must already exist and
+	// is left untouched, e.g. its node selectors aren't cleared.
+	// [defaults to true]
+	// +optional
+	ManageNamespace *bool `json:"manageNamespace,omitempty"`
+
+	// Master describes scheduling and runtime options for the
+	// nfd-master pods.
+	// +optional
+	Master MasterSpec `json:"master,omitempty"`
+
+	// Worker describes scheduling and runtime options for the
+	// nfd-worker DaemonSet.
+	// +optional
+	Worker WorkerSpec `json:"worker,omitempty"`
+
+	// TopologyUpdater describes the nfd-topology-updater DaemonSet,
+	// which publishes the NodeResourceTopology objects of the nodes.
+	// +optional
+	TopologyUpdater TopologyUpdaterSpec `json:"topologyUpdater,omitempty"`
+
+	// GC describes the nfd-gc Deployment, which deletes the
+	// NodeFeature and NodeResourceTopology objects of the nodes that
+	// are gone.
+	// +optional
+	GC GCSpec `json:"gc,omitempty"`
+
+	// Publishing configures where the discovered features are
+	// published.
+	// +optional
+	Publishing PublishingSpec `json:"publishing,omitempty"`
+
+	// TLS configures the mutual TLS authentication of the gRPC
+	// connections between nfd-master and its clients.
+	// +optional
+	TLS TLSSpec `json:"tls,omitempty"`
+
+	// Telemetry configures the opt-in reporting of anonymized,
+	// aggregate usage data.
+	// +optional
+	Telemetry TelemetrySpec `json:"telemetry,omitempty"`
+
+	// Cleanup configures how the NFD labels are removed from the
+	// nodes when the NodeFeatureDiscovery object is deleted.
+	// +optional
+	Cleanup CleanupSpec `json:"cleanup,omitempty"`
+
+	// Upgrade configures how operand upgrades are rolled out.
+	// +optional
+	Upgrade UpgradeSpec `json:"upgrade,omitempty"`
+
+	// IntegrityCheck configures the periodic verification of the
+	// labels of the nodes against the ones nfd-master published.
+	// +optional
+	IntegrityCheck IntegrityCheckSpec `json:"integrityCheck,omitempty"`
+
+	// Verification configures the check of a rollout on a node, before
+	// the operands are reported available.
+	// +optional
+	Verification VerificationSpec `json:"verification,omitempty"`
+
+	// Notifications lists the webhooks notified of the condition
+	// transitions and of the end of the cleanup.
+	// +optional
+	Notifications []NotificationSink `json:"notifications,omitempty"`
+
+	// Paused stops the operator from applying the operand resources,
+	// e.g. to patch them by hand during an incident, until it's turned
+	// off again. The deletion of the object is still handled.
+	// [defaults to false]
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// ManagementState tells whether the operator manages the operands:
+	// Managed reconciles them, Unmanaged leaves them as they are and
+	// Removed deletes them, while keeping the NodeFeatureDiscovery
+	// object and the node labels. [defaults to Managed]
+	// +kubebuilder:validation:Enum=Managed;Unmanaged;Removed
+	// +optional
+	ManagementState ManagementState `json:"managementState,omitempty"`
+
+	// AdoptExisting lets the operator take over the nfd-master,
+	// nfd-worker, nfd-topology-updater and nfd-gc workloads deployed in
+	// the namespace from the NFD manifests or Helm chart, instead of
+	// running duplicates next to them. The workloads with the names the
+	// operator uses are adopted, the other ones are deleted once the
+	// operator's are available. [defaults to false]
+	// +optional
+	AdoptExisting bool `json:"adoptExisting,omitempty"`
+
+	// DeletionPolicy tells what happens to the operands when the
+	// NodeFeatureDiscovery object is deleted: Delete removes them along
+	// with the node labels, Retain orphans them, leaving NFD running and
+	// the nodes labelled. [defaults to Delete]
+	// +kubebuilder:validation:Enum=Delete;Retain
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
 }
 
+// ManagementState tells whether, and how, the operator manages the
+// operands
+type ManagementState string
+
+const (
+	// Managed has the operator reconcile the operands
+	Managed ManagementState = "Managed"
+
+	// Unmanaged has the operator leave the operands as they are
+	Unmanaged ManagementState = "Unmanaged"
+
+	// Removed has the operator delete the operands
+	Removed ManagementState = "Removed"
+)
+
+// DeletionPolicy tells what happens to the operands when the
+// NodeFeatureDiscovery object is deleted
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete has the operator delete the operands and
+	// remove the NFD labels from the nodes
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+
+	// DeletionPolicyRetain has the operator orphan the operands, so that
+	// they outlive the NodeFeatureDiscovery object
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+)
+
 // OperandSpec describes configuration options for the operand
 type OperandSpec struct {
 	// Namespace defines the namespace to deploy nfd-master
@@ -47,25 +240,615 @@ type OperandSpec struct {
 
 	// Image defines the image to pull for the
 	// NFD operand
-	// [defaults to k8s.gcr.io/nfd/node-feature-discovery]
+	// [defaults to the NODE_FEATURE_DISCOVERY_IMAGE of the operator
+	// running, see status.operandImage]
 	// +kubebuilder:validation:Pattern=[a-zA-Z0-9\-]+
 	Image string `json:"image,omitempty"`
 
+	// Version is the NFD version of the operand image, e.g. "v0.8.2".
+	// It selects the command line flags and configuration settings
+	// supported by the operand, for images pinned to older NFD
+	// versions. [defaults to the tag of the image, or to the latest
+	// NFD version if the tag isn't a version]
+	// +optional
+	Version string `json:"version,omitempty"`
+
 	// ImagePullPolicy defines Image pull policy for the
 	// NFD operand image [defaults to Always]
 	// +kubebuilder:validation:Optional
 	ImagePullPolicy string `json:"imagePullPolicy,omitempty"`
 
-	// ServicePort specifies the TCP port that nfd-master
-	// listens for incoming requests.
+	// ServicePort specifies the TCP port of the nfd-master Service,
+	// which nfd-master also listens on unless master.port is set.
+	// [defaults to 12000]
 	// +kubebuilder:validation:Optional
 	ServicePort int `json:"servicePort"`
+
+	// ClearNamespaceNodeSelector sets empty node selectors on the
+	// namespace of the operands, overriding the project or cluster
+	// default node selectors that would keep nfd-worker from running
+	// on every node. When not set, such node selectors are reported
+	// with warning Events. [defaults to false]
+	// +optional
+	ClearNamespaceNodeSelector bool `json:"clearNamespaceNodeSelector,omitempty"`
+
+	// ServiceAccountName is the name of an existing ServiceAccount, in
+	// the namespace of the operands, that the nfd-master and nfd-worker
+	// pods run as. The operator then doesn't create ServiceAccounts and
+	// binds the operand Roles and ClusterRoles to the given one instead.
+	// [defaults to the ServiceAccounts created by the operator]
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// PodLabels defines additional labels set on the nfd-master and
+	// nfd-worker pods, e.g. cost allocation labels. They don't
+	// override the labels the operator selects the pods with.
+	// +optional
+	PodLabels map[string]string `json:"podLabels,omitempty"`
+
+	// PodAnnotations defines additional annotations set on the
+	// nfd-master and nfd-worker pods, e.g. service mesh annotations.
+	// +optional
+	PodAnnotations map[string]string `json:"podAnnotations,omitempty"`
+
+	// Proxy defines the proxy environment variables set in the operand
+	// containers. [defaults to the cluster Proxy config on OpenShift]
+	// +optional
+	Proxy *ProxySpec `json:"proxy,omitempty"`
+}
+
+// ProxySpec defines the proxy the operands connect through
+type ProxySpec struct {
+	// HTTPProxy is the value of the HTTP_PROXY environment variable
+	// +optional
+	HTTPProxy string `json:"httpProxy,omitempty"`
+
+	// HTTPSProxy is the value of the HTTPS_PROXY environment variable
+	// +optional
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+
+	// NoProxy is the value of the NO_PROXY environment variable, the
+	// comma separated list of hosts not to connect to through the proxy
+	// +optional
+	NoProxy string `json:"noProxy,omitempty"`
+}
+
+// MasterSpec describes configuration options for the nfd-master pods
+type MasterSpec struct {
+	// Affinity defines scheduling constraints for the nfd-master
+	// pods. It is merged with the affinity defined in the master
+	// asset.
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// Resources defines the compute resource requests and limits of
+	// the nfd-master container.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Env defines additional environment variables of the nfd-master
+	// container. They override the variables of the same name set by
+	// the operator or in the asset.
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// ExtraArgs defines additional command line arguments appended
+	// to the nfd-master command, e.g. "-resync-period=1h".
+	// +optional
+	ExtraArgs []string `json:"extraArgs,omitempty"`
+
+	// PodSecurityContext is merged over the pod security context of the
+	// nfd-master asset: the fields it sets replace the ones of the asset.
+	// +optional
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+
+	// SecurityContext is merged over the security context of the
+	// nfd-master container of the asset, e.g. to add capabilities or run
+	// with a fixed UID: the fields it sets replace the ones of the asset.
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+
+	// EnableLeaderElection makes the nfd-master replicas elect a leader,
+	// the only replica updating the nodes, so that several replicas
+	// can safely run [defaults to false]
+	// +optional
+	EnableLeaderElection bool `json:"enableLeaderElection,omitempty"`
+
+	// LeaderElection tunes the leader election between nfd-master
+	// replicas, e.g. to keep leadership from flapping on clusters
+	// with a slow etcd.
+	// +optional
+	LeaderElection *LeaderElectionSpec `json:"leaderElection,omitempty"`
+
+	// StderrThreshold is the log severity at or above which
+	// nfd-master logs go to stderr [defaults to ERROR]
+	// +kubebuilder:validation:Enum=INFO;WARNING;ERROR;FATAL
+	// +optional
+	StderrThreshold string `json:"stderrThreshold,omitempty"`
+
+	// RuntimeClassName is the name of the RuntimeClass the nfd-master
+	// pods run with [defaults to the cluster default runtime]
+	// +optional
+	RuntimeClassName *string `json:"runtimeClassName,omitempty"`
+
+	// Replicas is the number of nfd-master pods [defaults to 1]
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// PodDisruptionBudget overrides the disruption budget of the
+	// nfd-master pods, only created when running more than one
+	// replica [defaults to a maxUnavailable of 1]
+	// +optional
+	PodDisruptionBudget *PodDisruptionBudgetSpec `json:"podDisruptionBudget,omitempty"`
+
+	// Port is the port nfd-master listens for gRPC connections on. The
+	// nfd-master Service forwards its port, operand.servicePort, to it.
+	// [defaults to operand.servicePort, or 12000]
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+}
+
+// PodDisruptionBudgetSpec describes the disruption budget of operand pods.
+// Only one of MinAvailable and MaxUnavailable may be set.
+type PodDisruptionBudgetSpec struct {
+	// MinAvailable is the number, or percentage, of pods that must
+	// remain available during voluntary disruptions, e.g. node drains
+	// +optional
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+
+	// MaxUnavailable is the number, or percentage, of pods that may be
+	// unavailable during voluntary disruptions
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// LeaderElectionSpec describes the leader election parameters of
+// nfd-master. Unset fields keep the nfd-master defaults.
+type LeaderElectionSpec struct {
+	// LeaseDuration is the duration non-leader replicas wait before
+	// trying to acquire leadership [defaults to 15s]
+	// +optional
+	LeaseDuration *metav1.Duration `json:"leaseDuration,omitempty"`
+
+	// RenewDeadline is the duration the leader retries refreshing
+	// leadership before giving it up [defaults to 10s]
+	// +optional
+	RenewDeadline *metav1.Duration `json:"renewDeadline,omitempty"`
+
+	// RetryPeriod is the duration between two leader election
+	// attempts [defaults to 2s]
+	// +optional
+	RetryPeriod *metav1.Duration `json:"retryPeriod,omitempty"`
+}
+
+// WorkerSpec describes configuration options for the nfd-worker pods
+type WorkerSpec struct {
+	// Tolerations defines additional tolerations for the nfd-worker
+	// pods. They are merged with the tolerations defined in the
+	// worker DaemonSet asset.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// NodeSelector restricts the nfd-worker pods to the nodes
+	// matching all of the given labels.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Affinity defines scheduling constraints for the nfd-worker
+	// pods. It is merged with the affinity defined in the worker
+	// asset.
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// Resources defines the compute resource requests and limits of
+	// the nfd-worker container.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Env defines additional environment variables of the nfd-worker
+	// container. They override the variables of the same name set by
+	// the operator or in the asset.
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// SleepInterval is the time between two feature discovery runs
+	// of nfd-worker [defaults to 60s]
+	// https://kubernetes-sigs.github.io/node-feature-discovery/v0.8/advanced/worker-commandline-reference.html#-sleep-interval
+	// +optional
+	SleepInterval *metav1.Duration `json:"sleepInterval,omitempty"`
+
+	// ExtraArgs defines additional command line arguments appended
+	// to the nfd-worker command, e.g. "-oneshot".
+	// +optional
+	ExtraArgs []string `json:"extraArgs,omitempty"`
+
+	// PodSecurityContext is merged over the pod security context of the
+	// nfd-worker asset: the fields it sets replace the ones of the asset.
+	// +optional
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+
+	// SecurityContext is merged over the security context of the
+	// nfd-worker container of the asset, e.g. to add capabilities or run
+	// with a fixed UID: the fields it sets replace the ones of the asset.
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+
+	// RuntimeClassName is the name of the RuntimeClass the nfd-worker
+	// pods run with, e.g. a runc based one on clusters whose default
+	// runtime is sandboxed and hides the host sysfs [defaults to the
+	// cluster default runtime]
+	// +optional
+	RuntimeClassName *string `json:"runtimeClassName,omitempty"`
+
+	// UpdateStrategy is the update strategy of the nfd-worker
+	// DaemonSet, e.g. a RollingUpdate with a larger maxUnavailable to
+	// roll large clusters faster, or OnDelete to update the pods
+	// manually. It takes precedence over upgrade.workerBatchSize.
+	// [defaults to a RollingUpdate with a maxUnavailable of 1]
+	// +optional
+	UpdateStrategy *appsv1.DaemonSetUpdateStrategy `json:"updateStrategy,omitempty"`
+}
+
+// TopologyUpdaterSpec describes configuration options for
+// nfd-topology-updater
+type TopologyUpdaterSpec struct {
+	// Enable deploys nfd-topology-updater on the worker nodes. It
+	// requires the NodeResourceTopology CRD to be installed.
+	// [defaults to false]
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+
+	// Image is the image of the nfd-topology-updater pods
+	// [defaults to the operand image]
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// ExtraArgs defines additional command line arguments appended
+	// to the nfd-topology-updater command, e.g. "-sleep-interval=10s".
+	// +optional
+	ExtraArgs []string `json:"extraArgs,omitempty"`
+
+	// PodSecurityContext is merged over the pod security context of the
+	// nfd-topology-updater asset: the fields it sets replace the ones of the asset.
+	// +optional
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+
+	// SecurityContext is merged over the security context of the
+	// nfd-topology-updater container of the asset, e.g. to add capabilities or run
+	// with a fixed UID: the fields it sets replace the ones of the asset.
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+
+	// ConfigData holds the raw nfd-topology-updater.conf YAML. It is
+	// written to the nfd-topology-updater ConfigMap managed by the
+	// operator. If empty, the default (all commented out)
+	// configuration is used.
+	// +optional
+	ConfigData string `json:"configData,omitempty"`
+}
+
+// GCSpec describes configuration options for nfd-gc
+type GCSpec struct {
+	// Enable deploys nfd-gc [defaults to false]
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+
+	// Interval is the time between two garbage collection runs
+	// [defaults to 1h]
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// ExtraArgs defines additional command line arguments appended
+	// to the nfd-gc command.
+	// +optional
+	ExtraArgs []string `json:"extraArgs,omitempty"`
+
+	// PodSecurityContext is merged over the pod security context of the
+	// nfd-gc asset: the fields it sets replace the ones of the asset.
+	// +optional
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+
+	// SecurityContext is merged over the security context of the
+	// nfd-gc container of the asset, e.g. to add capabilities or run
+	// with a fixed UID: the fields it sets replace the ones of the asset.
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+}
+
+// TelemetrySpec describes the opt-in telemetry reporting. The report only
+// contains aggregate data: node counts, enabled components and versions.
+type TelemetrySpec struct {
+	// Enabled turns on telemetry reporting [defaults to false]
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// ConfigMapName is the name of the ConfigMap, in the namespace of
+	// the NodeFeatureDiscovery object, the report is written to
+	// [defaults to nfd-telemetry-<name of the NodeFeatureDiscovery object>]
+	// +optional
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	// Endpoint is an optional https URL, of a host outside of the
+	// cluster, the report is POSTed to, as JSON, whenever its content
+	// changes
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// CleanupSpec describes how nodes are cleaned up on deletion
+type CleanupSpec struct {
+	// Concurrency is the maximum number of nodes that are updated
+	// in parallel [defaults to 5]
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Concurrency int `json:"concurrency,omitempty"`
+
+	// QPS is the maximum number of node updates per second, a node
+	// taking up to two updates, of its metadata and of its status
+	// [defaults to 10]
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	QPS int `json:"qps,omitempty"`
+
+	// Timeout bounds the time the cleanup may take, from the deletion
+	// request. Once elapsed, the NodeFeatureDiscovery object is let go
+	// even though the cleanup isn't done, with a warning Event, and the
+	// nodes that weren't cleaned up are listed in the
+	// nfd-cleanup-report-<name> ConfigMap. [defaults to no timeout]
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Prune runs "nfd-master -prune" in a Job once the operands are
+	// deleted, which removes the labels, annotations and extended
+	// resources nfd-master published from all the nodes, before the
+	// operator checks the nodes itself. [defaults to true]
+	// +optional
+	Prune *bool `json:"prune,omitempty"`
+}
+
+// UpgradeSpec describes how operand upgrades are rolled out. When the
+// operand image changes, nfd-master is upgraded first. The workers are only
+// upgraded once the new nfd-master is available and the existing workers
+// keep working with it. The progress is reported in the Progressing
+// condition.
+type UpgradeSpec struct {
+	// WorkerBatchSize is the maximum number, or percentage, of
+	// nfd-worker pods updated at once [defaults to 1]
+	// +optional
+	WorkerBatchSize *intstr.IntOrString `json:"workerBatchSize,omitempty"`
+
+	// MaxTemplateChangesPerHour is the number of times the pod template
+	// of an operand workload may change within an hour. Further
+	// changes, e.g. from a flapping configuration, are held back and
+	// reported in the RolloutThrottled condition, so that the pods
+	// aren't restarted over and over. [defaults to 10]
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxTemplateChangesPerHour *int32 `json:"maxTemplateChangesPerHour,omitempty"`
+}
+
+// IntegrityCheckSpec describes the periodic node label verification. A
+// sample of the nodes is checked each time, comparing their feature labels
+// with the list of labels nfd-master recorded in the node annotations, in
+// order to catch tampered labels or partial writes.
+type IntegrityCheckSpec struct {
+	// Enabled turns on the integrity check [defaults to false]
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Interval is the time between two checks [defaults to 10m]
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// SampleSize is the number of nodes checked each time
+	// [defaults to 10]
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	SampleSize int `json:"sampleSize,omitempty"`
+}
+
+// PublishingMode is where the discovered features are published
+type PublishingMode string
+
+const (
+	// PublishNodeLabels has nfd-master label the nodes
+	PublishNodeLabels PublishingMode = "NodeLabels"
+
+	// PublishNodeGroupConfigMaps has the operator aggregate the
+	// NodeFeature objects into a ConfigMap per node group, leaving the
+	// nodes untouched
+	PublishNodeGroupConfigMaps PublishingMode = "NodeGroupConfigMaps"
+)
+
+// PublishingSpec describes where the discovered features are published.
+// Publishing to ConfigMaps is meant for clusters where the Node objects
+// can't be modified, and requires the NodeFeature API.
+type PublishingSpec struct {
+	// Mode is where the features are published [defaults to NodeLabels]
+	// +kubebuilder:validation:Enum=NodeLabels;NodeGroupConfigMaps
+	// +optional
+	Mode PublishingMode `json:"mode,omitempty"`
+
+	// NodeGroupLabel is the node label whose value groups the nodes
+	// into ConfigMaps. The nodes without it are grouped together.
+	// [defaults to node.kubernetes.io/instance-type]
+	// +optional
+	NodeGroupLabel string `json:"nodeGroupLabel,omitempty"`
+
+	// Interval is the time between two updates of the ConfigMaps
+	// [defaults to 1m]
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// TLSSpec describes how the certificates of the operands are provided
+type TLSSpec struct {
+	// CertManager lets cert-manager issue the certificates
+	// +optional
+	CertManager CertManagerSpec `json:"certManager,omitempty"`
+
+	// SecretRefs names existing secrets holding the certificates, in
+	// the namespace of the operands. It is ignored when cert-manager
+	// issues the certificates.
+	// +optional
+	SecretRefs *TLSSecretRefs `json:"secretRefs,omitempty"`
+}
+
+// TLSSecretRefs names the secrets holding the certificates of the
+// operands. The pods are restarted when the secrets change, e.g. when the
+// certificates are rotated.
+type TLSSecretRefs struct {
+	// CA is the secret holding the CA certificate, under the ca.crt
+	// key
+	CA string `json:"ca"`
+
+	// Master is the kubernetes.io/tls secret holding the certificate
+	// and key of nfd-master
+	Master string `json:"master"`
+
+	// Worker is the kubernetes.io/tls secret holding the certificate
+	// and key of nfd-worker and nfd-topology-updater
+	Worker string `json:"worker"`
+}
+
+// CertManagerSpec describes the certificates issued by cert-manager. The
+// operator creates a Certificate for nfd-master, valid for its Service,
+// and one shared by nfd-worker and nfd-topology-updater, and mounts the
+// resulting secrets into the pods.
+type CertManagerSpec struct {
+	// Enable turns on mutual TLS with certificates issued by
+	// cert-manager, which must be installed [defaults to false]
+	// +optional
+	Enable bool `json:"enable,omitempty"`
+
+	// IssuerRef is the issuer of the certificates. It must be a CA
+	// issuer, so that the issued secrets hold the CA certificate. If
+	// not set, the operator creates a self-signed CA and its Issuer in
+	// the namespace of the operands.
+	// +optional
+	IssuerRef *IssuerReference `json:"issuerRef,omitempty"`
+}
+
+// IssuerReference refers to a cert-manager Issuer or ClusterIssuer
+type IssuerReference struct {
+	// Name is the name of the issuer
+	Name string `json:"name"`
+
+	// Kind is the kind of the issuer [defaults to Issuer]
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	// +optional
+	Kind string `json:"kind,omitempty"`
+}
+
+// VerificationSpec describes the smoke verification of a rollout. Once
+// the operands are ready, a node running nfd-worker is sampled and the
+// Available condition is only set once a baseline label shows up on it,
+// so that a rollout that doesn't label the nodes isn't reported as
+// available.
+type VerificationSpec struct {
+	// Enabled turns on the verification [defaults to false]
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Label is the label expected on the sampled node
+	// [defaults to feature.node.kubernetes.io/kernel-version.full]
+	// +optional
+	Label string `json:"label,omitempty"`
+
+	// Timeout is the time the label is waited for before the
+	// verification fails [defaults to 5m]
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
 }
 
 // ConfigMap describes configuration options for the NFD worker
 type ConfigMap struct {
-	// BinaryData holds the NFD configuration file
-	ConfigData string `json:"configData"`
+	// ConfigData holds the raw nfd-worker.conf YAML. It is written to
+	// the nfd-worker ConfigMap managed by the operator, replacing any
+	// manual edits of the ConfigMap. If empty, the default (all
+	// commented out) configuration is used.
+	// https://kubernetes-sigs.github.io/node-feature-discovery/v0.8/advanced/worker-configuration-reference.html
+	// +optional
+	ConfigData string `json:"configData,omitempty"`
+
+	// ConfigMapRef refers to an existing ConfigMap, e.g. managed with
+	// GitOps, holding nfd-worker.conf. When set, the ConfigMap is
+	// mounted into the nfd-worker pods instead of the one generated by
+	// the operator, ConfigData is ignored and the nfd-worker pods are
+	// restarted whenever the configuration changes.
+	// +optional
+	ConfigMapRef *ConfigMapReference `json:"configMapRef,omitempty"`
+}
+
+// MasterConfig describes configuration options for the NFD master
+type MasterConfig struct {
+	// ConfigData holds the raw nfd-master.conf YAML. It is written to
+	// the nfd-master ConfigMap managed by the operator. If empty, the
+	// default (all commented out) configuration is used.
+	// +optional
+	ConfigData string `json:"configData,omitempty"`
+
+	// ConfigMapRef refers to an existing ConfigMap holding
+	// nfd-master.conf, mounted into the nfd-master pods instead of the
+	// one generated by the operator. ConfigData is ignored when set.
+	// +optional
+	ConfigMapRef *ConfigMapReference `json:"configMapRef,omitempty"`
+}
+
+// NotificationFormat is the payload format of a notification sink
+type NotificationFormat string
+
+const (
+	// NotificationJSON POSTs the notification as a JSON document
+	NotificationJSON NotificationFormat = "JSON"
+
+	// NotificationSlack POSTs a Slack incoming webhook message, which
+	// Slack compatible chat services also accept
+	NotificationSlack NotificationFormat = "Slack"
+)
+
+// NotificationSink describes a webhook notified of the state transitions
+// of the NodeFeatureDiscovery object. Either the URL or a reference to the
+// secret holding it must be given.
+type NotificationSink struct {
+	// Name identifies the sink in the logs
+	Name string `json:"name"`
+
+	// URL is the https URL, of a host outside of the cluster, the
+	// notifications are POSTed to
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// URLSecretRef selects the key of a secret, in the namespace of the
+	// NodeFeatureDiscovery object, holding the URL, e.g. for Slack
+	// webhook URLs, which embed a token
+	// +optional
+	URLSecretRef *corev1.SecretKeySelector `json:"urlSecretRef,omitempty"`
+
+	// Format is the format of the payload [defaults to JSON]
+	// +kubebuilder:validation:Enum=JSON;Slack
+	// +optional
+	Format NotificationFormat `json:"format,omitempty"`
+
+	// Conditions restricts the notified transitions to the conditions
+	// of the given types, e.g. "Degraded" [defaults to all conditions]
+	// +optional
+	Conditions []string `json:"conditions,omitempty"`
+}
+
+// ConfigMapReference refers to a key of a ConfigMap in the namespace of
+// the NodeFeatureDiscovery object
+type ConfigMapReference struct {
+	// Name of the ConfigMap
+	Name string `json:"name"`
+
+	// Key of the ConfigMap holding the configuration file [defaults to
+	// the name of the configuration file, e.g. nfd-worker.conf]
+	// +optional
+	Key string `json:"key,omitempty"`
 }
 
 // NodeFeatureDiscoveryStatus defines the observed state of NodeFeatureDiscovery
@@ -74,11 +857,349 @@ type NodeFeatureDiscoveryStatus struct {
 	// Conditions represents the latest available observations of current state.
 	// +optional
 	Conditions []conditionsv1.Condition `json:"conditions,omitempty"`
+
+	// ObservedGeneration is the generation of the NodeFeatureDiscovery
+	// object last reconciled successfully. The operands reflect the
+	// latest spec once it equals metadata.generation.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// OperandVersion is the NFD version of the operands the last
+	// complete rollout deployed, or the tag of their image if it isn't a
+	// version, e.g. "latest".
+	// +optional
+	OperandVersion string `json:"operandVersion,omitempty"`
+
+	// OperandImage is the image of the operands the last complete
+	// rollout deployed, i.e. spec.operand.image or, if unset, the
+	// default image of the operator.
+	// +optional
+	OperandImage string `json:"operandImage,omitempty"`
+
+	// OperatorVersion is the version of the operator that deployed them.
+	// +optional
+	OperatorVersion string `json:"operatorVersion,omitempty"`
+
+	// Reconcile reports the reconciles of the NodeFeatureDiscovery
+	// object, to tell whether the operator is working on it.
+	// +optional
+	Reconcile *ReconcileStatus `json:"reconcile,omitempty"`
+
+	// Cleanup reports the progress of the node cleanup performed when
+	// the NodeFeatureDiscovery object is deleted.
+	// +optional
+	Cleanup *CleanupStatus `json:"cleanup,omitempty"`
+
+	// Integrity reports the result of the last node label integrity
+	// check.
+	// +optional
+	Integrity *IntegrityStatus `json:"integrity,omitempty"`
+
+	// Master reports the readiness of the nfd-master replicas.
+	// +optional
+	Master *MasterStatus `json:"master,omitempty"`
+
+	// Components reports the readiness of each operand, i.e. "master",
+	// "worker", "topologyUpdater" and "gc", as found by the last apply of
+	// its resources. Disabled operands aren't listed.
+	// +optional
+	Components map[string]ComponentStatus `json:"components,omitempty"`
+
+	// TopologyUpdater reports the health of nfd-topology-updater, when
+	// it's enabled.
+	// +optional
+	TopologyUpdater *TopologyUpdaterStatus `json:"topologyUpdater,omitempty"`
+
+	// Worker reports the rollout progress of the nfd-worker DaemonSet.
+	// +optional
+	Worker *WorkerStatus `json:"worker,omitempty"`
+
+	// NodeCount is the number of nodes of the cluster.
+	// +optional
+	NodeCount int `json:"nodeCount,omitempty"`
+
+	// LabeledNodes is the number of nodes carrying NFD feature labels.
+	// +optional
+	LabeledNodes int `json:"labeledNodes,omitempty"`
+
+	// Coverage reports, per operating system and architecture, the
+	// number of nodes and how many of them carry NFD labels.
+	// +optional
+	Coverage []PlatformCoverage `json:"coverage,omitempty"`
+
+	// Verification reports the result of the smoke verification of the
+	// last rollout.
+	// +optional
+	Verification *VerificationStatus `json:"verification,omitempty"`
+
+	// LabelConflicts reports the feature labels published on the same
+	// nodes by this and other NFD instances.
+	// +optional
+	LabelConflicts *LabelConflictsStatus `json:"labelConflicts,omitempty"`
+
+	// ManifestHash is the SHA-256 hash of the operand objects rendered
+	// by the last complete rollout, serialized in a stable order without
+	// the fields set by the cluster. Clusters running the same operator
+	// version with the same spec and inputs have the same hash.
+	// +optional
+	ManifestHash string `json:"manifestHash,omitempty"`
+}
+
+// LabelConflictsStatus describes the feature labels claimed by several
+// NFD instances. Each conflicting label is owned by a single instance,
+// the one of the oldest NodeFeatureDiscovery object.
+type LabelConflictsStatus struct {
+	// ConflictingLabels is the number of labels of this instance also
+	// published by other instances
+	ConflictingLabels int `json:"conflictingLabels"`
+
+	// LostLabels is the number of conflicting labels owned by another
+	// instance
+	LostLabels int `json:"lostLabels"`
+
+	// Conflicts lists the first conflicting labels
+	// +optional
+	Conflicts []LabelConflict `json:"conflicts,omitempty"`
+}
+
+// LabelConflict describes a feature label published by several instances
+type LabelConflict struct {
+	// Label is the name of the label
+	Label string `json:"label"`
+
+	// Owner is the NodeFeatureDiscovery object, as "<namespace>/<name>",
+	// whose instance owns the label
+	Owner string `json:"owner"`
+
+	// Claimants are the NodeFeatureDiscovery objects whose instances
+	// publish the label
+	Claimants []string `json:"claimants"`
+
+	// Nodes is the number of nodes the label is published on by
+	// several instances
+	Nodes int `json:"nodes"`
+}
+
+// VerificationResult is the outcome of the smoke verification
+type VerificationResult string
+
+const (
+	// VerificationPending means the label is still waited for
+	VerificationPending VerificationResult = "Pending"
+
+	// VerificationSucceeded means the label showed up on the node
+	VerificationSucceeded VerificationResult = "Succeeded"
+
+	// VerificationFailed means the label didn't show up in time
+	VerificationFailed VerificationResult = "Failed"
+)
+
+// VerificationStatus describes the smoke verification of a rollout
+type VerificationStatus struct {
+	// ObservedGeneration is the generation of the NodeFeatureDiscovery
+	// object whose rollout is verified
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// Result is the outcome of the verification
+	Result VerificationResult `json:"result"`
+
+	// Node is the name of the sampled node
+	// +optional
+	Node string `json:"node,omitempty"`
+
+	// Label is the label expected on the node
+	Label string `json:"label"`
+
+	// StartTime is the time the verification started
+	StartTime metav1.Time `json:"startTime"`
+
+	// CompletionTime is the time the verification succeeded or failed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Message describes the result
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// PlatformCoverage describes the feature discovery coverage of the nodes
+// of a platform, as given by their kubernetes.io/os and kubernetes.io/arch
+// labels
+type PlatformCoverage struct {
+	// OS is the operating system of the nodes, e.g. "linux"
+	OS string `json:"os"`
+
+	// Arch is the architecture of the nodes, e.g. "amd64"
+	Arch string `json:"arch"`
+
+	// Nodes is the number of nodes of the platform
+	Nodes int `json:"nodes"`
+
+	// LabeledNodes is the number of nodes of the platform with NFD
+	// labels
+	LabeledNodes int `json:"labeledNodes"`
+}
+
+// MasterStatus describes the readiness of the nfd-master Deployment
+type MasterStatus struct {
+	// Replicas is the number of nfd-master pods
+	Replicas int32 `json:"replicas"`
+
+	// ReadyReplicas is the number of ready nfd-master pods
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// AvailableReplicas is the number of nfd-master pods available
+	// for at least the minimum ready seconds of the Deployment
+	AvailableReplicas int32 `json:"availableReplicas"`
+
+	// Leader is the identity of the replica holding the leader
+	// election Lease, when leader election is enabled
+	// +optional
+	Leader string `json:"leader,omitempty"`
+}
+
+// ReconcileStatus describes the reconciles of a NodeFeatureDiscovery
+// object. It's updated at most once a minute, unless a reconcile fails
+// with a new error.
+type ReconcileStatus struct {
+	// LastReconcileTime is the time of the last reconcile reported
+	LastReconcileTime metav1.Time `json:"lastReconcileTime"`
+
+	// ReconcileCount is the number of reconciles so far
+	ReconcileCount int64 `json:"reconcileCount"`
+
+	// LastError is the error the last failed reconcile returned
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// LastErrorTime is the time of the last failed reconcile
+	// +optional
+	LastErrorTime *metav1.Time `json:"lastErrorTime,omitempty"`
+}
+
+// ComponentStatus describes the readiness of an operand
+type ComponentStatus struct {
+	// Ready is true if all the resources of the operand are ready
+	Ready bool `json:"ready"`
+
+	// Message tells why the operand isn't ready
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// LastTransitionTime is the last time the operand became ready or
+	// not ready
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+}
+
+// WorkerStatus describes the rollout progress of the nfd-worker DaemonSet
+type WorkerStatus struct {
+	// DesiredNumberScheduled is the number of nodes that should run an
+	// nfd-worker pod
+	DesiredNumberScheduled int32 `json:"desiredNumberScheduled"`
+
+	// NumberReady is the number of nodes running a ready nfd-worker pod
+	NumberReady int32 `json:"numberReady"`
+
+	// UpdatedNumberScheduled is the number of nodes running an
+	// nfd-worker pod of the current pod template
+	UpdatedNumberScheduled int32 `json:"updatedNumberScheduled"`
+
+	// NumberUnavailable is the number of nodes that should run an
+	// nfd-worker pod but have none available
+	NumberUnavailable int32 `json:"numberUnavailable"`
+}
+
+// TopologyUpdaterStatus describes the health of nfd-topology-updater
+type TopologyUpdaterStatus struct {
+	// DesiredNumberScheduled is the number of nodes that should run an
+	// nfd-topology-updater pod
+	DesiredNumberScheduled int32 `json:"desiredNumberScheduled"`
+
+	// NumberReady is the number of nodes running a ready
+	// nfd-topology-updater pod
+	NumberReady int32 `json:"numberReady"`
+
+	// NumberUnavailable is the number of nodes that should run an
+	// nfd-topology-updater pod but have none available
+	NumberUnavailable int32 `json:"numberUnavailable"`
+
+	// NodeResourceTopologies is the number of existing nodes with a
+	// NodeResourceTopology object
+	NodeResourceTopologies int `json:"nodeResourceTopologies"`
+
+	// Message tells why the NodeResourceTopology objects are missing
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// CleanupStatus describes the progress of the node cleanup. Nodes are
+// processed in alphabetical order so that the cleanup can be resumed
+// after an operator restart.
+type CleanupStatus struct {
+	// LastNode is the name of the last node that was cleaned up
+	// +optional
+	LastNode string `json:"lastNode,omitempty"`
+
+	// CleanedNodes is the number of nodes cleaned up so far
+	CleanedNodes int `json:"cleanedNodes"`
+
+	// TotalNodes is the number of nodes in the cluster
+	TotalNodes int `json:"totalNodes"`
+
+	// Pruned is true once the prune Job is done, see
+	// spec.cleanup.prune
+	// +optional
+	Pruned bool `json:"pruned,omitempty"`
+}
+
+// IntegrityStatus describes the result of a node label integrity check
+type IntegrityStatus struct {
+	// LastCheckTime is the time of the last check
+	LastCheckTime metav1.Time `json:"lastCheckTime"`
+
+	// CheckedNodes is the number of nodes checked
+	CheckedNodes int `json:"checkedNodes"`
+
+	// DiscrepantNodes is the number of checked nodes whose labels
+	// don't match the ones published by nfd-master
+	DiscrepantNodes int `json:"discrepantNodes"`
+
+	// Discrepancies lists the mismatching labels of the first
+	// discrepant nodes
+	// +optional
+	Discrepancies []NodeLabelDiscrepancy `json:"discrepancies,omitempty"`
+}
+
+// NodeLabelDiscrepancy describes the feature labels of a node that don't
+// match the ones published by nfd-master
+type NodeLabelDiscrepancy struct {
+	// Node is the name of the node
+	Node string `json:"node"`
+
+	// MissingLabels are published by nfd-master but missing on the
+	// node
+	// +optional
+	MissingLabels []string `json:"missingLabels,omitempty"`
+
+	// UnexpectedLabels are feature labels present on the node but
+	// not published by nfd-master
+	// +optional
+	UnexpectedLabels []string `json:"unexpectedLabels,omitempty"`
 }
 
 // +kubebuilder:object:root=true
+// +kubebuilder:storageversion
 // +kubebuilder:subresource:status
-// +kubebuilder:resource:path=nodefeaturediscoveries,scope=Namespaced
+// +kubebuilder:resource:path=nodefeaturediscoveries,scope=Namespaced,shortName=nfd
+// +kubebuilder:printcolumn:name="Available",type=string,JSONPath=`.status.conditions[?(@.type=="Available")].status`
+// +kubebuilder:printcolumn:name="Progressing",type=string,JSONPath=`.status.conditions[?(@.type=="Progressing")].status`
+// +kubebuilder:printcolumn:name="Degraded",type=string,JSONPath=`.status.conditions[?(@.type=="Degraded")].status`
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.operandVersion`
+// +kubebuilder:printcolumn:name="Labeled",type=integer,JSONPath=`.status.labeledNodes`,description="Nodes carrying NFD labels"
+// +kubebuilder:printcolumn:name="Nodes",type=integer,JSONPath=`.status.nodeCount`,priority=1
+// +kubebuilder:printcolumn:name="Paused",type=boolean,JSONPath=`.spec.paused`,priority=1
+// +kubebuilder:printcolumn:name="Management",type=string,JSONPath=`.spec.managementState`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // NodeFeatureDiscovery is the Schema for the nodefeaturediscoveries API
 type NodeFeatureDiscovery struct {
@@ -103,9 +1224,14 @@ func init() {
 	SchemeBuilder.Register(&NodeFeatureDiscovery{}, &NodeFeatureDiscoveryList{})
 }
 
-// ImagePath returns a compiled full valid image string
+// ImagePath returns a compiled full valid image string. The image of the
+// operator version running is used if none is set, so that upgrading the
+// operator upgrades the operands.
 func (o *OperandSpec) ImagePath() string {
-	return o.Image
+	if o.Image != "" {
+		return o.Image
+	}
+	return config.NodeFeatureDiscoveryImage()
 }
 
 // ImagePolicy returns a valid corev1.PullPolicy from the string in the CR
@@ -119,6 +1245,12 @@ func (o *OperandSpec) ImagePolicy(pullPolicy string) corev1.PullPolicy {
 	return corev1.PullIfNotPresent
 }
 
+// NamespaceManaged returns true if the operator may create and modify the
+// namespace of the operands
+func (s *NodeFeatureDiscoverySpec) NamespaceManaged() bool {
+	return s.ManageNamespace == nil || *s.ManageNamespace
+}
+
 // Data returns a valid ConfigMap name
 func (c *ConfigMap) Data() string {
 	return c.ConfigData
@@ -0,0 +1,560 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/version"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/yaml"
+)
+
+// The defaults of the spec, which the operator and the operands apply when
+// a field isn't set. Default fills them in so that the persisted objects
+// show what actually runs.
+const (
+	DefaultServicePort               = 12000
+	DefaultMasterReplicas            = int32(1)
+	DefaultWorkerSleepInterval       = time.Minute
+	DefaultGCInterval                = time.Hour
+	DefaultCleanupConcurrency        = 5
+	DefaultCleanupQPS                = 10
+	DefaultWorkerBatchSize           = 1
+	DefaultMaxTemplateChangesPerHour = int32(10)
+	DefaultIntegrityCheckInterval    = 10 * time.Minute
+	DefaultIntegrityCheckSampleSize  = 10
+	DefaultPublishingMode            = PublishNodeLabels
+	DefaultNodeGroupLabel            = corev1.LabelInstanceTypeStable
+	DefaultPublishingInterval        = time.Minute
+	DefaultVerificationTimeout       = 5 * time.Minute
+
+	// DefaultVerificationLabel is labeled by all the NFD versions, with
+	// the default worker configuration
+	DefaultVerificationLabel = "feature.node.kubernetes.io/kernel-version.full"
+)
+
+// The nfd-master leader election defaults, which the values set in the CR
+// are validated against
+const (
+	defaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+
+	// leaderElectionJitter is the jitter factor of client-go's leader
+	// election, the renew deadline must be greater than the retry period
+	// times this factor
+	leaderElectionJitter = 1.2
+)
+
+// AllowedLabelNsAnnotation is set by cluster admins on a tenant namespace
+// to restrict the label namespaces the NodeFeatureDiscovery objects of that
+// namespace may publish labels in. Its value is a comma separated list of
+// label namespaces, where "*.example.com" matches any subdomain of
+// example.com.
+const AllowedLabelNsAnnotation = "nfd.kubernetes.io/allowed-label-ns"
+
+// namespaceReader reads the namespaces of the objects being validated, for
+// their label namespace policy. It's set by SetupWebhookWithManager.
+var namespaceReader client.Reader
+
+// SetupWebhookWithManager registers the admission webhooks of the
+// NodeFeatureDiscovery objects with the manager
+func (r *NodeFeatureDiscovery) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	namespaceReader = mgr.GetAPIReader()
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-nfd-kubernetes-io-v1-nodefeaturediscovery,mutating=true,failurePolicy=fail,sideEffects=None,groups=nfd.kubernetes.io,resources=nodefeaturediscoveries,verbs=create;update,versions=v1,name=mnodefeaturediscovery.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Defaulter = &NodeFeatureDiscovery{}
+
+// Default fills in the unset fields of the spec with the values the
+// operator would otherwise apply implicitly. The fields of the disabled
+// components are defaulted too, so that enabling them doesn't come with
+// unexpected values. The objects being deleted are left as is.
+func (r *NodeFeatureDiscovery) Default() {
+	if r.GetDeletionTimestamp() != nil {
+		return
+	}
+	r.Spec.Default()
+}
+
+// Default fills in the unset fields of the spec with their defaults
+func (s *NodeFeatureDiscoverySpec) Default() {
+	if s.ManagementState == "" {
+		s.ManagementState = Managed
+	}
+	if s.DeletionPolicy == "" {
+		s.DeletionPolicy = DeletionPolicyDelete
+	}
+
+	if s.Operand.ImagePullPolicy == "" {
+		s.Operand.ImagePullPolicy = string(s.Operand.ImagePolicy(""))
+	}
+	if s.Operand.ServicePort == 0 {
+		s.Operand.ServicePort = DefaultServicePort
+	}
+
+	if s.Master.Replicas == nil {
+		replicas := DefaultMasterReplicas
+		s.Master.Replicas = &replicas
+	}
+	if s.Worker.SleepInterval == nil {
+		s.Worker.SleepInterval = &metav1.Duration{Duration: DefaultWorkerSleepInterval}
+	}
+	if s.GC.Interval == nil {
+		s.GC.Interval = &metav1.Duration{Duration: DefaultGCInterval}
+	}
+
+	// The worker update strategy, when set, takes precedence over the
+	// batch size, which is then left unset
+	if s.Worker.UpdateStrategy == nil && s.Upgrade.WorkerBatchSize == nil {
+		size := intstr.FromInt(DefaultWorkerBatchSize)
+		s.Upgrade.WorkerBatchSize = &size
+	}
+	if s.Upgrade.MaxTemplateChangesPerHour == nil {
+		changes := DefaultMaxTemplateChangesPerHour
+		s.Upgrade.MaxTemplateChangesPerHour = &changes
+	}
+
+	if s.Cleanup.Concurrency == 0 {
+		s.Cleanup.Concurrency = DefaultCleanupConcurrency
+	}
+	if s.Cleanup.QPS == 0 {
+		s.Cleanup.QPS = DefaultCleanupQPS
+	}
+	if s.Cleanup.Prune == nil {
+		prune := true
+		s.Cleanup.Prune = &prune
+	}
+
+	if s.IntegrityCheck.Interval == nil {
+		s.IntegrityCheck.Interval = &metav1.Duration{Duration: DefaultIntegrityCheckInterval}
+	}
+	if s.IntegrityCheck.SampleSize == 0 {
+		s.IntegrityCheck.SampleSize = DefaultIntegrityCheckSampleSize
+	}
+
+	if s.Publishing.Mode == "" {
+		s.Publishing.Mode = DefaultPublishingMode
+	}
+	if s.Publishing.NodeGroupLabel == "" {
+		s.Publishing.NodeGroupLabel = DefaultNodeGroupLabel
+	}
+	if s.Publishing.Interval == nil {
+		s.Publishing.Interval = &metav1.Duration{Duration: DefaultPublishingInterval}
+	}
+
+	if s.Verification.Label == "" {
+		s.Verification.Label = DefaultVerificationLabel
+	}
+	if s.Verification.Timeout == nil {
+		s.Verification.Timeout = &metav1.Duration{Duration: DefaultVerificationTimeout}
+	}
+}
+
+// +kubebuilder:webhook:path=/validate-nfd-kubernetes-io-v1-nodefeaturediscovery,mutating=false,failurePolicy=fail,sideEffects=None,groups=nfd.kubernetes.io,resources=nodefeaturediscoveries,verbs=create;update,versions=v1,name=vnodefeaturediscovery.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &NodeFeatureDiscovery{}
+
+// ValidateCreate rejects the NodeFeatureDiscovery objects with an invalid
+// spec
+func (r *NodeFeatureDiscovery) ValidateCreate() error {
+	return r.validate()
+}
+
+// ValidateUpdate rejects the updates of the spec making it invalid. The
+// updates leaving the spec as is, e.g. of the finalizers, are let through
+// so that the objects created before the webhook are still reconciled and
+// deleted. The old spec is defaulted first, as the defaults filled in by
+// the mutating webhook don't change it.
+func (r *NodeFeatureDiscovery) ValidateUpdate(old runtime.Object) error {
+	if o, ok := old.(*NodeFeatureDiscovery); ok {
+		spec := o.Spec.DeepCopy()
+		spec.Default()
+		if reflect.DeepEqual(o.Spec, r.Spec) || reflect.DeepEqual(*spec, r.Spec) {
+			return nil
+		}
+	}
+	if r.GetDeletionTimestamp() != nil {
+		return nil
+	}
+	return r.validate()
+}
+
+// ValidateDelete lets any NodeFeatureDiscovery object be deleted
+func (r *NodeFeatureDiscovery) ValidateDelete() error {
+	return nil
+}
+
+// validate returns an Invalid error listing the invalid fields of the spec,
+// if any, including the ones its namespace's label namespace policy
+// doesn't allow
+func (r *NodeFeatureDiscovery) validate() error {
+	errs := r.Spec.Validate(field.NewPath("spec"))
+	if namespaceReader != nil && r.GetNamespace() != "" {
+		ns := &corev1.Namespace{}
+		if err := namespaceReader.Get(context.TODO(), types.NamespacedName{Name: r.GetNamespace()}, ns); err != nil {
+			return apierrors.NewInternalError(fmt.Errorf("could not read the label namespace policy: %w", err))
+		}
+		if allowed, ok := LabelNsPolicy(ns); ok {
+			errs = append(errs, r.Spec.ValidateLabelNsPolicy(field.NewPath("spec"), allowed)...)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("NodeFeatureDiscovery").GroupKind(), r.GetName(), errs)
+}
+
+// Validate returns the errors of the spec the CRD schema can't catch,
+// which would otherwise only show up once the operands are failing
+func (s *NodeFeatureDiscoverySpec) Validate(p *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	errs = append(errs, s.Operand.validate(p.Child("operand"))...)
+
+	if s.LabelWhiteList != "" {
+		if _, err := regexp.Compile(s.LabelWhiteList); err != nil {
+			errs = append(errs, field.Invalid(p.Child("labelWhiteList"), s.LabelWhiteList,
+				fmt.Sprintf("not a valid regular expression: %v", err)))
+		}
+	}
+	for i, ns := range s.ExtraLabelNs {
+		errs = append(errs, validateLabelNs(p.Child("extraLabelNs").Index(i), ns, false)...)
+	}
+	for i, ns := range s.DenyLabelNs {
+		errs = append(errs, validateLabelNs(p.Child("denyLabelNs").Index(i), ns, true)...)
+	}
+
+	errs = append(errs, validateConfig(p.Child("workerConfig"), s.WorkerConfig.ConfigData, s.WorkerConfig.ConfigMapRef)...)
+	errs = append(errs, validateConfig(p.Child("masterConfig"), s.MasterConfig.ConfigData, s.MasterConfig.ConfigMapRef)...)
+	errs = append(errs, validateConfig(p.Child("topologyUpdater"), s.TopologyUpdater.ConfigData, nil)...)
+
+	errs = append(errs, s.Master.validate(p.Child("master"))...)
+	errs = append(errs, validatePositive(p.Child("worker", "sleepInterval"), s.Worker.SleepInterval)...)
+	errs = append(errs, validatePositive(p.Child("gc", "interval"), s.GC.Interval)...)
+	errs = append(errs, validatePositive(p.Child("cleanup", "timeout"), s.Cleanup.Timeout)...)
+	errs = append(errs, validatePositive(p.Child("integrityCheck", "interval"), s.IntegrityCheck.Interval)...)
+	errs = append(errs, validatePositive(p.Child("verification", "timeout"), s.Verification.Timeout)...)
+	errs = append(errs, validatePositive(p.Child("publishing", "interval"), s.Publishing.Interval)...)
+
+	if s.Publishing.Mode == PublishNodeGroupConfigMaps && !s.EnableNodeFeatureAPI {
+		errs = append(errs, field.Invalid(p.Child("publishing", "mode"), s.Publishing.Mode,
+			"publishing to ConfigMaps requires enableNodeFeatureApi to be true"))
+	}
+
+	errs = append(errs, s.TLS.validate(p.Child("tls"))...)
+
+	if s.Telemetry.Endpoint != "" {
+		errs = append(errs, validateURL(p.Child("telemetry", "endpoint"), s.Telemetry.Endpoint)...)
+	}
+
+	names := map[string]bool{}
+	for i, sink := range s.Notifications {
+		sp := p.Child("notifications").Index(i)
+		if sink.Name == "" {
+			errs = append(errs, field.Required(sp.Child("name"), "the sink must be named"))
+		} else if names[sink.Name] {
+			errs = append(errs, field.Duplicate(sp.Child("name"), sink.Name))
+		}
+		names[sink.Name] = true
+
+		switch {
+		case sink.URL == "" && sink.URLSecretRef == nil:
+			errs = append(errs, field.Required(sp, "one of url or urlSecretRef must be set"))
+		case sink.URL != "" && sink.URLSecretRef != nil:
+			errs = append(errs, field.Forbidden(sp.Child("urlSecretRef"), "url and urlSecretRef can't both be set"))
+		case sink.URL != "":
+			errs = append(errs, validateURL(sp.Child("url"), sink.URL)...)
+		}
+	}
+
+	return errs
+}
+
+// validate returns the errors of the operand options
+func (o *OperandSpec) validate(p *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	switch corev1.PullPolicy(o.ImagePullPolicy) {
+	case "", corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever:
+	default:
+		errs = append(errs, field.NotSupported(p.Child("imagePullPolicy"), o.ImagePullPolicy,
+			[]string{string(corev1.PullAlways), string(corev1.PullIfNotPresent), string(corev1.PullNever)}))
+	}
+
+	if o.Version != "" {
+		if _, err := version.ParseGeneric(o.Version); err != nil {
+			errs = append(errs, field.Invalid(p.Child("version"), o.Version, "must be an NFD version, e.g. v0.8.2"))
+		}
+	}
+
+	if o.ServicePort < 0 || o.ServicePort > 65535 {
+		errs = append(errs, field.Invalid(p.Child("servicePort"), o.ServicePort, "must be between 1 and 65535"))
+	}
+
+	return errs
+}
+
+// validate returns the errors of the nfd-master options
+func (m *MasterSpec) validate(p *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if pdb := m.PodDisruptionBudget; pdb != nil && pdb.MinAvailable != nil && pdb.MaxUnavailable != nil {
+		errs = append(errs, field.Forbidden(p.Child("podDisruptionBudget"), "only one of minAvailable and maxUnavailable may be set"))
+	}
+
+	if le := m.LeaderElection; le != nil {
+		lp := p.Child("leaderElection")
+		errs = append(errs, validatePositive(lp.Child("leaseDuration"), le.LeaseDuration)...)
+		errs = append(errs, validatePositive(lp.Child("renewDeadline"), le.RenewDeadline)...)
+		errs = append(errs, validatePositive(lp.Child("retryPeriod"), le.RetryPeriod)...)
+
+		lease, renew, retry := defaultLeaseDuration, defaultRenewDeadline, defaultRetryPeriod
+		if le.LeaseDuration != nil {
+			lease = le.LeaseDuration.Duration
+		}
+		if le.RenewDeadline != nil {
+			renew = le.RenewDeadline.Duration
+		}
+		if le.RetryPeriod != nil {
+			retry = le.RetryPeriod.Duration
+		}
+		if lease <= renew {
+			errs = append(errs, field.Invalid(lp.Child("leaseDuration"), lease.String(),
+				fmt.Sprintf("must be greater than the renew deadline (%s)", renew)))
+		}
+		if float64(renew) <= leaderElectionJitter*float64(retry) {
+			errs = append(errs, field.Invalid(lp.Child("renewDeadline"), renew.String(),
+				fmt.Sprintf("must be greater than %v times the retry period (%s)", leaderElectionJitter, retry)))
+		}
+	}
+
+	return errs
+}
+
+// validate returns the errors of the TLS options, which provide the
+// certificates either with cert-manager or with existing secrets
+func (t *TLSSpec) validate(p *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if ref := t.CertManager.IssuerRef; ref != nil {
+		if !t.CertManager.Enable {
+			errs = append(errs, field.Forbidden(p.Child("certManager", "issuerRef"), "requires certManager.enable to be true"))
+		}
+		if ref.Name == "" {
+			errs = append(errs, field.Required(p.Child("certManager", "issuerRef", "name"), "the issuer must be named"))
+		}
+	}
+
+	if refs := t.SecretRefs; refs != nil {
+		if t.CertManager.Enable {
+			errs = append(errs, field.Forbidden(p.Child("secretRefs"), "can't be set when cert-manager issues the certificates"))
+		}
+		for _, ref := range []struct{ name, secret string }{{"ca", refs.CA}, {"master", refs.Master}, {"worker", refs.Worker}} {
+			if ref.secret == "" {
+				errs = append(errs, field.Required(p.Child("secretRefs", ref.name), "all the certificate secrets must be named"))
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateLabelNs returns the errors of a label namespace, which is a DNS
+// subdomain, optionally prefixed with a "*." wildcard
+func validateLabelNs(p *field.Path, ns string, wildcard bool) field.ErrorList {
+	name := ns
+	if wildcard {
+		name = strings.TrimPrefix(ns, "*.")
+	}
+	msgs := validation.IsDNS1123Subdomain(name)
+	if len(msgs) == 0 {
+		return nil
+	}
+	return field.ErrorList{field.Invalid(p, ns, "not a valid label namespace: "+strings.Join(msgs, ", "))}
+}
+
+// validateConfig returns the errors of an operand configuration, which
+// must be YAML, or the name of the ConfigMap holding it
+func validateConfig(p *field.Path, data string, ref *ConfigMapReference) field.ErrorList {
+	errs := field.ErrorList{}
+	if ref != nil && ref.Name == "" {
+		errs = append(errs, field.Required(p.Child("configMapRef", "name"), "the ConfigMap must be named"))
+	}
+	if data != "" {
+		if _, err := yaml.YAMLToJSON([]byte(data)); err != nil {
+			errs = append(errs, field.Invalid(p.Child("configData"), "", fmt.Sprintf("not valid YAML: %v", err)))
+		}
+	}
+	return errs
+}
+
+// validatePositive returns an error if the duration, if set, isn't
+// positive
+func validatePositive(p *field.Path, d *metav1.Duration) field.ErrorList {
+	if d == nil || d.Duration > 0 {
+		return nil
+	}
+	return field.ErrorList{field.Invalid(p, d.Duration.String(), "must be a positive duration, e.g. 30s")}
+}
+
+// validateURL returns an error if u isn't an endpoint the operator may
+// POST to, see ValidateEndpoint
+func validateURL(p *field.Path, u string) field.ErrorList {
+	if err := ValidateEndpoint(u); err != nil {
+		// The URL may embed a token, so don't echo it
+		return field.ErrorList{field.Invalid(p, "", err.Error())}
+	}
+	return nil
+}
+
+// internalHostSuffixes are the suffixes of the host names that resolve to
+// the cluster services, the node itself or the cloud metadata servers,
+// e.g. metadata.google.internal
+var internalHostSuffixes = []string{".localhost", ".local", ".internal", ".svc"}
+
+// ValidateEndpoint returns an error if u isn't an https URL of a host
+// outside of the cluster, as the operator POSTs the notifications and the
+// telemetry reports to it on behalf of the authors of the CRs. Loopback,
+// link-local and private addresses, the names without a domain, which
+// resolve to the services of the namespace, and the cluster and cloud
+// metadata domains are refused. The host names resolving to internal
+// addresses are refused when connecting, see InternalAddress.
+func ValidateEndpoint(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("must be an https URL")
+	}
+	if parsed.Scheme != "https" {
+		return fmt.Errorf("must be an https URL")
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if ip := net.ParseIP(host); ip != nil {
+		if InternalAddress(ip) {
+			return fmt.Errorf("must not be a loopback, link-local or private address")
+		}
+		return nil
+	}
+	if host == "localhost" || !strings.Contains(host, ".") {
+		return fmt.Errorf("must be a fully qualified host name outside of the cluster")
+	}
+	for _, suffix := range internalHostSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return fmt.Errorf("must be a host outside of the cluster, not in the %s domain", suffix[1:])
+		}
+	}
+	return nil
+}
+
+// privateNetworks are the IPv4 and IPv6 private address ranges, which the
+// pod and service networks of the clusters use, along with the carrier
+// grade NAT range some clusters use as well
+var privateNetworks = func() []*net.IPNet {
+	nets := []*net.IPNet{}
+	for _, cidr := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"} {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// InternalAddress returns true if ip is an unspecified, loopback,
+// link-local, e.g. a cloud metadata server, or private address
+func InternalAddress(ip net.IP) bool {
+	if ip.IsUnspecified() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() {
+		return true
+	}
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// LabelNsPolicy returns the label namespaces the NodeFeatureDiscovery
+// objects of the given namespace may publish labels in, as set in its
+// AllowedLabelNsAnnotation, and false if it has no policy
+func LabelNsPolicy(ns metav1.Object) ([]string, bool) {
+	policy, ok := ns.GetAnnotations()[AllowedLabelNsAnnotation]
+	if !ok {
+		return nil, false
+	}
+	allowed := []string{}
+	for _, a := range strings.Split(policy, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			allowed = append(allowed, a)
+		}
+	}
+	return allowed, true
+}
+
+// ValidateLabelNsPolicy returns the errors of the label namespaces
+// requested in the spec that don't match any of the allowed ones. The
+// labels of the other namespaces are denied to nfd-master when a policy
+// applies, see LabelNsPolicy.
+func (s *NodeFeatureDiscoverySpec) ValidateLabelNsPolicy(p *field.Path, allowed []string) field.ErrorList {
+	errs := field.ErrorList{}
+	for i, ns := range s.ExtraLabelNs {
+		if !labelNsAllowed(ns, allowed) {
+			errs = append(errs, field.Forbidden(p.Child("extraLabelNs").Index(i),
+				fmt.Sprintf("label namespace %q is not allowed by the %s annotation of the namespace", ns, AllowedLabelNsAnnotation)))
+		}
+	}
+	return errs
+}
+
+// labelNsAllowed returns true if the label namespace matches one of the
+// allowed patterns
+func labelNsAllowed(labelNs string, allowed []string) bool {
+	for _, a := range allowed {
+		if a == labelNs {
+			return true
+		}
+		if strings.HasPrefix(a, "*.") && strings.HasSuffix(labelNs, a[1:]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,318 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// duration returns a metav1.Duration of the given duration
+func duration(d time.Duration) *metav1.Duration {
+	return &metav1.Duration{Duration: d}
+}
+
+// errorFields returns the paths of the fields of the errors
+func errorFields(errs field.ErrorList) []string {
+	fields := []string{}
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	return fields
+}
+
+func TestSpecValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(s *NodeFeatureDiscoverySpec)
+		want   []string
+	}{
+		{"defaults", func(s *NodeFeatureDiscoverySpec) {}, []string{}},
+		{"image pull policy", func(s *NodeFeatureDiscoverySpec) {
+			s.Operand.ImagePullPolicy = "Sometimes"
+		}, []string{"spec.operand.imagePullPolicy"}},
+		{"operand version", func(s *NodeFeatureDiscoverySpec) {
+			s.Operand.Version = "latest"
+		}, []string{"spec.operand.version"}},
+		{"service port", func(s *NodeFeatureDiscoverySpec) {
+			s.Operand.ServicePort = 65536
+		}, []string{"spec.operand.servicePort"}},
+		{"label whitelist", func(s *NodeFeatureDiscoverySpec) {
+			s.LabelWhiteList = "feature.node.kubernetes.io/(cpu"
+		}, []string{"spec.labelWhiteList"}},
+		{"extra label namespace", func(s *NodeFeatureDiscoverySpec) {
+			s.ExtraLabelNs = []string{"vendor.example.com", "Vendor_Example"}
+		}, []string{"spec.extraLabelNs[1]"}},
+		{"extra label namespace wildcard", func(s *NodeFeatureDiscoverySpec) {
+			s.ExtraLabelNs = []string{"*.example.com"}
+		}, []string{"spec.extraLabelNs[0]"}},
+		{"deny label namespace wildcard", func(s *NodeFeatureDiscoverySpec) {
+			s.DenyLabelNs = []string{"*.example.com", "*.Example_com"}
+		}, []string{"spec.denyLabelNs[1]"}},
+		{"worker config", func(s *NodeFeatureDiscoverySpec) {
+			s.WorkerConfig.ConfigData = "core: [sleepInterval"
+		}, []string{"spec.workerConfig.configData"}},
+		{"master config map", func(s *NodeFeatureDiscoverySpec) {
+			s.MasterConfig.ConfigMapRef = &ConfigMapReference{}
+		}, []string{"spec.masterConfig.configMapRef.name"}},
+		{"topology updater config", func(s *NodeFeatureDiscoverySpec) {
+			s.TopologyUpdater.ConfigData = "excludeList: {"
+		}, []string{"spec.topologyUpdater.configData"}},
+		{"durations", func(s *NodeFeatureDiscoverySpec) {
+			s.Worker.SleepInterval = duration(0)
+			s.GC.Interval = duration(-time.Hour)
+			s.Cleanup.Timeout = duration(0)
+			s.IntegrityCheck.Interval = duration(0)
+			s.Verification.Timeout = duration(0)
+			s.Publishing.Interval = duration(0)
+		}, []string{"spec.worker.sleepInterval", "spec.gc.interval", "spec.cleanup.timeout",
+			"spec.integrityCheck.interval", "spec.verification.timeout", "spec.publishing.interval"}},
+		{"publishing mode", func(s *NodeFeatureDiscoverySpec) {
+			s.Publishing.Mode = PublishNodeGroupConfigMaps
+		}, []string{"spec.publishing.mode"}},
+		{"publishing mode with the NodeFeature API", func(s *NodeFeatureDiscoverySpec) {
+			s.Publishing.Mode = PublishNodeGroupConfigMaps
+			s.EnableNodeFeatureAPI = true
+		}, []string{}},
+		{"pod disruption budget", func(s *NodeFeatureDiscoverySpec) {
+			one := intstr.FromInt(1)
+			s.Master.PodDisruptionBudget = &PodDisruptionBudgetSpec{MinAvailable: &one, MaxUnavailable: &one}
+		}, []string{"spec.master.podDisruptionBudget"}},
+		{"telemetry endpoint", func(s *NodeFeatureDiscoverySpec) {
+			s.Telemetry.Endpoint = "http://telemetry.example.com"
+		}, []string{"spec.telemetry.endpoint"}},
+		{"notifications", func(s *NodeFeatureDiscoverySpec) {
+			s.Notifications = []NotificationSink{
+				{Name: "ok", URL: "https://hooks.example.com/nfd"},
+				{URL: "https://hooks.example.com/nfd"},
+				{Name: "ok", URL: "https://hooks.example.com/nfd"},
+				{Name: "none"},
+				{Name: "both", URL: "https://hooks.example.com/nfd", URLSecretRef: &corev1.SecretKeySelector{Key: "url"}},
+				{Name: "internal", URL: "https://nfd-master.nfd.svc/nfd"},
+			}
+		}, []string{"spec.notifications[1].name", "spec.notifications[2].name", "spec.notifications[3]",
+			"spec.notifications[4].urlSecretRef", "spec.notifications[5].url"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &NodeFeatureDiscoverySpec{}
+			s.Default()
+			tc.mutate(s)
+			if got := errorFields(s.Validate(field.NewPath("spec"))); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got errors on %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLeaderElectionValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		le   LeaderElectionSpec
+		want []string
+	}{
+		{"defaults", LeaderElectionSpec{}, []string{}},
+		{"valid", LeaderElectionSpec{LeaseDuration: duration(30 * time.Second), RenewDeadline: duration(20 * time.Second), RetryPeriod: duration(5 * time.Second)}, []string{}},
+		{"lease equal to the default renew deadline", LeaderElectionSpec{LeaseDuration: duration(10 * time.Second)},
+			[]string{"spec.master.leaderElection.leaseDuration"}},
+		{"renew deadline beyond the default lease", LeaderElectionSpec{RenewDeadline: duration(20 * time.Second)},
+			[]string{"spec.master.leaderElection.leaseDuration"}},
+		// The renew deadline must be strictly greater than 1.2 times the
+		// retry period
+		{"renew deadline at the jitter bound", LeaderElectionSpec{RenewDeadline: duration(2400 * time.Millisecond)},
+			[]string{"spec.master.leaderElection.renewDeadline"}},
+		{"renew deadline above the jitter bound", LeaderElectionSpec{RenewDeadline: duration(2500 * time.Millisecond)}, []string{}},
+		{"retry period beyond the default renew deadline", LeaderElectionSpec{RetryPeriod: duration(9 * time.Second)},
+			[]string{"spec.master.leaderElection.renewDeadline"}},
+		{"negative durations", LeaderElectionSpec{LeaseDuration: duration(-time.Second), RetryPeriod: duration(0)},
+			[]string{"spec.master.leaderElection.leaseDuration", "spec.master.leaderElection.retryPeriod",
+				"spec.master.leaderElection.leaseDuration"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &MasterSpec{LeaderElection: &tc.le}
+			if got := errorFields(m.validate(field.NewPath("spec", "master"))); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got errors on %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTLSValidate(t *testing.T) {
+	refs := &TLSSecretRefs{CA: "nfd-ca", Master: "nfd-master-tls", Worker: "nfd-worker-tls"}
+	tests := []struct {
+		name string
+		tls  TLSSpec
+		want []string
+	}{
+		{"disabled", TLSSpec{}, []string{}},
+		{"cert-manager", TLSSpec{CertManager: CertManagerSpec{Enable: true, IssuerRef: &IssuerReference{Name: "ca"}}}, []string{}},
+		{"secrets", TLSSpec{SecretRefs: refs}, []string{}},
+		{"issuer without cert-manager", TLSSpec{CertManager: CertManagerSpec{IssuerRef: &IssuerReference{Name: "ca"}}},
+			[]string{"spec.tls.certManager.issuerRef"}},
+		{"unnamed issuer", TLSSpec{CertManager: CertManagerSpec{Enable: true, IssuerRef: &IssuerReference{}}},
+			[]string{"spec.tls.certManager.issuerRef.name"}},
+		{"secrets and cert-manager", TLSSpec{CertManager: CertManagerSpec{Enable: true}, SecretRefs: refs},
+			[]string{"spec.tls.secretRefs"}},
+		{"unnamed secrets", TLSSpec{SecretRefs: &TLSSecretRefs{Master: "nfd-master-tls"}},
+			[]string{"spec.tls.secretRefs.ca", "spec.tls.secretRefs.worker"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := errorFields(tc.tls.validate(field.NewPath("spec", "tls"))); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got errors on %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLabelNsAllowed(t *testing.T) {
+	allowed := []string{"vendor.io", "*.example.com"}
+	tests := []struct {
+		labelNs string
+		want    bool
+	}{
+		{"vendor.io", true},
+		{"sub.vendor.io", false},
+		{"myvendor.io", false},
+		{"gpu.example.com", true},
+		{"a.gpu.example.com", true},
+		{"example.com", false},
+		{"evilexample.com", false},
+		{"example.com.evil.io", false},
+	}
+
+	for _, tc := range tests {
+		if got := labelNsAllowed(tc.labelNs, allowed); got != tc.want {
+			t.Errorf("labelNsAllowed(%q): got %v, want %v", tc.labelNs, got, tc.want)
+		}
+	}
+
+	if labelNsAllowed("vendor.io", nil) {
+		t.Error("label namespace allowed by an empty policy")
+	}
+}
+
+func TestValidateLabelNsPolicy(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{AllowedLabelNsAnnotation: " vendor.io, ,*.example.com"},
+	}}
+	allowed, ok := LabelNsPolicy(ns)
+	if !ok || !reflect.DeepEqual(allowed, []string{"vendor.io", "*.example.com"}) {
+		t.Fatalf("got policy %v, %v", allowed, ok)
+	}
+	if _, ok := LabelNsPolicy(&corev1.Namespace{}); ok {
+		t.Error("got a policy for a namespace without annotation")
+	}
+
+	s := &NodeFeatureDiscoverySpec{ExtraLabelNs: []string{"gpu.example.com", "evilexample.com", "vendor.io", "other.io"}}
+	want := []string{"spec.extraLabelNs[1]", "spec.extraLabelNs[3]"}
+	if got := errorFields(s.ValidateLabelNsPolicy(field.NewPath("spec"), allowed)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got errors on %v, want %v", got, want)
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	// An object created before the webhooks, with an invalid spec the
+	// defaults don't change
+	old := &NodeFeatureDiscovery{
+		ObjectMeta: metav1.ObjectMeta{Name: "nfd"},
+		Spec:       NodeFeatureDiscoverySpec{LabelWhiteList: "("},
+	}
+
+	// Updating the metadata only
+	unchanged := old.DeepCopy()
+	unchanged.Finalizers = []string{"nfd.kubernetes.io/finalizer"}
+	if err := unchanged.ValidateUpdate(old); err != nil {
+		t.Errorf("unchanged spec: %v", err)
+	}
+
+	// The update filled in by the mutating webhook
+	defaulted := old.DeepCopy()
+	defaulted.Default()
+	if err := defaulted.ValidateUpdate(old); err != nil {
+		t.Errorf("defaulted spec: %v", err)
+	}
+
+	// Updating the spec, leaving it invalid
+	changed := defaulted.DeepCopy()
+	changed.Spec.Instance = "other"
+	if err := changed.ValidateUpdate(old); err == nil {
+		t.Error("invalid spec update accepted")
+	}
+
+	// The objects being deleted are let through
+	deleting := changed.DeepCopy()
+	now := metav1.Now()
+	deleting.DeletionTimestamp = &now
+	if err := deleting.ValidateUpdate(old); err != nil {
+		t.Errorf("object being deleted: %v", err)
+	}
+
+	// Fixing the spec
+	fixed := changed.DeepCopy()
+	fixed.Spec.LabelWhiteList = ""
+	if err := fixed.ValidateUpdate(old); err != nil {
+		t.Errorf("valid spec: %v", err)
+	}
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://hooks.example.com/nfd", true},
+		{"https://hooks.example.com:8443/nfd?token=x", true},
+		{"https://203.0.113.10/nfd", true},
+		{"http://hooks.example.com/nfd", false},
+		{"ftp://hooks.example.com/nfd", false},
+		{"hooks.example.com/nfd", false},
+		{"https://", false},
+		{"https://localhost/nfd", false},
+		{"https://127.0.0.1/nfd", false},
+		{"https://[::1]/nfd", false},
+		{"https://0.0.0.0/nfd", false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"https://[fe80::1]/nfd", false},
+		{"https://10.96.0.1/nfd", false},
+		{"https://172.16.0.1/nfd", false},
+		{"https://192.168.1.1/nfd", false},
+		{"https://[fd00::1]/nfd", false},
+		{"https://kubernetes/nfd", false},
+		{"https://nfd-master.nfd.svc/nfd", false},
+		{"https://nfd-master.nfd.svc.cluster.local./nfd", false},
+		{"https://metadata.google.internal/computeMetadata/v1", false},
+	}
+	for _, tc := range tests {
+		err := ValidateEndpoint(tc.url)
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.url, err)
+		} else if !tc.valid && err == nil {
+			t.Errorf("%s: expected an error", tc.url)
+		}
+	}
+}
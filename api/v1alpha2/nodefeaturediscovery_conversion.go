@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+var _ conversion.Convertible = &NodeFeatureDiscovery{}
+
+// ConvertTo converts the object to v1, the hub version. All the fields
+// have a v1 counterpart, so nothing is lost.
+func (src *NodeFeatureDiscovery) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*nfdv1.NodeFeatureDiscovery)
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Status = src.Status
+
+	s := src.Spec
+	dst.Spec = nfdv1.NodeFeatureDiscoverySpec{
+		Operand:              s.Operand.OperandSpec,
+		Instance:             s.Instance,
+		ManageNamespace:      s.Operand.ManageNamespace,
+		Master:               s.Master.MasterSpec,
+		MasterConfig:         s.Master.Config,
+		LabelWhiteList:       s.Master.LabelWhiteList,
+		ExtraLabelNs:         s.Master.ExtraLabelNs,
+		DenyLabelNs:          s.Master.DenyLabelNs,
+		ResourceLabels:       s.Master.ResourceLabels,
+		EnableTaints:         s.Master.EnableTaints,
+		Worker:               s.Worker.WorkerSpec,
+		WorkerConfig:         s.Worker.Config,
+		TopologyUpdater:      s.TopologyUpdater,
+		GC:                   s.GC,
+		FeatureGates:         s.FeatureGates,
+		EnableNodeFeatureAPI: s.EnableNodeFeatureAPI,
+		Publishing:           s.Publishing,
+		TLS:                  s.TLS,
+		Telemetry:            s.Telemetry,
+		Cleanup:              s.Cleanup,
+		Upgrade:              s.Upgrade,
+		IntegrityCheck:       s.IntegrityCheck,
+		Verification:         s.Verification,
+		Notifications:        s.Notifications,
+		Paused:               s.Paused,
+		ManagementState:      s.ManagementState,
+		AdoptExisting:        s.AdoptExisting,
+		DeletionPolicy:       s.DeletionPolicy,
+	}
+	return nil
+}
+
+// ConvertFrom converts the object from v1, the hub version
+func (dst *NodeFeatureDiscovery) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*nfdv1.NodeFeatureDiscovery)
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Status = src.Status
+
+	s := src.Spec
+	dst.Spec = NodeFeatureDiscoverySpec{
+		Operand: OperandSpec{
+			OperandSpec:     s.Operand,
+			ManageNamespace: s.ManageNamespace,
+		},
+		Instance: s.Instance,
+		Master: MasterSpec{
+			MasterSpec:     s.Master,
+			Config:         s.MasterConfig,
+			LabelWhiteList: s.LabelWhiteList,
+			ExtraLabelNs:   s.ExtraLabelNs,
+			DenyLabelNs:    s.DenyLabelNs,
+			ResourceLabels: s.ResourceLabels,
+			EnableTaints:   s.EnableTaints,
+		},
+		Worker: WorkerSpec{
+			WorkerSpec: s.Worker,
+			Config:     s.WorkerConfig,
+		},
+		TopologyUpdater:      s.TopologyUpdater,
+		GC:                   s.GC,
+		FeatureGates:         s.FeatureGates,
+		EnableNodeFeatureAPI: s.EnableNodeFeatureAPI,
+		Publishing:           s.Publishing,
+		TLS:                  s.TLS,
+		Telemetry:            s.Telemetry,
+		Cleanup:              s.Cleanup,
+		Upgrade:              s.Upgrade,
+		IntegrityCheck:       s.IntegrityCheck,
+		Verification:         s.Verification,
+		Notifications:        s.Notifications,
+		Paused:               s.Paused,
+		ManagementState:      s.ManagementState,
+		AdoptExisting:        s.AdoptExisting,
+		DeletionPolicy:       s.DeletionPolicy,
+	}
+	return nil
+}
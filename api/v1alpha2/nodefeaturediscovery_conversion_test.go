@@ -0,0 +1,73 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"testing"
+
+	fuzz "github.com/google/gofuzz"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/diff"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// fuzzRounds is the number of random objects converted back and forth
+const fuzzRounds = 1000
+
+func TestConvertRoundTrip(t *testing.T) {
+	// The type meta isn't converted, it's set by the conversion webhook
+	f := fuzz.New().NilChance(0.2).NumElements(0, 3).Funcs(func(tm *metav1.TypeMeta, _ fuzz.Continue) {})
+
+	// v1 -> v1alpha2 -> v1, a field missing in either direction being
+	// lost
+	for i := 0; i < fuzzRounds; i++ {
+		in := &nfdv1.NodeFeatureDiscovery{}
+		f.Fuzz(in)
+
+		spoke := &NodeFeatureDiscovery{}
+		if err := spoke.ConvertFrom(in.DeepCopy()); err != nil {
+			t.Fatalf("ConvertFrom: %v", err)
+		}
+		out := &nfdv1.NodeFeatureDiscovery{}
+		if err := spoke.ConvertTo(out); err != nil {
+			t.Fatalf("ConvertTo: %v", err)
+		}
+		if !apiequality.Semantic.DeepEqual(in, out) {
+			t.Fatalf("v1 round trip changed the object: %s", diff.ObjectReflectDiff(in, out))
+		}
+	}
+
+	// v1alpha2 -> v1 -> v1alpha2
+	for i := 0; i < fuzzRounds; i++ {
+		in := &NodeFeatureDiscovery{}
+		f.Fuzz(in)
+
+		hub := &nfdv1.NodeFeatureDiscovery{}
+		if err := in.DeepCopy().ConvertTo(hub); err != nil {
+			t.Fatalf("ConvertTo: %v", err)
+		}
+		out := &NodeFeatureDiscovery{}
+		if err := out.ConvertFrom(hub); err != nil {
+			t.Fatalf("ConvertFrom: %v", err)
+		}
+		if !apiequality.Semantic.DeepEqual(in, out) {
+			t.Fatalf("v1alpha2 round trip changed the object: %s", diff.ObjectReflectDiff(in, out))
+		}
+	}
+}
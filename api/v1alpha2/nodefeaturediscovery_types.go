@@ -0,0 +1,237 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// NodeFeatureDiscoverySpec defines the desired state of NodeFeatureDiscovery.
+// The options of each operand are grouped in its section, the sections
+// left as is since v1 share their types with it.
+type NodeFeatureDiscoverySpec struct {
+	Operand OperandSpec `json:"operand"`
+
+	// Instance name. Used to separate annotation namespaces for
+	// multiple parallel deployments. The names of the operand
+	// resources are suffixed with it so that the deployments don't
+	// collide.
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?)?$`
+	// +kubebuilder:validation:MaxLength=32
+	// +optional
+	Instance string `json:"instance,omitempty"`
+
+	// Master describes the nfd-master Deployment and the labels it
+	// publishes.
+	// +optional
+	Master MasterSpec `json:"master,omitempty"`
+
+	// Worker describes the nfd-worker DaemonSet and its configuration.
+	// +optional
+	Worker WorkerSpec `json:"worker,omitempty"`
+
+	// TopologyUpdater describes the nfd-topology-updater DaemonSet,
+	// which publishes the NodeResourceTopology objects of the nodes.
+	// +optional
+	TopologyUpdater nfdv1.TopologyUpdaterSpec `json:"topologyUpdater,omitempty"`
+
+	// GC describes the nfd-gc Deployment, which deletes the
+	// NodeFeature and NodeResourceTopology objects of the nodes that
+	// are gone.
+	// +optional
+	GC nfdv1.GCSpec `json:"gc,omitempty"`
+
+	// FeatureGates enables or disables NFD features, e.g.
+	// "NodeFeatureAPI". They are passed to both nfd-master and
+	// nfd-worker.
+	// +optional
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+
+	// EnableNodeFeatureAPI makes nfd-worker publish the features it
+	// discovers in NodeFeature objects, which nfd-master watches,
+	// instead of sending them to nfd-master over gRPC. [defaults to false]
+	// +optional
+	EnableNodeFeatureAPI bool `json:"enableNodeFeatureApi,omitempty"`
+
+	// Publishing configures where the discovered features are
+	// published.
+	// +optional
+	Publishing nfdv1.PublishingSpec `json:"publishing,omitempty"`
+
+	// TLS configures the mutual TLS authentication of the gRPC
+	// connections between nfd-master and its clients.
+	// +optional
+	TLS nfdv1.TLSSpec `json:"tls,omitempty"`
+
+	// Telemetry configures the opt-in reporting of anonymized,
+	// aggregate usage data.
+	// +optional
+	Telemetry nfdv1.TelemetrySpec `json:"telemetry,omitempty"`
+
+	// Cleanup configures how the NFD labels are removed from the
+	// nodes when the NodeFeatureDiscovery object is deleted.
+	// +optional
+	Cleanup nfdv1.CleanupSpec `json:"cleanup,omitempty"`
+
+	// Upgrade configures how operand upgrades are rolled out.
+	// +optional
+	Upgrade nfdv1.UpgradeSpec `json:"upgrade,omitempty"`
+
+	// IntegrityCheck configures the periodic verification of the
+	// labels of the nodes against the ones nfd-master published.
+	// +optional
+	IntegrityCheck nfdv1.IntegrityCheckSpec `json:"integrityCheck,omitempty"`
+
+	// Verification configures the check of a rollout on a node, before
+	// the operands are reported available.
+	// +optional
+	Verification nfdv1.VerificationSpec `json:"verification,omitempty"`
+
+	// Notifications lists the webhooks notified of the condition
+	// transitions and of the end of the cleanup.
+	// +optional
+	Notifications []nfdv1.NotificationSink `json:"notifications,omitempty"`
+
+	// Paused stops the operator from applying the operand resources,
+	// e.g. to patch them by hand during an incident, until it's turned
+	// off again. The deletion of the object is still handled.
+	// [defaults to false]
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// ManagementState tells whether the operator manages the operands:
+	// Managed reconciles them, Unmanaged leaves them as they are and
+	// Removed deletes them, while keeping the NodeFeatureDiscovery
+	// object and the node labels. [defaults to Managed]
+	// +kubebuilder:validation:Enum=Managed;Unmanaged;Removed
+	// +optional
+	ManagementState nfdv1.ManagementState `json:"managementState,omitempty"`
+
+	// AdoptExisting lets the operator take over the nfd-master,
+	// nfd-worker, nfd-topology-updater and nfd-gc workloads deployed in
+	// the namespace from the NFD manifests or Helm chart, instead of
+	// running duplicates next to them. The workloads with the names the
+	// operator uses are adopted, the other ones are deleted once the
+	// operator's are available. [defaults to false]
+	// +optional
+	AdoptExisting bool `json:"adoptExisting,omitempty"`
+
+	// DeletionPolicy tells what happens to the operands when the
+	// NodeFeatureDiscovery object is deleted: Delete removes them along
+	// with the node labels, Retain orphans them, leaving NFD running and
+	// the nodes labelled. [defaults to Delete]
+	// +kubebuilder:validation:Enum=Delete;Retain
+	// +optional
+	DeletionPolicy nfdv1.DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// OperandSpec describes the options shared by all the operands
+type OperandSpec struct {
+	nfdv1.OperandSpec `json:",inline"`
+
+	// ManageNamespace lets the operator create and modify the namespace
+	// of the operands. When false, the namespace must already exist and
+	// is left untouched, e.g. its node selectors aren't cleared.
+	// [defaults to true]
+	// +optional
+	ManageNamespace *bool `json:"manageNamespace,omitempty"`
+}
+
+// MasterSpec describes the nfd-master Deployment, its configuration and
+// the labels it publishes
+type MasterSpec struct {
+	nfdv1.MasterSpec `json:",inline"`
+
+	// Config describes the configuration file of nfd-master
+	// +optional
+	Config nfdv1.MasterConfig `json:"config,omitempty"`
+
+	// LabelWhiteList is a regular expression used by nfd-master to
+	// filter the feature labels it publishes. Labels not matching
+	// it are dropped.
+	// +optional
+	LabelWhiteList string `json:"labelWhiteList,omitempty"`
+
+	// ExtraLabelNs is the list of label namespaces, in addition to the
+	// default feature.node.kubernetes.io, nfd-master is allowed to
+	// publish labels in.
+	// +optional
+	ExtraLabelNs []string `json:"extraLabelNs,omitempty"`
+
+	// DenyLabelNs is the list of label namespaces nfd-master refuses
+	// to publish labels in. Wildcards, like "*.example.com", are
+	// supported.
+	// +optional
+	DenyLabelNs []string `json:"denyLabelNs,omitempty"`
+
+	// ResourceLabels is the list of feature labels nfd-master
+	// advertises as extended resources instead of labels, e.g.
+	// "vendor.io/feature-1"
+	// +optional
+	ResourceLabels []string `json:"resourceLabels,omitempty"`
+
+	// EnableTaints lets nfd-master taint the nodes according to the
+	// NodeFeatureRules. [defaults to false]
+	// +optional
+	EnableTaints bool `json:"enableTaints,omitempty"`
+}
+
+// WorkerSpec describes the nfd-worker DaemonSet and its configuration
+type WorkerSpec struct {
+	nfdv1.WorkerSpec `json:",inline"`
+
+	// Config describes the configuration file of nfd-worker
+	// +optional
+	Config nfdv1.ConfigMap `json:"config,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=nodefeaturediscoveries,scope=Namespaced,shortName=nfd
+// +kubebuilder:printcolumn:name="Available",type=string,JSONPath=`.status.conditions[?(@.type=="Available")].status`
+// +kubebuilder:printcolumn:name="Progressing",type=string,JSONPath=`.status.conditions[?(@.type=="Progressing")].status`
+// +kubebuilder:printcolumn:name="Degraded",type=string,JSONPath=`.status.conditions[?(@.type=="Degraded")].status`
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.operandVersion`
+// +kubebuilder:printcolumn:name="Labeled",type=integer,JSONPath=`.status.labeledNodes`,description="Nodes carrying NFD labels"
+// +kubebuilder:printcolumn:name="Nodes",type=integer,JSONPath=`.status.nodeCount`,priority=1
+// +kubebuilder:printcolumn:name="Paused",type=boolean,JSONPath=`.spec.paused`,priority=1
+// +kubebuilder:printcolumn:name="Management",type=string,JSONPath=`.spec.managementState`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NodeFeatureDiscovery is the Schema for the nodefeaturediscoveries API
+type NodeFeatureDiscovery struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NodeFeatureDiscoverySpec         `json:"spec,omitempty"`
+	Status nfdv1.NodeFeatureDiscoveryStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NodeFeatureDiscoveryList contains a list of NodeFeatureDiscovery
+type NodeFeatureDiscoveryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NodeFeatureDiscovery `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NodeFeatureDiscovery{}, &NodeFeatureDiscoveryList{})
+}
@@ -0,0 +1,196 @@
+// +build !ignore_autogenerated
+
+/*
+Copyright 2021. The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1alpha2
+
+import (
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MasterSpec) DeepCopyInto(out *MasterSpec) {
+	*out = *in
+	in.MasterSpec.DeepCopyInto(&out.MasterSpec)
+	in.Config.DeepCopyInto(&out.Config)
+	if in.ExtraLabelNs != nil {
+		in, out := &in.ExtraLabelNs, &out.ExtraLabelNs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DenyLabelNs != nil {
+		in, out := &in.DenyLabelNs, &out.DenyLabelNs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ResourceLabels != nil {
+		in, out := &in.ResourceLabels, &out.ResourceLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MasterSpec.
+func (in *MasterSpec) DeepCopy() *MasterSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MasterSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeFeatureDiscovery) DeepCopyInto(out *NodeFeatureDiscovery) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeFeatureDiscovery.
+func (in *NodeFeatureDiscovery) DeepCopy() *NodeFeatureDiscovery {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeFeatureDiscovery)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NodeFeatureDiscovery) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeFeatureDiscoveryList) DeepCopyInto(out *NodeFeatureDiscoveryList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NodeFeatureDiscovery, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeFeatureDiscoveryList.
+func (in *NodeFeatureDiscoveryList) DeepCopy() *NodeFeatureDiscoveryList {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeFeatureDiscoveryList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NodeFeatureDiscoveryList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeFeatureDiscoverySpec) DeepCopyInto(out *NodeFeatureDiscoverySpec) {
+	*out = *in
+	in.Operand.DeepCopyInto(&out.Operand)
+	in.Master.DeepCopyInto(&out.Master)
+	in.Worker.DeepCopyInto(&out.Worker)
+	in.TopologyUpdater.DeepCopyInto(&out.TopologyUpdater)
+	in.GC.DeepCopyInto(&out.GC)
+	if in.FeatureGates != nil {
+		in, out := &in.FeatureGates, &out.FeatureGates
+		*out = make(map[string]bool, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Publishing.DeepCopyInto(&out.Publishing)
+	in.TLS.DeepCopyInto(&out.TLS)
+	out.Telemetry = in.Telemetry
+	in.Cleanup.DeepCopyInto(&out.Cleanup)
+	in.Upgrade.DeepCopyInto(&out.Upgrade)
+	in.IntegrityCheck.DeepCopyInto(&out.IntegrityCheck)
+	in.Verification.DeepCopyInto(&out.Verification)
+	if in.Notifications != nil {
+		in, out := &in.Notifications, &out.Notifications
+		*out = make([]v1.NotificationSink, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeFeatureDiscoverySpec.
+func (in *NodeFeatureDiscoverySpec) DeepCopy() *NodeFeatureDiscoverySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeFeatureDiscoverySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperandSpec) DeepCopyInto(out *OperandSpec) {
+	*out = *in
+	in.OperandSpec.DeepCopyInto(&out.OperandSpec)
+	if in.ManageNamespace != nil {
+		in, out := &in.ManageNamespace, &out.ManageNamespace
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperandSpec.
+func (in *OperandSpec) DeepCopy() *OperandSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OperandSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkerSpec) DeepCopyInto(out *WorkerSpec) {
+	*out = *in
+	in.WorkerSpec.DeepCopyInto(&out.WorkerSpec)
+	in.Config.DeepCopyInto(&out.Config)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerSpec.
+func (in *WorkerSpec) DeepCopy() *WorkerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,34 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package assets embeds the default operand manifests, so that the
+// operator, or another operator embedding NFD, doesn't depend on them
+// being installed under /opt/nfd.
+package assets
+
+import "embed"
+
+// FS holds the nodefeatureapi, certmanager, master, worker,
+// topologyupdater, gc and console asset directories
+//
+//go:embed nodefeatureapi certmanager master worker topologyupdater gc console
+var FS embed.FS
+
+// ConsolePlugin holds the files of the OpenShift console dynamic plugin
+// served by the operator
+//
+//go:embed consoleplugin
+var ConsolePlugin embed.FS
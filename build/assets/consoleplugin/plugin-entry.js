@@ -0,0 +1,14 @@
+// The extensions of the plugin are declared in plugin-manifest.json and
+// don't reference any code, so the plugin entry exposes no module. It
+// registers with the callback of the console, which was renamed in later
+// console versions.
+(function () {
+  var entry = {
+    init: function () {},
+    get: function (module) {
+      return Promise.reject(new Error('nfd-console-plugin exposes no module ' + module));
+    },
+  };
+  var register = window.__load_plugin_entry__ || window.loadPluginEntry;
+  register('nfd-console-plugin@0.1.0', entry);
+})();
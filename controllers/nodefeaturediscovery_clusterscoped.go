@@ -0,0 +1,78 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+)
+
+// clusterScopedDeleted only lets through the deletion of the
+// cluster-scoped resources managed by the operator, i.e. the ClusterRoles,
+// ClusterRoleBindings and SecurityContextConstraints of the operands,
+// which can't be owned by the namespaced NodeFeatureDiscovery objects.
+// Their other events don't need a reconcile, as they're applied on every
+// reconcile anyway.
+var clusterScopedDeleted = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		return false
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return false
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return deployment.ClusterScopedOwner(e.Object) != ""
+	},
+	GenericFunc: func(e event.GenericEvent) bool {
+		return false
+	},
+}
+
+// clusterScopedToRequests maps a deleted cluster-scoped resource to
+// reconcile requests for the NodeFeatureDiscovery objects it was managed
+// for, so that it's recreated right away rather than on the next change.
+func (r *NodeFeatureDiscoveryReconciler) clusterScopedToRequests(obj client.Object) []reconcile.Request {
+	owner := deployment.ClusterScopedOwner(obj)
+	if owner == "" {
+		return nil
+	}
+
+	nfdList := &nfdv1.NodeFeatureDiscoveryList{}
+	if err := r.List(context.TODO(), nfdList); err != nil {
+		r.Log.Error(err, "could not list NodeFeatureDiscovery objects")
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, i := range nfdList.Items {
+		if i.Name != owner || !i.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: i.Namespace, Name: i.Name},
+		})
+	}
+	return requests
+}
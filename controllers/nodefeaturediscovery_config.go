@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+)
+
+const (
+	// conditionConfigInvalid is true when nfd-worker fails to parse its
+	// configuration
+	conditionConfigInvalid conditionsv1.ConditionType = "ConfigInvalid"
+
+	reasonWorkerConfigInvalid = "WorkerConfigParseFailed"
+	reasonConfigValid         = "ConfigValid"
+)
+
+// configErrorRegexp matches the errors nfd-worker exits with when its
+// configuration file or --options can't be parsed
+var configErrorRegexp = regexp.MustCompile(`(?i)failed to parse (config file|-?-options)[^\n]*`)
+
+// checkWorkerConfig reflects the configuration parse errors of the
+// nfd-worker pods in the ConfigInvalid condition. The pods report the end
+// of their logs in their termination message when they fail, so the logs
+// don't need to be read.
+func (r *NodeFeatureDiscoveryReconciler) checkWorkerConfig(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) error {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(ins.GetNamespace()), deployment.PodSelector(ins, "nfd-worker")); err != nil {
+		return err
+	}
+
+	// Report the distinct errors, along with the number of pods having
+	// them, in a stable order
+	errs := map[string]int{}
+	for i := range pods.Items {
+		if msg := configError(&pods.Items[i]); msg != "" {
+			errs[msg]++
+		}
+	}
+
+	cond := conditionsv1.Condition{
+		Type:   conditionConfigInvalid,
+		Status: corev1.ConditionFalse,
+		Reason: reasonConfigValid,
+	}
+	if len(errs) > 0 {
+		msgs := []string{}
+		for msg, count := range errs {
+			msgs = append(msgs, fmt.Sprintf("%s (%d pods)", msg, count))
+		}
+		sort.Strings(msgs)
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = reasonWorkerConfigInvalid
+		cond.Message = strings.Join(msgs, "; ")
+	}
+
+	found := conditionsv1.FindStatusCondition(ins.Status.Conditions, conditionConfigInvalid)
+	if found != nil && found.Status == cond.Status && found.Reason == cond.Reason && found.Message == cond.Message {
+		return nil
+	}
+	if cond.Status == corev1.ConditionTrue {
+		r.warn(ins, reasonWorkerConfigInvalid, cond.Message)
+	}
+	conditionsv1.SetStatusCondition(&ins.Status.Conditions, cond)
+	return r.Status().Update(ctx, ins)
+}
+
+// configError returns the configuration parse error the nfd-worker
+// container of the pod last failed with, if any. Only the containers
+// that haven't run successfully since are considered, so the condition
+// clears once the configuration is fixed.
+func configError(pod *corev1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Ready {
+			continue
+		}
+		for _, terminated := range []*corev1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+			if terminated == nil || terminated.ExitCode == 0 {
+				continue
+			}
+			if msg := configErrorRegexp.FindString(terminated.Message); msg != "" {
+				return msg
+			}
+		}
+	}
+	return ""
+}
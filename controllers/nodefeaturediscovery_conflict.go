@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// reasonInstanceConflict is the reason of the conditions of a CR that
+// isn't reconciled because another CR already deploys its instance
+const reasonInstanceConflict = "InstanceConflict"
+
+// instanceOwner returns the NodeFeatureDiscovery object deploying the
+// instance of ins, i.e. the oldest live object, in any namespace, with the
+// same spec.instance, or nil if that's ins itself. The instance names the
+// cluster-scoped resources and the node annotations of the deployment, so
+// it must be unique across the cluster.
+func (r *NodeFeatureDiscoveryReconciler) instanceOwner(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) (*nfdv1.NodeFeatureDiscovery, error) {
+	list := &nfdv1.NodeFeatureDiscoveryList{}
+	if err := r.List(ctx, list); err != nil {
+		return nil, err
+	}
+
+	var owner *nfdv1.NodeFeatureDiscovery
+	for i := range list.Items {
+		other := &list.Items[i]
+		if other.GetUID() == ins.GetUID() || other.GetDeletionTimestamp() != nil || other.Spec.Instance != ins.Spec.Instance {
+			continue
+		}
+		if precedes(other, ins) && (owner == nil || precedes(other, owner)) {
+			owner = other
+		}
+	}
+	return owner, nil
+}
+
+// instanceInUse returns true if another live NodeFeatureDiscovery object,
+// in any namespace, uses the same instance name as ins
+func (r *NodeFeatureDiscoveryReconciler) instanceInUse(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) (bool, error) {
+	list := &nfdv1.NodeFeatureDiscoveryList{}
+	if err := r.List(ctx, list); err != nil {
+		return false, err
+	}
+	for _, other := range list.Items {
+		if other.GetUID() != ins.GetUID() && other.GetDeletionTimestamp() == nil && other.Spec.Instance == ins.Spec.Instance {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// precedes returns true if a was created before b, breaking ties with the
+// namespace and name
+func precedes(a, b *nfdv1.NodeFeatureDiscovery) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	if a.GetNamespace() != b.GetNamespace() {
+		return a.GetNamespace() < b.GetNamespace()
+	}
+	return a.GetName() < b.GetName()
+}
+
+// rejectConflict reports that ins isn't reconciled because owner already
+// deploys its instance
+func (r *NodeFeatureDiscoveryReconciler) rejectConflict(ctx context.Context, ins, owner *nfdv1.NodeFeatureDiscovery) error {
+	msg := fmt.Sprintf("instance %q is already deployed by NodeFeatureDiscovery %s/%s, set a distinct spec.instance",
+		ins.Spec.Instance, owner.GetNamespace(), owner.GetName())
+
+	return r.setConditions(ctx, ins,
+		condition(conditionsv1.ConditionAvailable, false, reasonInstanceConflict, msg),
+		condition(conditionsv1.ConditionProgressing, false, reasonInstanceConflict, msg),
+		condition(conditionsv1.ConditionDegraded, true, reasonInstanceConflict, msg),
+		condition(conditionsv1.ConditionUpgradeable, false, reasonInstanceConflict, msg))
+}
+
+// conflictToRequests maps a NodeFeatureDiscovery object to reconcile
+// requests for the other objects rejected because of an instance
+// conflict, so that they're deployed once their instance is free again
+func (r *NodeFeatureDiscoveryReconciler) conflictToRequests(obj client.Object) []reconcile.Request {
+	list := &nfdv1.NodeFeatureDiscoveryList{}
+	if err := r.List(context.TODO(), list); err != nil {
+		r.Log.Error(err, "could not list NodeFeatureDiscovery objects")
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, i := range list.Items {
+		if i.GetUID() == obj.GetUID() {
+			continue
+		}
+		cond := conditionsv1.FindStatusCondition(i.Status.Conditions, conditionsv1.ConditionDegraded)
+		if cond == nil || cond.Reason != reasonInstanceConflict {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: i.Namespace, Name: i.Name},
+		})
+	}
+	return requests
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/build/assets"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+)
+
+// consolePluginShutdownTimeout bounds the time spent finishing the
+// pending requests once the manager stops
+const consolePluginShutdownTimeout = 5 * time.Second
+
+// ConsolePluginServer serves the OpenShift console dynamic plugin adding
+// the NodeFeatureDiscovery objects to the console navigation and their
+// operand status to their details page, and declares it with a
+// ConsolePlugin. The console fetches the plugin files through the plugin
+// Service, over TLS, with the serving certificate of the Service.
+type ConsolePluginServer struct {
+	Client client.Client
+	Log    logr.Logger
+
+	// Addr is the address the plugin files are served on
+	Addr string
+
+	// CertDir holds the tls.crt and tls.key serving certificate
+	CertDir string
+
+	// Service is the Service forwarding Port to Addr, which the
+	// ConsolePlugin points to
+	Service types.NamespacedName
+	Port    int32
+}
+
+// NeedLeaderElection implements the manager LeaderElectionRunnable: the
+// plugin files are served by all the replicas, as the Service forwards to
+// any of them
+func (s *ConsolePluginServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start declares the ConsolePlugin and serves the plugin files until ctx
+// is done. Nothing is served without a serving certificate, e.g. when the
+// Service isn't annotated to get one.
+func (s *ConsolePluginServer) Start(ctx context.Context) error {
+	certFile := filepath.Join(s.CertDir, "tls.crt")
+	keyFile := filepath.Join(s.CertDir, "tls.key")
+	if _, err := os.Stat(certFile); errors.Is(err, fs.ErrNotExist) {
+		s.Log.Info("No serving certificate, not serving the console plugin", "certDir", s.CertDir)
+		return nil
+	}
+
+	// A ConsolePlugin left as is only keeps the console from showing
+	// the plugin, which doesn't prevent the operator from working
+	if err := deployment.ApplyConsolePlugin(ctx, s.Client, s.Service, s.Port); err != nil {
+		s.Log.Error(err, "Couldn't apply the ConsolePlugin", "name", deployment.ConsolePluginName)
+	}
+
+	handler, err := s.handler()
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Addr: s.Addr, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), consolePluginShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			s.Log.Error(err, "Couldn't stop serving the console plugin")
+		}
+	}()
+
+	s.Log.Info("Serving the console plugin", "addr", s.Addr)
+	if err := srv.ListenAndServeTLS(certFile, keyFile); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// handler serves the embedded plugin files. They're revalidated on every
+// fetch, so that the console picks the plugin of an upgraded operator.
+func (s *ConsolePluginServer) handler() (http.Handler, error) {
+	files, err := fs.Sub(assets.ConsolePlugin, "consoleplugin")
+	if err != nil {
+		return nil, err
+	}
+	fileServer := http.FileServer(http.FS(files))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		fileServer.ServeHTTP(w, r)
+	}), nil
+}
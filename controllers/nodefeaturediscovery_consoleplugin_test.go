@@ -0,0 +1,114 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// get returns the body of a file served by the console plugin handler
+func get(t *testing.T, h http.Handler, path string) []byte {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s: got status %d", path, rec.Code)
+	}
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// hasJSONPath returns true if the dot separated path of JSON field names
+// exists in the given struct type
+func hasJSONPath(typ reflect.Type, path string) bool {
+	for _, name := range strings.Split(path, ".") {
+		for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Map {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct {
+			return false
+		}
+		found := false
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if strings.Split(f.Tag.Get("json"), ",")[0] == name {
+				typ, found = f.Type, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func TestConsolePluginFiles(t *testing.T) {
+	h, err := (&ConsolePluginServer{}).handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := struct {
+		Name       string
+		Version    string
+		Extensions []struct {
+			Type       string
+			Properties struct {
+				Model struct {
+					Group, Version, Kind string
+				}
+				Path string
+			}
+		}
+	}{}
+	if err := json.Unmarshal(get(t, h, "/plugin-manifest.json"), &manifest); err != nil {
+		t.Fatalf("invalid plugin manifest: %v", err)
+	}
+
+	// The plugin is registered under the name and version of its manifest
+	entry := string(get(t, h, "/plugin-entry.js"))
+	if id := manifest.Name + "@" + manifest.Version; !strings.Contains(entry, "'"+id+"'") {
+		t.Errorf("plugin entry doesn't register %s", id)
+	}
+
+	// The extensions point to the NodeFeatureDiscovery fields
+	gvk := nfdv1.GroupVersion.WithKind("NodeFeatureDiscovery")
+	for _, ext := range manifest.Extensions {
+		model := ext.Properties.Model
+		if model.Group != gvk.Group || model.Version != gvk.Version || model.Kind != gvk.Kind {
+			t.Errorf("%s extension: got model %+v, want %s", ext.Type, model, gvk)
+		}
+		if ext.Type != "console.resource/details-item" {
+			continue
+		}
+		if !hasJSONPath(reflect.TypeOf(nfdv1.NodeFeatureDiscovery{}), ext.Properties.Path) {
+			t.Errorf("details item path %q not found in NodeFeatureDiscovery", ext.Properties.Path)
+		}
+	}
+}
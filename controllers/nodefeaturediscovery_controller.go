@@ -18,23 +18,36 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/go-logr/logr"
+	secv1 "github.com/openshift/api/security/v1"
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
 )
 
 // log is used to set the logger with a name that describes the actions of
@@ -42,7 +55,24 @@ import (
 var log = logf.Log.WithName("controller_nodefeaturediscovery")
 
 // nfd is an NFD object that will be used to initialize the NFD operator
-var nfd NFD
+var nfd deployment.NFD
+
+const (
+	// reconcileNowAnnotation can be set on a NodeFeatureDiscovery CR to
+	// force a full reconcile (including reloading the assets) without
+	// having to edit the spec. The annotation is removed once handled.
+	reconcileNowAnnotation = "nfd.kubernetes.io/reconcile-now"
+
+	// baseRetryDelay and maxRetryDelay bound the exponential backoff used
+	// when a reconcile fails. Capping the delay makes sure a degraded CR
+	// recovers shortly after the underlying issue (e.g., a missing
+	// permission or an image that couldn't be pulled) is fixed.
+	baseRetryDelay = 500 * time.Millisecond
+	maxRetryDelay  = 60 * time.Second
+
+	// defaultAssetsDir holds the assets in the operator image
+	defaultAssetsDir = "/opt/nfd"
+)
 
 // NodeFeatureDiscoveryReconciler reconciles a NodeFeatureDiscovery object
 type NodeFeatureDiscoveryReconciler struct {
@@ -62,8 +92,47 @@ type NodeFeatureDiscoveryReconciler struct {
 	// field is needed by the operator in order for the operator to write events.
 	Recorder record.EventRecorder
 
-	// AssetsDir defines the directory with assets under the operator image
-	AssetsDir string
+	// HeartbeatInterval is the interval between two updates of the
+	// LastHeartbeatTime of the conditions [defaults to 5m]
+	HeartbeatInterval time.Duration
+
+	// resumed records the CRs reconciled at least once by this operator
+	// instance, see resumeApply
+	resumed map[types.NamespacedName]bool
+
+	// reconciles counts the reconciles of the CRs not reported in their
+	// status yet, see recordReconcile
+	reconciles map[types.NamespacedName]int64
+
+	// waits counts the consecutive reconciles of the CRs that waited for
+	// their resources to be ready, see notReadyDelay
+	waits map[types.NamespacedName]int
+
+	// nodes summarizes the nodes of the cluster, see nodeSummary
+	nodes *nodeSummary
+
+	// triggers records what triggered the reconciles, see triggerTracker
+	triggers *triggerTracker
+
+	// labelDrifts records the feature labels removed or changed on the
+	// nodes outside of NFD, see labelDrifts
+	labelDrifts *labelDrifts
+
+	// cleanupLimiters rate limit the node updates of the CRs being
+	// deleted, see cleanupLimiter
+	cleanupLimiters map[types.NamespacedName]*qpsLimiter
+
+	// sender POSTs the notifications and the telemetry reports in the
+	// background, see sender
+	sender *sender
+
+	// Assets provides the manifests of the operand resources. The assets
+	// under /opt/nfd in the operator image are used if not set.
+	Assets deployment.AssetsProvider
+
+	// Platform tells which platform specific APIs the cluster serves, as
+	// detected at startup
+	Platform deployment.Platform
 }
 
 // SetupWithManager sets up the controller with a specified manager responsible for
@@ -79,19 +148,174 @@ func (r *NodeFeatureDiscoveryReconciler) SetupWithManager(mgr ctrl.Manager) erro
 		},
 	}
 
+	// Keep the node counts up to date from the node events, rather
+	// than going through all the nodes whenever they're needed
+	informer, err := mgr.GetCache().GetInformer(context.TODO(), &corev1.Node{})
+	if err != nil {
+		return err
+	}
+	r.nodes = newNodeSummary()
+	r.nodes.synced = informer.HasSynced
+	informer.AddEventHandler(r.nodes)
+
+	// The notifications and the telemetry reports are sent outside of
+	// the reconciles
+	r.sender = newSender()
+	if err := mgr.Add(r.sender); err != nil {
+		return err
+	}
+
+	// The events are recorded as the cause of the reconciles they
+	// trigger. The owned objects are watched like "Owns" does, with the
+	// handler wrapped to record the events.
+	r.triggers = newTriggerTracker()
+	r.labelDrifts = newLabelDrifts()
+	owned := func(kind string) handler.EventHandler {
+		return r.triggers.handler(kind, &handler.EnqueueRequestForOwner{
+			OwnerType:    &nfdv1.NodeFeatureDiscovery{},
+			IsController: true,
+		})
+	}
+
 	// Create a new controller.  "For" specifies the type of object being
-	// reconciled whereas "Owns" specify the types of objects being
-	// generated and "Complete" specifies the reconciler object.
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&nfdv1.NodeFeatureDiscovery{}).
-		Owns(&appsv1.DaemonSet{}, builder.WithPredicates(p)).
-		Owns(&corev1.Service{}, builder.WithPredicates(p)).
-		Owns(&corev1.ServiceAccount{}, builder.WithPredicates(p)).
-		Owns(&corev1.Pod{}, builder.WithPredicates(p)).
-		Owns(&corev1.ConfigMap{}, builder.WithPredicates(p)).
+	// reconciled whereas the owned objects are the ones being generated
+	// and "Complete" specifies the reconciler object. The operand Pods
+	// are owned by their DaemonSets rather than by the CR, so they are
+	// mapped back to the CR explicitly in order to notice e.g. an image
+	// becoming pullable. The same goes for the ConfigMaps provided by
+	// the user and the secrets holding the operand certificates, whose
+	// metadata only is cached, as their rotations are all that matters, and for
+	// the CRs waiting for another one to free their instance, for the
+	// nodes whose feature labels were removed or changed outside of NFD,
+	// for the cluster-scoped RBAC and SecurityContextConstraints, which
+	// can't be owned by the CR, being deleted, for the namespaces whose
+	// label namespace policy changes, and for the OpenShift cluster proxy
+	// config the operands connect through.
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&nfdv1.NodeFeatureDiscovery{}, builder.WithPredicates(r.triggers.predicate("NodeFeatureDiscovery"))).
+		Watches(&source.Kind{Type: &appsv1.DaemonSet{}}, owned("DaemonSet"), builder.WithPredicates(p)).
+		Watches(&source.Kind{Type: &appsv1.Deployment{}}, owned("Deployment"), builder.WithPredicates(p)).
+		Watches(&source.Kind{Type: &policyv1beta1.PodDisruptionBudget{}}, owned("PodDisruptionBudget"), builder.WithPredicates(p)).
+		Watches(&source.Kind{Type: &corev1.Service{}}, owned("Service"), builder.WithPredicates(p)).
+		Watches(&source.Kind{Type: &corev1.ServiceAccount{}}, owned("ServiceAccount"), builder.WithPredicates(p)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, owned("ConfigMap"), builder.WithPredicates(p)).
+		Watches(&source.Kind{Type: &corev1.Pod{}},
+			r.triggers.handler("Pod", handler.EnqueueRequestsFromMapFunc(r.operandPodToRequests)),
+			builder.WithPredicates(p)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}},
+			r.triggers.handler("ConfigMap", handler.EnqueueRequestsFromMapFunc(r.configMapToRequests)),
+			builder.WithPredicates(p)).
+		Watches(&source.Kind{Type: &corev1.Secret{}},
+			r.triggers.handler("Secret", handler.EnqueueRequestsFromMapFunc(r.secretToRequests)),
+			builder.OnlyMetadata, builder.WithPredicates(p)).
+		Watches(&source.Kind{Type: &nfdv1.NodeFeatureDiscovery{}},
+			r.triggers.handler("NodeFeatureDiscovery", handler.EnqueueRequestsFromMapFunc(r.conflictToRequests))).
+		Watches(&source.Kind{Type: &corev1.Node{}},
+			r.triggers.handler("Node", r.labelDriftHandler()),
+			builder.WithPredicates(featureLabelsDrifted)).
+		Watches(&source.Kind{Type: &rbacv1.ClusterRole{}},
+			r.triggers.handler("ClusterRole", handler.EnqueueRequestsFromMapFunc(r.clusterScopedToRequests)),
+			builder.WithPredicates(clusterScopedDeleted)).
+		Watches(&source.Kind{Type: &rbacv1.ClusterRoleBinding{}},
+			r.triggers.handler("ClusterRoleBinding", handler.EnqueueRequestsFromMapFunc(r.clusterScopedToRequests)),
+			builder.WithPredicates(clusterScopedDeleted)).
+		Watches(&source.Kind{Type: &corev1.Namespace{}},
+			r.triggers.handler("Namespace", handler.EnqueueRequestsFromMapFunc(r.namespaceToRequests)),
+			builder.WithPredicates(labelNsPolicyChanged))
+	if r.Platform.SecurityContextConstraints {
+		b = b.Watches(&source.Kind{Type: &secv1.SecurityContextConstraints{}},
+			r.triggers.handler("SecurityContextConstraints", handler.EnqueueRequestsFromMapFunc(r.clusterScopedToRequests)),
+			builder.WithPredicates(clusterScopedDeleted))
+	}
+	if r.Platform.ClusterProxy {
+		b = b.Watches(&source.Kind{Type: clusterProxy()},
+			r.triggers.handler("Proxy", handler.EnqueueRequestsFromMapFunc(r.proxyToRequests)),
+			builder.WithPredicates(proxyStatusChanged))
+	}
+	return b.
+		WithOptions(controller.Options{
+			RateLimiter: workqueue.NewItemExponentialFailureRateLimiter(baseRetryDelay, maxRetryDelay),
+		}).
 		Complete(r)
 }
 
+// operandPodToRequests maps an operand Pod to reconcile requests for the
+// NodeFeatureDiscovery CRs living in the same namespace.
+func (r *NodeFeatureDiscoveryReconciler) operandPodToRequests(obj client.Object) []reconcile.Request {
+	switch obj.GetLabels()["app"] {
+	case "nfd-master", "nfd-worker", "nfd-topology-updater", "nfd-gc":
+	default:
+		return nil
+	}
+
+	nfdList := &nfdv1.NodeFeatureDiscoveryList{}
+	if err := r.List(context.TODO(), nfdList, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "could not list NodeFeatureDiscovery objects", "Namespace", obj.GetNamespace())
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, i := range nfdList.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: i.Namespace, Name: i.Name},
+		})
+	}
+	return requests
+}
+
+// configMapToRequests maps a user provided ConfigMap to reconcile requests
+// for the NodeFeatureDiscovery CRs referencing it, so that changes to the
+// configuration are rolled out.
+func (r *NodeFeatureDiscoveryReconciler) configMapToRequests(obj client.Object) []reconcile.Request {
+	nfdList := &nfdv1.NodeFeatureDiscoveryList{}
+	if err := r.List(context.TODO(), nfdList, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "could not list NodeFeatureDiscovery objects", "Namespace", obj.GetNamespace())
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, i := range nfdList.Items {
+		if !referencesConfigMap(i.Spec.WorkerConfig.ConfigMapRef, obj.GetName()) &&
+			!referencesConfigMap(i.Spec.MasterConfig.ConfigMapRef, obj.GetName()) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: i.Namespace, Name: i.Name},
+		})
+	}
+	return requests
+}
+
+// secretToRequests maps a secret holding operand certificates to reconcile
+// requests for the NodeFeatureDiscovery CRs mounting it, so that rotated
+// certificates are rolled out.
+func (r *NodeFeatureDiscoveryReconciler) secretToRequests(obj client.Object) []reconcile.Request {
+	nfdList := &nfdv1.NodeFeatureDiscoveryList{}
+	if err := r.List(context.TODO(), nfdList, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "could not list NodeFeatureDiscovery objects", "Namespace", obj.GetNamespace())
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for i := range nfdList.Items {
+		for _, name := range deployment.TLSSecretNames(&nfdList.Items[i]) {
+			if name != obj.GetName() {
+				continue
+			}
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: nfdList.Items[i].Namespace, Name: nfdList.Items[i].Name},
+			})
+			break
+		}
+	}
+	return requests
+}
+
+// referencesConfigMap returns true if ref refers to the named ConfigMap
+func referencesConfigMap(ref *nfdv1.ConfigMapReference, name string) bool {
+	return ref != nil && ref.Name == name
+}
+
 // validateUpdateEvent looks at an update event and returns true or false
 // depending on whether the update event has runtime objects to update.
 func validateUpdateEvent(e *event.UpdateEvent) bool {
@@ -107,44 +331,53 @@ func validateUpdateEvent(e *event.UpdateEvent) bool {
 	return true
 }
 
+// The operator needs to hold every permission it grants to the operands
+// (e.g. pods and nodes update for nfd-master), as RBAC prevents privilege
+// escalation. Permissions only needed by optional features, like managing
+// SecurityContextConstraints on OpenShift, are not listed here but in the
+// aggregated ClusterRoles under config/rbac.
 // +kubebuilder:rbac:groups=nfd.kubernetes.io,resources=nodefeaturediscoveries,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=nfd.kubernetes.io,resources=nodefeaturediscoveries/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=nfd.kubernetes.io,resources=nodefeaturediscoveries/finalizers,verbs=update
-// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=core,resources=pods/log,verbs=get
-// +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;patch;update;delete
+// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;patch;update
+// +kubebuilder:rbac:groups=core,resources=nodes/status,verbs=patch;update
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;create;patch
 // +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// The secrets are listed and watched for their metadata only, their data
+// being read with uncached gets, see main.go.
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch;create;update;delete
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=core,resources=imagestreams/layers,verbs=get
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterrolebindings,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=core,resources=events,verbs=list;watch;create;update;patch
-// +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;update;
-// +kubebuilder:rbac:groups=core,resources=persistentvolumes,verbs=get;list;watch;create;delete
-// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch;create;update;delete
-// +kubebuilder:rbac:groups=storage.k8s.io,resources=csinodes,verbs=get;list;watch
-// +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=watch
-// +kubebuilder:rbac:groups=storage.k8s.io,resources=csidrivers,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=core,resources=endpoints,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheusrules,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims
 // to move the current state of the cluster closer to the desired state.
 func (r *NodeFeatureDiscoveryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.Log.Info("Reconcile triggered", "nodefeaturediscovery", req.NamespacedName, "trigger", r.triggers.take(req.NamespacedName))
+
+	result, err := r.reconcileInstance(ctx, req)
+	r.triggers.done(req.NamespacedName, err)
+	return result, err
+}
+
+// reconcileInstance reconciles the NodeFeatureDiscovery CR of the request
+func (r *NodeFeatureDiscoveryReconciler) reconcileInstance(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	_ = r.Log.WithValues("nodefeaturediscovery", req.NamespacedName)
 
 	// Fetch the NodeFeatureDiscovery instance on the cluster
 	r.Log.Info("Fetch the NodeFeatureDiscovery instance")
 	instance := &nfdv1.NodeFeatureDiscovery{}
-	err := r.Get(ctx, req.NamespacedName, instance)
+	err = r.Get(ctx, req.NamespacedName, instance)
 
 	// If an error occurs because "r.Get" cannot get the NFD instance
 	// (e.g., due to timeouts, aborts, etc. defined by ctx), the
@@ -156,6 +389,10 @@ func (r *NodeFeatureDiscoveryReconciler) Reconcile(ctx context.Context, req ctrl
 			// Owned objects are automatically garbage collected. For additional cleanup
 			// logic use finalizers. Return and don't requeue.
 			r.Log.Info("resource has been deleted", "req", req.Name, "got", instance.Name)
+			r.triggers.forget(req.NamespacedName)
+			r.labelDrifts.forget(req.NamespacedName)
+			delete(r.reconciles, req.NamespacedName)
+			delete(r.waits, req.NamespacedName)
 			return ctrl.Result{Requeue: false}, nil
 		}
 
@@ -163,19 +400,283 @@ func (r *NodeFeatureDiscoveryReconciler) Reconcile(ctx context.Context, req ctrl
 		return ctrl.Result{Requeue: true}, err
 	}
 
+	// Tell why a busy CR keeps being reconciled
+	r.reportTriggers(instance)
+
+	// Notify the sinks of the conditions this reconcile changes
+	previous := append([]conditionsv1.Condition(nil), instance.Status.Conditions...)
+	defer r.notifyTransitions(ctx, instance, previous)
+
+	// Reflect a reconcile that didn't complete in the conditions. This
+	// runs before the notification above, so that it's notified too.
+	defer func() {
+		if err == nil || !instance.GetDeletionTimestamp().IsZero() {
+			return
+		}
+		if sErr := r.reportReconcileError(ctx, instance, err); sErr != nil {
+			r.Log.Error(sErr, "Couldn't update the conditions")
+		}
+	}()
+
+	// Tell whether the CR is being worked on, and how it's going
+	defer func() {
+		if !instance.GetDeletionTimestamp().IsZero() {
+			return
+		}
+		if sErr := r.recordReconcile(ctx, instance, err); sErr != nil {
+			r.Log.Error(sErr, "Couldn't record the reconcile statistics")
+		}
+	}()
+
+	// If the object is being deleted, clean up the nodes before letting
+	// it go. Otherwise make sure the finalizer is in place.
+	if !instance.GetDeletionTimestamp().IsZero() {
+		return r.finalizeNFD(ctx, instance)
+	}
+	// Only one CR may deploy an instance, the other ones would fight over
+	// its resources and node labels. They're left alone, without the
+	// finalizer, until the instance is free again.
+	owner, err := r.instanceOwner(ctx, instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if owner != nil {
+		return ctrl.Result{}, r.rejectConflict(ctx, instance, owner)
+	}
+
+	if !controllerutil.ContainsFinalizer(instance, nfdFinalizer) {
+		controllerutil.AddFinalizer(instance, nfdFinalizer)
+		if err := r.Update(ctx, instance); err != nil {
+			return ctrl.Result{Requeue: true}, err
+		}
+	}
+
+	// A paused CR is left as is, so that its operands can be patched by
+	// hand, but the status still tells why nothing happens
+	if instance.Spec.Paused {
+		r.Log.Info("Reconciliation is paused")
+		delete(r.waits, req.NamespacedName)
+		if err := r.reportSuspended(ctx, instance, reasonPaused, pausedMessage); err != nil {
+			return ctrl.Result{}, err
+		}
+		next, err := r.heartbeat(ctx, instance)
+		return ctrl.Result{RequeueAfter: next}, err
+	}
+
+	// Unmanaged operands are left alone as well, and removed ones are
+	// deleted without deleting the CR
+	switch instance.Spec.ManagementState {
+	case nfdv1.Unmanaged:
+		r.Log.Info("Operands are unmanaged")
+		delete(r.waits, req.NamespacedName)
+		if err := r.reportSuspended(ctx, instance, reasonUnmanaged, unmanagedMessage); err != nil {
+			return ctrl.Result{}, err
+		}
+		next, err := r.heartbeat(ctx, instance)
+		return ctrl.Result{RequeueAfter: next}, err
+	case nfdv1.Removed:
+		delete(r.waits, req.NamespacedName)
+		return r.removeOperands(ctx, instance)
+	}
+
+	// A manual "reconcile-now" request drops the cached assets so that
+	// they're read again from disk, and then removes the annotation so
+	// that it can be set again later on.
+	if _, ok := instance.GetAnnotations()[reconcileNowAnnotation]; ok {
+		r.Log.Info("Manual reconcile requested", "annotation", reconcileNowAnnotation)
+		nfd.Reset()
+
+		patch := client.MergeFrom(instance.DeepCopy())
+		delete(instance.Annotations, reconcileNowAnnotation)
+		if err := r.Patch(ctx, instance, patch); err != nil {
+			return ctrl.Result{Requeue: true}, err
+		}
+	}
+
+	// Make sure the CR only uses the label namespaces its tenant is
+	// allowed to publish labels in. A violation is only fixed by
+	// changing the CR or the policy, both of which trigger a reconcile.
+	isolated, violation, err := r.checkLabelNsPolicy(ctx, instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if violation != "" {
+		r.Log.Info("Label namespace policy violation", "reason", violation)
+		return ctrl.Result{}, r.rejectLabelNsPolicy(ctx, instance, violation)
+	}
+
+	// Make sure admission defaults don't keep the operands from being
+	// scheduled
+	if err := r.checkNamespaceScheduling(ctx, instance); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	r.Log.Info("Ready to apply components")
-	nfd.init(r, instance)
+	if r.Assets == nil {
+		r.Assets = deployment.NewDirAssets(defaultAssetsDir)
+	}
+	loaded := nfd.Loaded()
+	if err := nfd.Init(r.Client, r.Scheme, r.Assets, r.Platform, instance); err != nil {
+		r.Log.Error(err, "Couldn't load the assets")
+		return ctrl.Result{}, err
+	}
+	if !loaded {
+		r.reportAssetsLoaded(instance)
+	}
+	if isolated {
+		nfd.IsolateLabelNs()
+	}
+
+	// Surface the worker configuration errors first, as the failing
+	// workers keep the apply below from completing
+	if err := r.checkWorkerConfig(ctx, instance); err != nil {
+		return ctrl.Result{}, err
+	}
 
 	// Run through all control functions, return an error on any NotReady resource.
+	// The operator keeps beating while a resource isn't ready, as
+	// it's still making progress. The state being applied is recorded
+	// so that another operator instance can resume from there.
+	r.resumeApply(instance)
 	for {
-		err := nfd.step()
+		err := nfd.Step()
 		if err != nil {
+			r.reportDrift(instance)
+			r.reportAdoptions(instance)
+			r.reportChanges(instance)
+			if cErr := r.reportComponents(ctx, instance); cErr != nil {
+				r.Log.Error(cErr, "Couldn't report the operand readiness")
+			}
+			if pErr := r.recordApplyProgress(ctx, instance, nfd.State()); pErr != nil {
+				r.Log.Error(pErr, "Couldn't record the rollout progress")
+			}
+
+			// Waiting for resources to come up isn't an error: report
+			// it in the conditions and check again later on
+			if errors.Is(err, deployment.ErrResourceNotReady) {
+				if wErr := r.reportWaiting(ctx, instance, err); wErr != nil {
+					return reconcile.Result{}, wErr
+				}
+				if _, hbErr := r.heartbeat(ctx, instance); hbErr != nil {
+					r.Log.Error(hbErr, "Couldn't update the condition heartbeats")
+				}
+				delay := r.notReadyDelay(req.NamespacedName)
+				r.Log.Info("Waiting for the resources to be ready", "state", nfd.State(), "resource", err.Error(), "requeueAfter", delay)
+				return reconcile.Result{RequeueAfter: delay}, nil
+			}
+
+			if _, hbErr := r.heartbeat(ctx, instance); hbErr != nil {
+				r.Log.Error(hbErr, "Couldn't update the condition heartbeats")
+			}
 			return reconcile.Result{}, err
 		}
-		if nfd.last() {
+		if nfd.Last() {
 			break
 		}
 	}
+	delete(r.waits, req.NamespacedName)
+	r.reportDrift(instance)
+	r.reportAdoptions(instance)
+	r.reportChanges(instance)
+	if err := r.reportComponents(ctx, instance); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.recordApplyProgress(ctx, instance, applyCompleted); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Record the hash of the rendered operand objects, unless the
+	// rollout was resumed and didn't render them all
+	if hash := nfd.ManifestHash(); hash != "" && hash != instance.Status.ManifestHash {
+		instance.Status.ManifestHash = hash
+		if err := r.Status().Update(ctx, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// All the operands have been applied, report whether they're ready
+	if err := r.reportApplied(ctx, instance); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// nfd-master now runs without tainting, if it was turned off, so
+	// the taints it set before can be removed
+	if !instance.Spec.EnableTaints {
+		if err := r.removeFeatureTaints(ctx, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Report the discovery coverage of each platform
+	if err := r.updateCoverage(ctx, instance); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Report whether nfd-topology-updater publishes the node topologies
+	if err := r.updateTopologyStatus(ctx, instance); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Publish the telemetry report, if the user opted in
+	if instance.Spec.Telemetry.Enabled {
+		r.reportTelemetry(ctx, instance)
+	}
+
+	// Check that the rollout labels a node before reporting the
+	// operands available, if requested
+	result, err = r.verifyRollout(ctx, instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Publish the features to the node group ConfigMaps, if requested
+	published, err := r.publishNodeGroups(ctx, instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if published.RequeueAfter != 0 && (result.RequeueAfter == 0 || published.RequeueAfter < result.RequeueAfter) {
+		result.RequeueAfter = published.RequeueAfter
+	}
+
+	// Report the feature labels other instances also publish
+	if err := r.checkLabelOwnership(ctx, instance); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Have the feature labels removed or changed outside of NFD
+	// published again
+	if err := r.remediateLabelDrift(ctx, instance); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Verify the node labels periodically, if requested
+	if instance.Spec.IntegrityCheck.Enabled {
+		integrity, err := r.checkLabelIntegrity(ctx, instance)
+		if err != nil {
+			return integrity, err
+		}
+		if result.RequeueAfter == 0 || integrity.RequeueAfter < result.RequeueAfter {
+			result.RequeueAfter = integrity.RequeueAfter
+		}
+	}
+
+	// The spec of this generation is now fully reconciled
+	if instance.Status.ObservedGeneration != instance.GetGeneration() {
+		instance.Status.ObservedGeneration = instance.GetGeneration()
+		if err := r.Status().Update(ctx, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Come back for the next heartbeat, unless something else is due
+	// earlier
+	next, err := r.heartbeat(ctx, instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if result.RequeueAfter == 0 || next < result.RequeueAfter {
+		result.RequeueAfter = next
+	}
 
-	return ctrl.Result{}, nil
+	return result, nil
 }
@@ -0,0 +1,65 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// updateCoverage reports the discovery coverage of the cluster, and of
+// each platform, in the status of the CR, so that e.g. Windows nodes left
+// out of a mixed fleet stand out. The status is only updated when the
+// coverage changed.
+func (r *NodeFeatureDiscoveryReconciler) updateCoverage(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) error {
+	counts, err := r.nodeCounts(ctx)
+	if err != nil {
+		return err
+	}
+
+	coverage := []nfdv1.PlatformCoverage{}
+	for p, c := range counts.Platforms {
+		coverage = append(coverage, nfdv1.PlatformCoverage{
+			OS:           p.os,
+			Arch:         p.arch,
+			Nodes:        c.Nodes,
+			LabeledNodes: c.Labeled,
+		})
+	}
+	sort.Slice(coverage, func(i, j int) bool {
+		if coverage[i].OS != coverage[j].OS {
+			return coverage[i].OS < coverage[j].OS
+		}
+		return coverage[i].Arch < coverage[j].Arch
+	})
+	if len(coverage) == 0 {
+		coverage = nil
+	}
+
+	if ins.Status.NodeCount == counts.Nodes && ins.Status.LabeledNodes == counts.Labeled &&
+		equality.Semantic.DeepEqual(ins.Status.Coverage, coverage) {
+		return nil
+	}
+	ins.Status.NodeCount = counts.Nodes
+	ins.Status.LabeledNodes = counts.Labeled
+	ins.Status.Coverage = coverage
+	return r.Status().Update(ctx, ins)
+}
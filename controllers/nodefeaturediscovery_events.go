@@ -0,0 +1,103 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// Reasons of the events reporting the progress of the reconciles
+const (
+	reasonAssetsLoaded      = "AssetsLoaded"
+	reasonApplied           = "Applied"
+	reasonComponentReady    = "ComponentReady"
+	reasonComponentNotReady = "ComponentNotReady"
+	reasonRecovered         = "Recovered"
+)
+
+// event logs a transition of the CR and records it as a Normal Event
+func (r *NodeFeatureDiscoveryReconciler) event(ins *nfdv1.NodeFeatureDiscovery, reason, message string) {
+	r.Log.Info(message, "reason", reason)
+	if r.Recorder != nil {
+		r.Recorder.Event(ins, corev1.EventTypeNormal, reason, message)
+	}
+}
+
+// warn logs a warning about the CR and records it as an Event
+func (r *NodeFeatureDiscoveryReconciler) warn(ins *nfdv1.NodeFeatureDiscovery, reason, message string) {
+	r.Log.Info(message, "reason", reason)
+	if r.Recorder != nil {
+		r.Recorder.Event(ins, corev1.EventTypeWarning, reason, message)
+	}
+}
+
+// reportAssetsLoaded records an event listing the states read from the
+// assets, on the CR whose reconcile read them
+func (r *NodeFeatureDiscoveryReconciler) reportAssetsLoaded(ins *nfdv1.NodeFeatureDiscovery) {
+	r.event(ins, reasonAssetsLoaded, fmt.Sprintf("loaded the assets of states %s", strings.Join(nfd.States(), ", ")))
+}
+
+// reportChanges records an event for each state whose objects this
+// reconcile created or updated, listing them
+func (r *NodeFeatureDiscoveryReconciler) reportChanges(ins *nfdv1.NodeFeatureDiscovery) {
+	changes := nfd.Changes()
+	states := make([]string, 0, len(changes))
+	for state := range changes {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+	for _, state := range states {
+		r.event(ins, reasonApplied, fmt.Sprintf("state %s: %s", state, strings.Join(changes[state], ", ")))
+	}
+}
+
+// reportComponentTransition records an event when an operand becomes
+// ready, or stops being ready. found is the status of the operand before
+// this reconcile, if any.
+func (r *NodeFeatureDiscoveryReconciler) reportComponentTransition(ins *nfdv1.NodeFeatureDiscovery, name string, found *nfdv1.ComponentStatus, status nfdv1.ComponentStatus) {
+	if found != nil && found.Ready == status.Ready {
+		return
+	}
+	if status.Ready {
+		r.event(ins, reasonComponentReady, fmt.Sprintf("component %s is ready", name))
+		return
+	}
+	r.event(ins, reasonComponentNotReady, fmt.Sprintf("component %s is not ready: %s", name, status.Message))
+}
+
+// reportConditionTransition records a warning event when the CR becomes
+// degraded, or degraded for another reason, and an event once it's no
+// longer degraded. found is the condition before the change, if any.
+func (r *NodeFeatureDiscoveryReconciler) reportConditionTransition(ins *nfdv1.NodeFeatureDiscovery, found *conditionsv1.Condition, cond conditionsv1.Condition) {
+	if cond.Type != conditionsv1.ConditionDegraded {
+		return
+	}
+	wasDegraded := found != nil && found.Status == corev1.ConditionTrue
+	switch {
+	case cond.Status == corev1.ConditionTrue && (!wasDegraded || found.Reason != cond.Reason):
+		r.warn(ins, cond.Reason, cond.Message)
+	case cond.Status != corev1.ConditionTrue && wasDegraded:
+		r.event(ins, reasonRecovered, fmt.Sprintf("no longer degraded, was: %s", found.Reason))
+	}
+}
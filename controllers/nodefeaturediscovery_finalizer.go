@@ -0,0 +1,667 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+)
+
+const (
+	// nfdFinalizer is the finalizer that makes sure the nodes are
+	// cleaned up before a NodeFeatureDiscovery object goes away
+	nfdFinalizer = "nfd.kubernetes.io/finalizer"
+
+	// defaultCleanupConcurrency is the default maximum number of nodes
+	// updated in parallel during cleanup
+	defaultCleanupConcurrency = nfdv1.DefaultCleanupConcurrency
+
+	// defaultCleanupQPS is the default maximum number of node updates
+	// per second during cleanup
+	defaultCleanupQPS = nfdv1.DefaultCleanupQPS
+
+	// cleanupBatchSize is the number of nodes cleaned up per reconcile.
+	// The progress is saved in the status after each batch.
+	cleanupBatchSize = 100
+
+	// nfdAnnotationNs is the namespace of the node annotations of
+	// nfd-master, prefixed with "<instance>." for a named instance
+	nfdAnnotationNs = "nfd.node.kubernetes.io/"
+
+	// extendedResourcesAnnotation is the node annotation nfd-master
+	// records the names of the extended resources it advertised in
+	extendedResourcesAnnotation = nfdAnnotationNs + "extended-resources"
+)
+
+const (
+	// cleanupReportPrefix is the prefix of the name of the ConfigMap
+	// listing the nodes that weren't cleaned up, followed by the name
+	// of the NodeFeatureDiscovery object
+	cleanupReportPrefix = "nfd-cleanup-report-"
+
+	// cleanupReportKey is the ConfigMap key holding the report
+	cleanupReportKey = "report.json"
+
+	// maxReportedNodes bounds the number of node names in the cleanup
+	// report, so that it fits in a ConfigMap on any cluster
+	maxReportedNodes = 1000
+)
+
+// cleanupReport describes a cleanup that timed out
+type cleanupReport struct {
+	DeletionTimestamp  metav1.Time     `json:"deletionTimestamp"`
+	Timeout            metav1.Duration `json:"timeout"`
+	UncleanedNodeCount int             `json:"uncleanedNodeCount"`
+
+	// UncleanedNodes lists the first maxReportedNodes nodes still
+	// having labels of the instance
+	UncleanedNodes []string `json:"uncleanedNodes"`
+}
+
+// operandLeases are the names of the coordination Leases used for the
+// leader election between the nfd-master replicas
+var operandLeases = []string{deployment.MasterLeaseName}
+
+// finalizeNFD tears the instance down: it stops nfd-worker, and
+// nfd-master if it would label the nodes again, runs the prune Job and
+// removes the NFD labels left on the nodes, one batch per call, then
+// deletes nfd-master and the cluster-scoped resources, and removes the
+// finalizer. With the Retain deletion policy, the operands are
+// orphaned instead.
+func (r *NodeFeatureDiscoveryReconciler) finalizeNFD(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(ins, nfdFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	// The cleanup timeout doesn't apply to Retain, which never deletes
+	// the operands
+	if ins.Spec.DeletionPolicy == nfdv1.DeletionPolicyRetain {
+		return ctrl.Result{}, r.retainOperands(ctx, ins)
+	}
+
+	// Give up on the cleanup once it's been going on for too long, so
+	// that the object doesn't hang in Terminating forever
+	if timeout := ins.Spec.Cleanup.Timeout; timeout != nil {
+		deadline := ins.GetDeletionTimestamp().Add(timeout.Duration)
+		if !time.Now().Before(deadline) {
+			return ctrl.Result{}, r.abandonCleanup(ctx, ins)
+		}
+	}
+
+	// The node labels and annotations of the instance are still
+	// published by the CR deploying it, if another one does
+	inUse, err := r.instanceInUse(ctx, ins)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// The teardown is ordered so that the nodes aren't left half
+	// labelled: the operands publishing the labels are stopped first,
+	// otherwise they would label the nodes again, then the nodes are
+	// cleaned up, and the RBAC goes last
+	if err := r.reportTeardown(ctx, ins, teardownStoppingWorkers); err != nil {
+		return ctrl.Result{}, err
+	}
+	stopped, err := r.stopPublishers(ctx, ins, inUse)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !stopped {
+		return ctrl.Result{RequeueAfter: teardownPollInterval}, nil
+	}
+
+	if inUse {
+		r.Log.Info("Instance still deployed by another NodeFeatureDiscovery object, skipping the node cleanup")
+	} else {
+		// nfd-master knows best what it published, have it remove it
+		// before checking the nodes
+		if pruneNeeded(ins) {
+			if err := r.reportTeardown(ctx, ins, teardownPruning); err != nil {
+				return ctrl.Result{}, err
+			}
+			done, err := r.runPrune(ctx, ins)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if !done {
+				return ctrl.Result{RequeueAfter: prunePollInterval}, nil
+			}
+		}
+
+		if err := r.reportTeardown(ctx, ins, teardownCleaningNodes); err != nil {
+			return ctrl.Result{}, err
+		}
+		done, err := r.cleanupNodes(ctx, ins)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !done {
+			return ctrl.Result{Requeue: true}, nil
+		}
+	}
+
+	if err := r.reportTeardown(ctx, ins, teardownRemovingMaster); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.deleteOperands(ctx, ins, operandWorkloads...); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.deleteLeases(ctx, ins); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.deleteClusterScoped(ctx, ins); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	r.Log.Info("Node cleanup done, removing finalizer")
+	r.forgetCleanupLimiter(ins)
+	controllerutil.RemoveFinalizer(ins, nfdFinalizer)
+	if err := r.Update(ctx, ins); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.notifyEvent(ctx, ins, notificationCleanupCompleted, "the NFD labels were removed from all the nodes")
+	return ctrl.Result{}, nil
+}
+
+// retainOperands orphans the operand resources, so that NFD keeps
+// running and the nodes keep their labels once the NodeFeatureDiscovery
+// object is gone, and removes the finalizer. The cluster-scoped resources
+// aren't owned by the object, so they're left in place as well.
+func (r *NodeFeatureDiscoveryReconciler) retainOperands(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) error {
+	if r.Assets == nil {
+		r.Assets = deployment.NewDirAssets(defaultAssetsDir)
+	}
+	if err := nfd.Init(r.Client, r.Scheme, r.Assets, r.Platform, ins); err != nil {
+		return err
+	}
+	if err := nfd.OrphanOperands(); err != nil {
+		return err
+	}
+
+	// The ConfigMaps the controller creates itself, e.g. the ones of the
+	// node groups, aren't part of the assets
+	configMaps := &corev1.ConfigMapList{}
+	if err := r.List(ctx, configMaps, client.InNamespace(ins.GetNamespace())); err != nil {
+		return err
+	}
+	for i := range configMaps.Items {
+		cm := &configMaps.Items[i]
+		if !metav1.IsControlledBy(cm, ins) {
+			continue
+		}
+		if err := deployment.RemoveOwnerReference(ctx, r.Client, ins, cm); err != nil {
+			return err
+		}
+	}
+
+	r.Log.Info("Operands orphaned, removing finalizer")
+	controllerutil.RemoveFinalizer(ins, nfdFinalizer)
+	if err := r.Update(ctx, ins); err != nil {
+		return err
+	}
+	r.notifyEvent(ctx, ins, notificationOperandsRetained, "the operands were left running, as spec.deletionPolicy is Retain")
+	return nil
+}
+
+// abandonCleanup removes the finalizer before the cleanup is done. The
+// nodes still having labels of the instance are listed in a report ConfigMap, which
+// is not owned by the NodeFeatureDiscovery object so that it outlives it.
+func (r *NodeFeatureDiscoveryReconciler) abandonCleanup(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) error {
+	// Don't leave running operands or privileged RBAC behind, but don't
+	// let them hold up the deletion either. The operands promised to be
+	// retained are never deleted.
+	if ins.Spec.DeletionPolicy != nfdv1.DeletionPolicyRetain {
+		if err := r.deleteOperands(ctx, ins, operandWorkloads...); err != nil {
+			r.Log.Error(err, "Couldn't delete the operands")
+		}
+		if err := r.deleteClusterScoped(ctx, ins); err != nil {
+			r.Log.Error(err, "Couldn't delete the cluster-scoped resources")
+		}
+	}
+	if err := r.deletePrune(ctx, ins); err != nil {
+		r.Log.Error(err, "Couldn't delete the prune Job")
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return err
+	}
+	blanket, err := r.soleInstance(ctx, ins)
+	if err != nil {
+		return err
+	}
+	report := cleanupReport{
+		DeletionTimestamp: *ins.GetDeletionTimestamp(),
+		Timeout:           *ins.Spec.Cleanup.Timeout,
+		UncleanedNodes:    []string{},
+	}
+	sort.Slice(nodes.Items, func(i, j int) bool {
+		return nodes.Items[i].Name < nodes.Items[j].Name
+	})
+	for _, node := range nodes.Items {
+		if len(instanceLabels(&node, ins, blanket)) == 0 {
+			continue
+		}
+		report.UncleanedNodeCount++
+		if len(report.UncleanedNodes) < maxReportedNodes {
+			report.UncleanedNodes = append(report.UncleanedNodes, node.Name)
+		}
+	}
+
+	name := cleanupReportPrefix + ins.GetName()
+	if err := r.writeCleanupReport(ctx, ins.GetNamespace(), name, &report); err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("cleanup timed out after %s, %d nodes still have labels of the instance, see ConfigMap %s",
+		report.Timeout.Duration, report.UncleanedNodeCount, name)
+	r.warn(ins, "CleanupTimedOut", msg)
+
+	r.forgetCleanupLimiter(ins)
+	controllerutil.RemoveFinalizer(ins, nfdFinalizer)
+	if err := r.Update(ctx, ins); err != nil {
+		return err
+	}
+	r.notifyEvent(ctx, ins, notificationCleanupTimedOut, msg)
+	return nil
+}
+
+// writeCleanupReport creates or replaces the cleanup report ConfigMap
+func (r *NodeFeatureDiscoveryReconciler) writeCleanupReport(ctx context.Context, namespace, name string, report *cleanupReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	obj := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Data:       map[string]string{cleanupReportKey: string(data)},
+	}
+	found := &corev1.ConfigMap{}
+	err = r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, found)
+	if errors.IsNotFound(err) {
+		return r.Create(ctx, obj)
+	} else if err != nil {
+		return err
+	}
+	obj.ResourceVersion = found.ResourceVersion
+	return r.Update(ctx, obj)
+}
+
+// deleteOperands deletes the workloads of the given operands, e.g.
+// "nfd-worker", whether they're DaemonSets or Deployments, as nfd-master
+// is a DaemonSet on older installs, unless they're controlled by another
+// NodeFeatureDiscovery object deploying the same instance
+func (r *NodeFeatureDiscoveryReconciler) deleteOperands(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, names ...string) error {
+	operands := []client.Object{}
+	for _, name := range names {
+		meta := metav1.ObjectMeta{Name: deployment.InstanceName(ins, name), Namespace: ins.GetNamespace()}
+		operands = append(operands, &appsv1.DaemonSet{ObjectMeta: meta}, &appsv1.Deployment{ObjectMeta: meta})
+	}
+	for _, obj := range operands {
+		err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if !metav1.IsControlledBy(obj, ins) {
+			continue
+		}
+		if err := r.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteLeases deletes the leader election Leases of nfd-master, so that
+// they don't hold up the deletion of the namespace or confuse a future
+// install. The Leases aren't per instance, so they're kept as long as
+// another NFD instance lives in the same namespace.
+func (r *NodeFeatureDiscoveryReconciler) deleteLeases(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) error {
+	list := &nfdv1.NodeFeatureDiscoveryList{}
+	if err := r.List(ctx, list, client.InNamespace(ins.GetNamespace())); err != nil {
+		return err
+	}
+	for _, other := range list.Items {
+		if other.GetUID() != ins.GetUID() && other.GetDeletionTimestamp() == nil {
+			return nil
+		}
+	}
+
+	for _, name := range operandLeases {
+		lease := &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ins.GetNamespace()},
+		}
+		if err := r.Delete(ctx, lease); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteClusterScoped deletes the ClusterRoles, ClusterRoleBindings and
+// SecurityContextConstraints created for the instance, which the
+// NodeFeatureDiscovery object can't own. Their names only depend on
+// spec.instance, so they're kept as long as another NodeFeatureDiscovery
+// object, in any namespace, uses the same instance name.
+func (r *NodeFeatureDiscoveryReconciler) deleteClusterScoped(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) error {
+	if inUse, err := r.instanceInUse(ctx, ins); err != nil || inUse {
+		return err
+	}
+
+	if r.Assets == nil {
+		r.Assets = deployment.NewDirAssets(defaultAssetsDir)
+	}
+	if err := nfd.Init(r.Client, r.Scheme, r.Assets, r.Platform, ins); err != nil {
+		return err
+	}
+	return nfd.DeleteClusterScoped()
+}
+
+// cleanupNodes removes the NFD labels, annotations and extended resources
+// from the next batch of nodes and records the progress in the status. It
+// returns true once all nodes have been processed.
+func (r *NodeFeatureDiscoveryReconciler) cleanupNodes(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) (bool, error) {
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return false, err
+	}
+	sort.Slice(nodes.Items, func(i, j int) bool {
+		return nodes.Items[i].Name < nodes.Items[j].Name
+	})
+
+	progress := &nfdv1.CleanupStatus{}
+	if ins.Status.Cleanup != nil {
+		progress = ins.Status.Cleanup.DeepCopy()
+	}
+
+	// Resume after the last node that was cleaned up
+	batch := []string{}
+	for _, node := range nodes.Items {
+		if node.Name <= progress.LastNode {
+			continue
+		}
+		batch = append(batch, node.Name)
+		if len(batch) == cleanupBatchSize {
+			break
+		}
+	}
+	if len(batch) == 0 {
+		return true, nil
+	}
+
+	concurrency := ins.Spec.Cleanup.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultCleanupConcurrency
+	}
+	limiter := r.cleanupLimiter(ins)
+
+	blanket, err := r.soleInstance(ctx, ins)
+	if err != nil {
+		return false, err
+	}
+
+	r.Log.Info("Cleaning up nodes", "first", batch[0], "count", len(batch))
+
+	// Clean up the batch using a bounded number of workers
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := []error{}
+	names := make(chan string)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range names {
+				if err := r.cleanupNode(ctx, ins, name, blanket, limiter); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, name := range batch {
+		names <- name
+	}
+	close(names)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return false, utilerrors.NewAggregate(errs)
+	}
+
+	progress.LastNode = batch[len(batch)-1]
+	progress.CleanedNodes += len(batch)
+	progress.TotalNodes = len(nodes.Items)
+	ins.Status.Cleanup = progress
+
+	return false, r.Status().Update(ctx, ins)
+}
+
+// jsonPatchOp is a single JSON patch (RFC 6902) operation
+type jsonPatchOp struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+}
+
+// qpsLimiter is a rate limiter along with the rate it was created for
+type qpsLimiter struct {
+	flowcontrol.RateLimiter
+	qps int
+}
+
+// cleanupLimiter returns the rate limiter of the node updates of the
+// cleanup of the given CR, which is kept across the batches, and thus the
+// reconciles, so that the rate isn't exceeded by the burst of a new
+// limiter. It's replaced if spec.cleanup.qps changes.
+func (r *NodeFeatureDiscoveryReconciler) cleanupLimiter(ins *nfdv1.NodeFeatureDiscovery) flowcontrol.RateLimiter {
+	qps := ins.Spec.Cleanup.QPS
+	if qps <= 0 {
+		qps = defaultCleanupQPS
+	}
+
+	if r.cleanupLimiters == nil {
+		r.cleanupLimiters = map[types.NamespacedName]*qpsLimiter{}
+	}
+	key := types.NamespacedName{Namespace: ins.GetNamespace(), Name: ins.GetName()}
+	if l, ok := r.cleanupLimiters[key]; ok && l.qps == qps {
+		return l
+	}
+	r.forgetCleanupLimiter(ins)
+	l := &qpsLimiter{RateLimiter: flowcontrol.NewTokenBucketRateLimiter(float32(qps), qps), qps: qps}
+	r.cleanupLimiters[key] = l
+	return l
+}
+
+// forgetCleanupLimiter drops the rate limiter of the cleanup of the given
+// CR, once its finalizer is removed
+func (r *NodeFeatureDiscoveryReconciler) forgetCleanupLimiter(ins *nfdv1.NodeFeatureDiscovery) {
+	key := types.NamespacedName{Namespace: ins.GetNamespace(), Name: ins.GetName()}
+	if l, ok := r.cleanupLimiters[key]; ok {
+		l.Stop()
+		delete(r.cleanupLimiters, key)
+	}
+}
+
+// cleanupNode removes from the given node the labels the instance
+// published, all the NFD labels if blanket is set, the annotations of the
+// instance, and the extended resources it advertised. Only those fields
+// are touched, using JSON patches, so that the cleanup doesn't conflict
+// with the kubelet or other controllers updating the node. Each patch
+// waits for the limiter, the node being read from the cache.
+func (r *NodeFeatureDiscoveryReconciler) cleanupNode(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, name string, blanket bool, limiter flowcontrol.RateLimiter) error {
+	// A field may vanish between reading the node and patching it, in
+	// which case the patch is rejected as invalid and has to be rebuilt
+	return retry.OnError(retry.DefaultRetry, errors.IsInvalid, func() error {
+		node := &corev1.Node{}
+		if err := r.Get(ctx, types.NamespacedName{Name: name}, node); err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+
+		// The extended resources are listed in an annotation, so they
+		// go first
+		ops := []jsonPatchOp{}
+		for _, res := range nfdAnnotationList(node, ins, extendedResourcesAnnotation) {
+			for _, field := range []string{"capacity", "allocatable"} {
+				list := node.Status.Capacity
+				if field == "allocatable" {
+					list = node.Status.Allocatable
+				}
+				if _, ok := list[corev1.ResourceName(res)]; ok {
+					ops = append(ops, jsonPatchOp{Op: "remove", Path: "/status/" + field + "/" + escapeJSONPointer(res)})
+				}
+			}
+		}
+		if err := r.patchNode(ctx, node, ops, true, limiter); err != nil {
+			return err
+		}
+
+		ops = []jsonPatchOp{}
+		for _, k := range instanceLabels(node, ins, blanket) {
+			ops = append(ops, jsonPatchOp{Op: "remove", Path: "/metadata/labels/" + escapeJSONPointer(k)})
+		}
+		prefix := nfdAnnotationPrefix(ins)
+		for k := range node.Annotations {
+			if strings.HasPrefix(k, prefix) {
+				ops = append(ops, jsonPatchOp{Op: "remove", Path: "/metadata/annotations/" + escapeJSONPointer(k)})
+			}
+		}
+		return r.patchNode(ctx, node, ops, false, limiter)
+	})
+}
+
+// instanceLabels returns the labels of the node published by the
+// instance, as listed in its feature-labels annotation, along with all the
+// feature.node.kubernetes.io ones if blanket is set
+func instanceLabels(node *corev1.Node, ins *nfdv1.NodeFeatureDiscovery, blanket bool) []string {
+	published := map[string]bool{}
+	for _, label := range nfdAnnotationList(node, ins, featureLabelsAnnotation) {
+		published[label] = true
+	}
+	labels := []string{}
+	for k := range node.Labels {
+		if published[k] || (blanket && strings.HasPrefix(k, featureLabelPrefix)) {
+			labels = append(labels, k)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// soleInstance returns true if no other NodeFeatureDiscovery object
+// deploys NFD in the cluster, in which case all the feature labels are
+// the ones of ins, even those missing from its feature-labels annotation.
+// Otherwise, they may be published by another instance, and only the
+// listed ones are removed.
+func (r *NodeFeatureDiscoveryReconciler) soleInstance(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) (bool, error) {
+	list := &nfdv1.NodeFeatureDiscoveryList{}
+	if err := r.List(ctx, list); err != nil {
+		return false, err
+	}
+	for _, other := range list.Items {
+		if other.GetUID() != ins.GetUID() && other.GetDeletionTimestamp() == nil {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// patchNode applies the JSON patch operations, if any, to the node or to
+// its status, once the limiter allows it
+func (r *NodeFeatureDiscoveryReconciler) patchNode(ctx context.Context, node *corev1.Node, ops []jsonPatchOp, status bool, limiter flowcontrol.RateLimiter) error {
+	if len(ops) == 0 {
+		return nil
+	}
+	limiter.Accept()
+	data, err := json.Marshal(ops)
+	if err != nil {
+		return err
+	}
+
+	patch := client.RawPatch(types.JSONPatchType, data)
+	if status {
+		err = r.Status().Patch(ctx, node, patch)
+	} else {
+		err = r.Patch(ctx, node, patch)
+	}
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// nfdAnnotationPrefix returns the prefix of the node annotations of the
+// nfd-master instance
+func nfdAnnotationPrefix(ins *nfdv1.NodeFeatureDiscovery) string {
+	if ins.Spec.Instance == "" {
+		return nfdAnnotationNs
+	}
+	return ins.Spec.Instance + "." + nfdAnnotationNs
+}
+
+// nfdAnnotationList returns the names listed in the given node annotation,
+// as named for the default nfd-master instance, of the nfd-master instance.
+// The names without namespace are in the default feature.node.kubernetes.io
+// one.
+func nfdAnnotationList(node *corev1.Node, ins *nfdv1.NodeFeatureDiscovery, annotation string) []string {
+	if ins.Spec.Instance != "" {
+		annotation = ins.Spec.Instance + "." + annotation
+	}
+	names := []string{}
+	for _, name := range strings.Split(node.Annotations[annotation], ",") {
+		if name == "" {
+			continue
+		}
+		if !strings.Contains(name, "/") {
+			name = featureLabelPrefix + name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// escapeJSONPointer escapes a string for use as a JSON pointer (RFC 6901)
+// reference token
+func escapeJSONPointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+)
+
+var _ = Describe("NodeFeatureDiscovery finalizer", func() {
+	const namespace = "nfd-finalizer"
+
+	var (
+		ctx  context.Context
+		r    *NodeFeatureDiscoveryReconciler
+		node *corev1.Node
+	)
+
+	// newInstance creates a NodeFeatureDiscovery object having the
+	// finalizer, without pruning, as no Job runs in the test environment
+	newInstance := func(name string) *nfdv1.NodeFeatureDiscovery {
+		prune := false
+		ins := &nfdv1.NodeFeatureDiscovery{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Finalizers: []string{nfdFinalizer}},
+			Spec: nfdv1.NodeFeatureDiscoverySpec{
+				Cleanup: nfdv1.CleanupSpec{Prune: &prune},
+			},
+		}
+		Expect(k8sClient.Create(ctx, ins)).To(Succeed())
+		return ins
+	}
+
+	// deleteInstance deletes the object, and returns it as left
+	// terminating by the finalizer
+	deleteInstance := func(ins *nfdv1.NodeFeatureDiscovery) *nfdv1.NodeFeatureDiscovery {
+		Expect(k8sClient.Delete(ctx, ins)).To(Succeed())
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ins), ins)).To(Succeed())
+		Expect(ins.GetDeletionTimestamp()).NotTo(BeNil())
+		return ins
+	}
+
+	// finalize runs the finalizer until the object is gone
+	finalize := func(ins *nfdv1.NodeFeatureDiscovery) {
+		for i := 0; i < 5; i++ {
+			_, err := r.finalizeNFD(ctx, ins)
+			Expect(err).NotTo(HaveOccurred())
+			err = k8sClient.Get(ctx, client.ObjectKeyFromObject(ins), ins)
+			if errors.IsNotFound(err) {
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Fail("the finalizer wasn't removed")
+	}
+
+	nodeLabels := func() map[string]string {
+		found := &corev1.Node{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: node.Name}, found)).To(Succeed())
+		return found.Labels
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		assets := &deployment.MemoryAssets{}
+		assets.Add("worker", []byte("apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: nfd-worker\n"))
+		r = &NodeFeatureDiscoveryReconciler{
+			Client: k8sClient,
+			Log:    logf.Log.WithName("finalizer-test"),
+			Scheme: scheme.Scheme,
+			Assets: assets,
+		}
+
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+		if err := k8sClient.Create(ctx, ns); err != nil && !errors.IsAlreadyExists(err) {
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		// The node has a label published by the instance, as listed in
+		// its annotation, and one that isn't listed
+		node = &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "nfd-finalizer-node",
+				Labels: map[string]string{
+					featureLabelPrefix + "cpu-cpuid.AVX": "true",
+					featureLabelPrefix + "custom":        "true",
+				},
+				Annotations: map[string]string{
+					featureLabelsAnnotation: "cpu-cpuid.AVX",
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, node)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, node))).To(Succeed())
+		list := &nfdv1.NodeFeatureDiscoveryList{}
+		Expect(k8sClient.List(ctx, list, client.InNamespace(namespace))).To(Succeed())
+		for i := range list.Items {
+			ins := &list.Items[i]
+			ins.Finalizers = nil
+			Expect(client.IgnoreNotFound(k8sClient.Update(ctx, ins))).To(Succeed())
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, ins))).To(Succeed())
+		}
+	})
+
+	It("stops nfd-worker before cleaning up the nodes", func() {
+		ins := deleteInstance(newInstance("nfd-ordering"))
+
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "nfd-worker-x", Namespace: namespace, Labels: map[string]string{"app": "nfd-worker"}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "nfd-worker", Image: "nfd"}}},
+		}
+		Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+
+		res, err := r.finalizeNFD(ctx, ins)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(teardownPollInterval))
+		Expect(nodeLabels()).To(HaveKey(featureLabelPrefix + "cpu-cpuid.AVX"))
+
+		Expect(k8sClient.Delete(ctx, pod, client.GracePeriodSeconds(0))).To(Succeed())
+		finalize(ins)
+		Expect(nodeLabels()).To(BeEmpty())
+	})
+
+	It("stops nfd-master before running the prune Job", func() {
+		ins := newInstance("nfd-prune-order")
+		ins.Spec.Cleanup.Prune = nil
+		Expect(k8sClient.Update(ctx, ins)).To(Succeed())
+
+		labels := map[string]string{"app": "nfd-master"}
+		master := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "nfd-master", Namespace: namespace},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "nfd-master", Image: "nfd"}}},
+				},
+			},
+		}
+		Expect(controllerutil.SetControllerReference(ins, master, scheme.Scheme)).To(Succeed())
+		Expect(k8sClient.Create(ctx, master)).To(Succeed())
+
+		ins = deleteInstance(ins)
+		res, err := r.finalizeNFD(ctx, ins)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(prunePollInterval))
+
+		err = k8sClient.Get(ctx, client.ObjectKeyFromObject(master), &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		job := &batchv1.Job{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: deployment.PruneName(ins)}, job)).To(Succeed())
+		Expect(r.deletePrune(ctx, ins)).To(Succeed())
+	})
+
+	It("only removes the labels of the deleted instance", func() {
+		other := newInstance("nfd-other")
+		other.Spec.Instance = "other"
+		Expect(k8sClient.Update(ctx, other)).To(Succeed())
+
+		ins := deleteInstance(newInstance("nfd-deleted"))
+		finalize(ins)
+
+		labels := nodeLabels()
+		Expect(labels).NotTo(HaveKey(featureLabelPrefix + "cpu-cpuid.AVX"))
+		Expect(labels).To(HaveKey(featureLabelPrefix + "custom"))
+	})
+
+	It("retains the operands even after the cleanup timeout", func() {
+		ins := newInstance("nfd-retain")
+		ins.Spec.DeletionPolicy = nfdv1.DeletionPolicyRetain
+		ins.Spec.Cleanup.Timeout = &metav1.Duration{}
+		Expect(k8sClient.Update(ctx, ins)).To(Succeed())
+
+		finalize(deleteInstance(ins))
+
+		Expect(nodeLabels()).To(HaveLen(2))
+		err := k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: cleanupReportPrefix + ins.Name}, &corev1.ConfigMap{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("reports the nodes left labelled once the cleanup times out", func() {
+		ins := newInstance("nfd-timeout")
+		ins.Spec.Cleanup.Timeout = &metav1.Duration{}
+		Expect(k8sClient.Update(ctx, ins)).To(Succeed())
+
+		finalize(deleteInstance(ins))
+
+		Expect(nodeLabels()).To(HaveLen(2))
+		cm := &corev1.ConfigMap{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: cleanupReportPrefix + ins.Name}, cm)).To(Succeed())
+		report := cleanupReport{}
+		Expect(json.Unmarshal([]byte(cm.Data[cleanupReportKey]), &report)).To(Succeed())
+		Expect(report.UncleanedNodes).To(Equal([]string{node.Name}))
+		Expect(k8sClient.Delete(ctx, cm)).To(Succeed())
+	})
+})
@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+const (
+	// defaultHeartbeatInterval is used if the reconciler doesn't define
+	// a heartbeat interval
+	defaultHeartbeatInterval = 5 * time.Minute
+
+	// heartbeatJitter spreads the heartbeats of the CRs over up to 20%
+	// of the interval, so that they don't all hit the API server at once
+	heartbeatJitter = 0.2
+)
+
+// heartbeat refreshes the LastHeartbeatTime of the conditions of the CR
+// once the previous heartbeat is older than the heartbeat interval, so that
+// a stale heartbeat tells a wedged operator apart from an idle one. It
+// returns the delay after which the CR should be reconciled again for the
+// next heartbeat.
+func (r *NodeFeatureDiscoveryReconciler) heartbeat(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) (time.Duration, error) {
+	interval := r.HeartbeatInterval
+	if interval == 0 {
+		interval = defaultHeartbeatInterval
+	}
+	next := wait.Jitter(interval, heartbeatJitter)
+
+	if len(ins.Status.Conditions) == 0 || !heartbeatDue(ins, interval) {
+		return next, nil
+	}
+
+	now := metav1.Now()
+	for i := range ins.Status.Conditions {
+		ins.Status.Conditions[i].LastHeartbeatTime = now
+	}
+	return next, r.Status().Update(ctx, ins)
+}
+
+// heartbeatDue returns true if the oldest heartbeat of the conditions is
+// older than the interval. Heartbeats too far in the future, e.g. written
+// by an operator running on a node with a skewed clock, are refreshed too.
+func heartbeatDue(ins *nfdv1.NodeFeatureDiscovery, interval time.Duration) bool {
+	for _, c := range ins.Status.Conditions {
+		age := time.Since(c.LastHeartbeatTime.Time)
+		if age >= interval || age < -interval {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+const (
+	// featureLabelsAnnotation is the node annotation nfd-master records
+	// the names of the labels it published in. Labels in the default
+	// feature.node.kubernetes.io namespace are listed without prefix.
+	featureLabelsAnnotation = nfdAnnotationNs + "feature-labels"
+
+	// defaultIntegrityCheckInterval and defaultIntegrityCheckSampleSize
+	// are used if the CR doesn't define them
+	defaultIntegrityCheckInterval   = nfdv1.DefaultIntegrityCheckInterval
+	defaultIntegrityCheckSampleSize = nfdv1.DefaultIntegrityCheckSampleSize
+
+	// maxReportedDiscrepancies bounds the number of nodes listed in the
+	// status, to keep the CR small on large clusters
+	maxReportedDiscrepancies = 10
+)
+
+// checkLabelIntegrity verifies the labels of a random sample of nodes, if
+// the previous check is older than the configured interval, and records
+// the result in the status. The returned result requeues the CR for the
+// next check.
+func (r *NodeFeatureDiscoveryReconciler) checkLabelIntegrity(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) (ctrl.Result, error) {
+	interval := defaultIntegrityCheckInterval
+	if ins.Spec.IntegrityCheck.Interval != nil {
+		interval = ins.Spec.IntegrityCheck.Interval.Duration
+	}
+
+	// Don't check more often than requested, e.g. when reconciling
+	// because of a change of an operand
+	if last := ins.Status.Integrity; last != nil {
+		if wait := time.Until(last.LastCheckTime.Add(interval)); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	sampleSize := defaultIntegrityCheckSampleSize
+	if ins.Spec.IntegrityCheck.SampleSize > 0 {
+		sampleSize = ins.Spec.IntegrityCheck.SampleSize
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Only the nodes labeled by nfd-master can be verified
+	annotation := featureLabelsAnnotationName(ins.Spec.Instance)
+	labeled := []corev1.Node{}
+	for _, node := range nodes.Items {
+		if _, ok := node.Annotations[annotation]; ok {
+			labeled = append(labeled, node)
+		}
+	}
+	rand.Shuffle(len(labeled), func(i, j int) { labeled[i], labeled[j] = labeled[j], labeled[i] })
+	if len(labeled) > sampleSize {
+		labeled = labeled[:sampleSize]
+	}
+
+	status := &nfdv1.IntegrityStatus{
+		LastCheckTime: metav1.Now(),
+		CheckedNodes:  len(labeled),
+	}
+	for _, node := range labeled {
+		d := nodeLabelDiscrepancy(&node, annotation)
+		if d == nil {
+			continue
+		}
+		r.Log.Info("Node labels don't match the ones published by nfd-master", "node", d.Node,
+			"missing", d.MissingLabels, "unexpected", d.UnexpectedLabels)
+		status.DiscrepantNodes++
+		if len(status.Discrepancies) < maxReportedDiscrepancies {
+			status.Discrepancies = append(status.Discrepancies, *d)
+		}
+	}
+
+	ins.Status.Integrity = status
+	if err := r.Status().Update(ctx, ins); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// featureLabelsAnnotationName returns the name of the feature labels
+// annotation of the given nfd-master instance
+func featureLabelsAnnotationName(instance string) string {
+	if instance == "" {
+		return featureLabelsAnnotation
+	}
+	return instance + "." + featureLabelsAnnotation
+}
+
+// nodeLabelDiscrepancy compares the feature labels of the node with the
+// ones listed in the given annotation, and returns nil if they match
+func nodeLabelDiscrepancy(node *corev1.Node, annotation string) *nfdv1.NodeLabelDiscrepancy {
+	published := map[string]bool{}
+	for _, name := range strings.Split(node.Annotations[annotation], ",") {
+		if name == "" {
+			continue
+		}
+		if !strings.Contains(name, "/") {
+			name = featureLabelPrefix + name
+		}
+		published[name] = true
+	}
+
+	d := &nfdv1.NodeLabelDiscrepancy{Node: node.Name}
+	for name := range published {
+		if _, ok := node.Labels[name]; !ok {
+			d.MissingLabels = append(d.MissingLabels, name)
+		}
+	}
+	for name := range node.Labels {
+		if strings.HasPrefix(name, featureLabelPrefix) && !published[name] {
+			d.UnexpectedLabels = append(d.UnexpectedLabels, name)
+		}
+	}
+
+	if len(d.MissingLabels) == 0 && len(d.UnexpectedLabels) == 0 {
+		return nil
+	}
+	sort.Strings(d.MissingLabels)
+	sort.Strings(d.UnexpectedLabels)
+	return d
+}
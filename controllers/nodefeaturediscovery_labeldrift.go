@@ -0,0 +1,279 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+)
+
+const (
+	// reasonFeatureLabelsDrifted is the reason of the events reporting
+	// the feature labels removed or changed outside of NFD
+	reasonFeatureLabelsDrifted = "FeatureLabelsDrifted"
+
+	// nfdMasterManager is the field manager of the node labels set by
+	// nfd-master
+	nfdMasterManager = "nfd-master"
+
+	// minWorkerRestartAge is the minimum age of the nfd-worker pod of a
+	// node restarted to restore its labels, so that a controller
+	// fighting over the labels doesn't keep the worker restarting
+	minWorkerRestartAge = 5 * time.Minute
+)
+
+// labelDrifts records the feature labels removed or changed on the nodes
+// outside of NFD, by CR, until the reconcile of the CR remediates them.
+// They're recorded by the node event handler, and taken by the reconcile.
+type labelDrifts struct {
+	// mu protects the field below
+	mu sync.Mutex
+
+	// drifts holds the published value of the drifted labels, by CR,
+	// node and label
+	drifts map[types.NamespacedName]map[string]map[string]string
+}
+
+// newLabelDrifts returns an empty drift record
+func newLabelDrifts() *labelDrifts {
+	return &labelDrifts{drifts: map[types.NamespacedName]map[string]map[string]string{}}
+}
+
+// add records the drifted labels of a node for a CR
+func (d *labelDrifts) add(key types.NamespacedName, node string, labels map[string]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.drifts[key] == nil {
+		d.drifts[key] = map[string]map[string]string{}
+	}
+	if d.drifts[key][node] == nil {
+		d.drifts[key][node] = map[string]string{}
+	}
+	for name, value := range labels {
+		d.drifts[key][node][name] = value
+	}
+}
+
+// take returns the drifted labels recorded for a CR, by node, and forgets
+// about them
+func (d *labelDrifts) take(key types.NamespacedName) map[string]map[string]string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	drifts := d.drifts[key]
+	delete(d.drifts, key)
+	return drifts
+}
+
+// forget drops the drifted labels recorded for a deleted CR
+func (d *labelDrifts) forget(key types.NamespacedName) {
+	d.take(key)
+}
+
+// driftedFeatureLabels returns the feature labels published by nfd-master
+// on the old node that the new node lacks, or that were changed by
+// another manager than nfd-master, with their published value, by feature
+// labels annotation, i.e. by NFD instance. The labels listed in an
+// annotation that changed too were updated by nfd-master itself.
+func driftedFeatureLabels(old, node *corev1.Node) map[string]map[string]string {
+	drifts := map[string]map[string]string{}
+	for annotation, list := range old.Annotations {
+		if annotation != featureLabelsAnnotation && !strings.HasSuffix(annotation, "."+featureLabelsAnnotation) {
+			continue
+		}
+		if node.Annotations[annotation] != list {
+			continue
+		}
+
+		for _, name := range strings.Split(list, ",") {
+			if name == "" {
+				continue
+			}
+			if !strings.Contains(name, "/") {
+				name = featureLabelPrefix + name
+			}
+			published, ok := old.Labels[name]
+			if !ok {
+				continue
+			}
+			value, ok := node.Labels[name]
+			if ok && (value == published || labelManager(node, name) == nfdMasterManager) {
+				continue
+			}
+			if drifts[annotation] == nil {
+				drifts[annotation] = map[string]string{}
+			}
+			drifts[annotation][name] = published
+		}
+	}
+	return drifts
+}
+
+// labelManager returns the field manager owning the given label of the
+// node, or "" if it can't be told
+func labelManager(node *corev1.Node, label string) string {
+	for _, entry := range node.ManagedFields {
+		if entry.FieldsV1 == nil {
+			continue
+		}
+		fields := map[string]map[string]map[string]interface{}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		if _, ok := fields["f:metadata"]["f:labels"]["f:"+label]; ok {
+			return entry.Manager
+		}
+	}
+	return ""
+}
+
+// featureLabelsDrifted passes the node updates removing or changing
+// feature labels published by nfd-master, see driftedFeatureLabels
+var featureLabelsDrifted = predicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		old, ok := e.ObjectOld.(*corev1.Node)
+		if !ok {
+			return false
+		}
+		node, ok := e.ObjectNew.(*corev1.Node)
+		if !ok {
+			return false
+		}
+		return len(driftedFeatureLabels(old, node)) > 0
+	},
+}
+
+// labelDriftHandler records the feature labels drifted by a node update
+// for the CRs deploying the NFD instances that published them, and
+// enqueues the CRs
+func (r *NodeFeatureDiscoveryReconciler) labelDriftHandler() handler.EventHandler {
+	return handler.Funcs{
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			old, ok := e.ObjectOld.(*corev1.Node)
+			if !ok {
+				return
+			}
+			node, ok := e.ObjectNew.(*corev1.Node)
+			if !ok {
+				return
+			}
+			drifts := driftedFeatureLabels(old, node)
+			if len(drifts) == 0 {
+				return
+			}
+
+			list := &nfdv1.NodeFeatureDiscoveryList{}
+			if err := r.List(context.TODO(), list); err != nil {
+				r.Log.Error(err, "could not list NodeFeatureDiscovery objects")
+				return
+			}
+			for _, i := range list.Items {
+				labels, ok := drifts[featureLabelsAnnotationName(i.Spec.Instance)]
+				if !ok || i.GetDeletionTimestamp() != nil {
+					continue
+				}
+				key := client.ObjectKeyFromObject(&i)
+				r.labelDrifts.add(key, node.Name, labels)
+				q.Add(reconcile.Request{NamespacedName: key})
+			}
+		},
+	}
+}
+
+// remediateLabelDrift restarts the nfd-worker pods of the nodes whose
+// feature labels were removed or changed outside of NFD, so that their
+// features are published again. The nodes whose labels were restored in
+// the meantime are left alone, as are the workers restarted recently.
+func (r *NodeFeatureDiscoveryReconciler) remediateLabelDrift(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) error {
+	drifts := r.labelDrifts.take(client.ObjectKeyFromObject(ins))
+	if len(drifts) == 0 {
+		return nil
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(ins.GetNamespace()), deployment.PodSelector(ins, "nfd-worker")); err != nil {
+		return err
+	}
+	workers := map[string]*corev1.Pod{}
+	for i := range pods.Items {
+		workers[pods.Items[i].Spec.NodeName] = &pods.Items[i]
+	}
+
+	nodes := make([]string, 0, len(drifts))
+	for name := range drifts {
+		nodes = append(nodes, name)
+	}
+	sort.Strings(nodes)
+	for _, name := range nodes {
+		node := &corev1.Node{}
+		err := r.Get(ctx, types.NamespacedName{Name: name}, node)
+		if k8serrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		drifted := []string{}
+		for label, published := range drifts[name] {
+			value, ok := node.Labels[label]
+			if !ok || (value != published && labelManager(node, label) != nfdMasterManager) {
+				drifted = append(drifted, label)
+			}
+		}
+		if len(drifted) == 0 {
+			continue
+		}
+		sort.Strings(drifted)
+
+		pod, ok := workers[name]
+		if !ok || pod.GetDeletionTimestamp() != nil {
+			continue
+		}
+		if age := time.Since(pod.CreationTimestamp.Time); age < minWorkerRestartAge {
+			r.Log.Info("Feature labels drifted, but nfd-worker was restarted recently", "node", name, "pod", pod.Name, "labels", drifted)
+			continue
+		}
+		if err := r.Delete(ctx, pod); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+		r.warn(ins, reasonFeatureLabelsDrifted, fmt.Sprintf("feature labels of node %s removed or changed outside of NFD, restarted %s to publish them again: %s",
+			name, pod.Name, strings.Join(drifted, ", ")))
+	}
+	return nil
+}
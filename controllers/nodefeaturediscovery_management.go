@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+)
+
+// Reasons of the conditions, and of the events, reporting the operands
+// the operator doesn't manage
+const (
+	reasonUnmanaged = "Unmanaged"
+	reasonRemoved   = "Removed"
+
+	unmanagedMessage = "the operands are unmanaged, they aren't reconciled until spec.managementState is Managed again"
+	removedMessage   = "the operands are removed, they are deployed again once spec.managementState is Managed"
+)
+
+// removeOperands deletes the operand resources of the CR, while keeping the
+// CR and the node labels, and reports it in the conditions, and in an event
+// when the operands get removed. The status of the operands is cleared.
+func (r *NodeFeatureDiscoveryReconciler) removeOperands(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) (ctrl.Result, error) {
+	if r.Assets == nil {
+		r.Assets = deployment.NewDirAssets(defaultAssetsDir)
+	}
+	if err := nfd.Init(r.Client, r.Scheme, r.Assets, r.Platform, ins); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := nfd.DeleteOperands(); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.deleteLeases(ctx, ins); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if clearOperandStatus(&ins.Status) {
+		if err := r.Status().Update(ctx, ins); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	found := conditionsv1.FindStatusCondition(ins.Status.Conditions, conditionsv1.ConditionAvailable)
+	if found == nil || found.Reason != reasonRemoved {
+		r.event(ins, reasonRemoved, removedMessage)
+	}
+	if err := r.setConditions(ctx, ins,
+		condition(conditionsv1.ConditionAvailable, false, reasonRemoved, removedMessage),
+		condition(conditionsv1.ConditionProgressing, false, reasonRemoved, removedMessage),
+		condition(conditionsv1.ConditionDegraded, false, reasonRemoved, ""),
+		condition(conditionsv1.ConditionUpgradeable, true, reasonAsExpected, "")); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	next, err := r.heartbeat(ctx, ins)
+	return ctrl.Result{RequeueAfter: next}, err
+}
+
+// clearOperandStatus drops the fields of the status describing the
+// operands, and returns true if any was set
+func clearOperandStatus(s *nfdv1.NodeFeatureDiscoveryStatus) bool {
+	if s.OperandVersion == "" && s.OperandImage == "" && s.ManifestHash == "" && s.Components == nil &&
+		s.Master == nil && s.Worker == nil && s.TopologyUpdater == nil {
+		return false
+	}
+	s.OperandVersion = ""
+	s.OperandImage = ""
+	s.ManifestHash = ""
+	s.Components = nil
+	s.Master = nil
+	s.Worker = nil
+	s.TopologyUpdater = nil
+	return true
+}
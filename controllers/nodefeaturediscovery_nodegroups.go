@@ -0,0 +1,268 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/yaml"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+)
+
+const (
+	// nodeFeatureNodeLabel is the label nfd-worker sets on a NodeFeature
+	// object with the name of its node
+	nodeFeatureNodeLabel = "nfd.node.kubernetes.io/node-name"
+
+	// nodeGroupConfigMapLabel marks the ConfigMaps holding the features
+	// of a node group, and nodeGroupAnnotation holds the value of the
+	// node label of the group
+	nodeGroupConfigMapLabel = "nfd.kubernetes.io/node-group-features"
+	nodeGroupAnnotation     = "nfd.kubernetes.io/node-group"
+
+	// defaultNodeGroupLabel and defaultPublishingInterval are used if
+	// the CR doesn't define them
+	defaultNodeGroupLabel     = nfdv1.DefaultNodeGroupLabel
+	defaultPublishingInterval = nfdv1.DefaultPublishingInterval
+
+	// ungroupedNodes names the ConfigMap of the nodes without the node
+	// group label
+	ungroupedNodes = "ungrouped"
+
+	// groupLabelsKey holds the labels shared by all the nodes of a
+	// group, and the labels of each node are under nodeLabelsKeyPrefix
+	// followed by the node name
+	groupLabelsKey      = "group.yaml"
+	nodeLabelsKeyPrefix = "node."
+)
+
+// nodeFeatureListGVK is the kind of the list of NodeFeature objects. The
+// NodeFeature API is only read through unstructured objects, as its CRD
+// may not be installed.
+var nodeFeatureListGVK = schema.GroupVersionKind{Group: "nfd.k8s-sigs.io", Version: "v1alpha1", Kind: "NodeFeatureList"}
+
+// invalidNameChars matches the characters not allowed in a ConfigMap name
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// publishNodeGroups aggregates the labels requested by nfd-worker in the
+// NodeFeature objects into a ConfigMap per node group, when the features
+// are published to ConfigMaps instead of node labels. The NodeFeature
+// objects aren't watched, as their CRD may come and go, so the ConfigMaps
+// are refreshed periodically. The ConfigMaps of the groups that are gone,
+// or of all groups once the mode is turned off, are deleted.
+func (r *NodeFeatureDiscoveryReconciler) publishNodeGroups(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) (ctrl.Result, error) {
+	if ins.Spec.Publishing.Mode != nfdv1.PublishNodeGroupConfigMaps {
+		return ctrl.Result{}, r.pruneNodeGroupConfigMaps(ctx, ins, nil)
+	}
+	if !ins.Spec.EnableNodeFeatureAPI {
+		r.Log.Info("Publishing to ConfigMaps requires enableNodeFeatureApi, skipping")
+		return ctrl.Result{}, nil
+	}
+
+	groupLabel := ins.Spec.Publishing.NodeGroupLabel
+	if groupLabel == "" {
+		groupLabel = defaultNodeGroupLabel
+	}
+	interval := defaultPublishingInterval
+	if ins.Spec.Publishing.Interval != nil {
+		interval = ins.Spec.Publishing.Interval.Duration
+	}
+
+	features := &unstructured.UnstructuredList{}
+	features.SetGroupVersionKind(nodeFeatureListGVK)
+	if err := r.List(ctx, features, client.InNamespace(ins.GetNamespace())); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Merge the labels of the NodeFeature objects of each node, and
+	// group the nodes
+	groups := map[string]map[string]map[string]string{}
+	for _, f := range features.Items {
+		nodeName := f.GetLabels()[nodeFeatureNodeLabel]
+		if nodeName == "" {
+			nodeName = f.GetName()
+		}
+		node := &corev1.Node{}
+		if err := r.Get(ctx, types.NamespacedName{Name: nodeName}, node); errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		labels, _, err := unstructured.NestedStringMap(f.Object, "spec", "labels")
+		if err != nil {
+			r.Log.Info("Invalid NodeFeature labels, skipping", "NodeFeature", f.GetName(), "error", err.Error())
+			continue
+		}
+		group := node.Labels[groupLabel]
+		if groups[group] == nil {
+			groups[group] = map[string]map[string]string{}
+		}
+		if groups[group][nodeName] == nil {
+			groups[group][nodeName] = map[string]string{}
+		}
+		for k, v := range labels {
+			groups[group][nodeName][k] = v
+		}
+	}
+
+	keep := map[string]bool{}
+	for group, nodes := range groups {
+		cm, err := nodeGroupConfigMap(ins, group, nodes)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := controllerutil.SetControllerReference(ins, cm, r.Scheme); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.applyNodeGroupConfigMap(ctx, cm); err != nil {
+			return ctrl.Result{}, err
+		}
+		keep[cm.Name] = true
+	}
+
+	if err := r.pruneNodeGroupConfigMaps(ctx, ins, keep); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// nodeGroupConfigMap renders the ConfigMap of a node group, holding the
+// labels of each node and the labels shared by all of them
+func nodeGroupConfigMap(ins *nfdv1.NodeFeatureDiscovery, group string, nodes map[string]map[string]string) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        nodeGroupConfigMapName(ins, group),
+			Namespace:   ins.GetNamespace(),
+			Labels:      map[string]string{nodeGroupConfigMapLabel: "true"},
+			Annotations: map[string]string{nodeGroupAnnotation: group},
+		},
+		Data: map[string]string{},
+	}
+	deployment.SetCommonLabels(ins, cm)
+
+	var shared map[string]string
+	for node, labels := range nodes {
+		data, err := yaml.Marshal(labels)
+		if err != nil {
+			return nil, err
+		}
+		cm.Data[nodeLabelsKeyPrefix+node+".yaml"] = string(data)
+
+		if shared == nil {
+			shared = map[string]string{}
+			for k, v := range labels {
+				shared[k] = v
+			}
+			continue
+		}
+		for k, v := range shared {
+			if value, ok := labels[k]; !ok || value != v {
+				delete(shared, k)
+			}
+		}
+	}
+	data, err := yaml.Marshal(shared)
+	if err != nil {
+		return nil, err
+	}
+	cm.Data[groupLabelsKey] = string(data)
+
+	return cm, nil
+}
+
+// nodeGroupConfigMapName returns the name of the ConfigMap of a node group.
+// The value of the node label is suffixed with a hash when it had to be
+// altered to fit in the name, or when it's the name of the ConfigMap of
+// the ungrouped nodes, so that distinct groups don't collide.
+func nodeGroupConfigMapName(ins *nfdv1.NodeFeatureDiscovery, group string) string {
+	prefix := deployment.InstanceName(ins, "nfd-features") + "-"
+	if group == "" {
+		return prefix + ungroupedNodes
+	}
+
+	name := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(group), "-"), ".-")
+	if name != group || name == ungroupedNodes {
+		hash := fmt.Sprintf("%x", sha256.Sum256([]byte(group)))[:8]
+		if name == "" {
+			name = hash
+		} else {
+			name += "-" + hash
+		}
+	}
+	if max := 253 - len(prefix); len(name) > max {
+		name = strings.TrimLeft(name[len(name)-max:], ".-")
+	}
+	return prefix + name
+}
+
+// applyNodeGroupConfigMap creates the ConfigMap of a node group, or updates
+// it if its content changed
+func (r *NodeFeatureDiscoveryReconciler) applyNodeGroupConfigMap(ctx context.Context, cm *corev1.ConfigMap) error {
+	found := &corev1.ConfigMap{}
+	err := r.Get(ctx, client.ObjectKeyFromObject(cm), found)
+	if errors.IsNotFound(err) {
+		r.Log.Info("Creating the node group ConfigMap", "ConfigMap", cm.Name, "group", cm.Annotations[nodeGroupAnnotation])
+		return r.Create(ctx, cm)
+	} else if err != nil {
+		return err
+	}
+
+	if equality.Semantic.DeepEqual(found.Data, cm.Data) &&
+		equality.Semantic.DeepEqual(found.Labels, cm.Labels) &&
+		found.Annotations[nodeGroupAnnotation] == cm.Annotations[nodeGroupAnnotation] {
+		return nil
+	}
+	cm.ResourceVersion = found.ResourceVersion
+	return r.Update(ctx, cm)
+}
+
+// pruneNodeGroupConfigMaps deletes the node group ConfigMaps of the CR
+// that aren't to be kept
+func (r *NodeFeatureDiscoveryReconciler) pruneNodeGroupConfigMaps(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, keep map[string]bool) error {
+	cms := &corev1.ConfigMapList{}
+	if err := r.List(ctx, cms, client.InNamespace(ins.GetNamespace()), client.MatchingLabels{nodeGroupConfigMapLabel: "true"}); err != nil {
+		return err
+	}
+	for i := range cms.Items {
+		cm := &cms.Items[i]
+		if keep[cm.Name] || !metav1.IsControlledBy(cm, ins) {
+			continue
+		}
+		r.Log.Info("Deleting the node group ConfigMap", "ConfigMap", cm.Name, "group", cm.Annotations[nodeGroupAnnotation])
+		if err := r.Delete(ctx, cm); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
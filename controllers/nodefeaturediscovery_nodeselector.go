@@ -0,0 +1,135 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+const (
+	// openshiftNodeSelectorAnnotation is the project node selector of
+	// OpenShift. When absent, the cluster default node selector of the
+	// Scheduler config applies.
+	openshiftNodeSelectorAnnotation = "openshift.io/node-selector"
+
+	// podNodeSelectorAnnotation is the namespace node selector of the
+	// PodNodeSelector admission plugin
+	podNodeSelectorAnnotation = "scheduler.alpha.kubernetes.io/node-selector"
+
+	// tolerationsWhitelistAnnotation restricts the tolerations of the
+	// pods of a namespace with the PodTolerationRestriction admission
+	// plugin
+	tolerationsWhitelistAnnotation = "scheduler.alpha.kubernetes.io/tolerationsWhitelist"
+)
+
+// schedulerGVK is the OpenShift cluster Scheduler config
+var schedulerGVK = schema.GroupVersionKind{Group: "config.openshift.io", Version: "v1", Kind: "Scheduler"}
+
+// checkNamespaceScheduling looks for namespace, or cluster, level defaults
+// that admission plugins would add to the operand pods and that would keep
+// nfd-worker from running on every node. They are either cleared, if the
+// CR asks for it, or reported.
+func (r *NodeFeatureDiscoveryReconciler) checkNamespaceScheduling(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) error {
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ins.GetNamespace()}, ns); err != nil {
+		return err
+	}
+
+	// Empty node selectors override the cluster defaults, as long as
+	// the operator may modify the namespace
+	if ins.Spec.Operand.ClearNamespaceNodeSelector && !ins.Spec.NamespaceManaged() {
+		r.warn(ins, "NamespaceNotManaged",
+			"spec.operand.clearNamespaceNodeSelector is ignored as spec.manageNamespace is false")
+	} else if ins.Spec.Operand.ClearNamespaceNodeSelector {
+		if isEmptyAnnotation(ns, openshiftNodeSelectorAnnotation) && isEmptyAnnotation(ns, podNodeSelectorAnnotation) {
+			return nil
+		}
+		r.Log.Info("Clearing the namespace node selectors", "Namespace", ns.Name)
+		patch := client.MergeFrom(ns.DeepCopy())
+		if ns.Annotations == nil {
+			ns.Annotations = map[string]string{}
+		}
+		ns.Annotations[openshiftNodeSelectorAnnotation] = ""
+		ns.Annotations[podNodeSelectorAnnotation] = ""
+		return r.Patch(ctx, ns, patch)
+	}
+
+	selectors := map[string]string{}
+	if s := ns.Annotations[podNodeSelectorAnnotation]; s != "" {
+		selectors[podNodeSelectorAnnotation] = s
+	}
+	if s, ok := ns.Annotations[openshiftNodeSelectorAnnotation]; ok {
+		if s != "" {
+			selectors[openshiftNodeSelectorAnnotation] = s
+		}
+	} else {
+		s, err := r.clusterDefaultNodeSelector(ctx)
+		if err != nil {
+			return err
+		}
+		if s != "" {
+			selectors["cluster default node selector"] = s
+		}
+	}
+
+	for source, s := range selectors {
+		r.warn(ins, "NamespaceNodeSelector", fmt.Sprintf(
+			"%s %q restricts the operand pods to the matching nodes; set spec.operand.clearNamespaceNodeSelector to override it",
+			source, s))
+	}
+	if s, ok := ns.Annotations[tolerationsWhitelistAnnotation]; ok {
+		r.warn(ins, "NamespaceTolerationsWhitelist", fmt.Sprintf(
+			"%s %q may reject the tolerations of the operand pods", tolerationsWhitelistAnnotation, s))
+	}
+
+	return nil
+}
+
+// clusterDefaultNodeSelector returns the default node selector of the
+// OpenShift cluster Scheduler config, or an empty string if the config
+// can't be read, e.g. when not running on OpenShift
+func (r *NodeFeatureDiscoveryReconciler) clusterDefaultNodeSelector(ctx context.Context) (string, error) {
+	scheduler := &unstructured.Unstructured{}
+	scheduler.SetGroupVersionKind(schedulerGVK)
+	err := r.Get(ctx, types.NamespacedName{Name: "cluster"}, scheduler)
+	if meta.IsNoMatchError(err) || errors.IsNotFound(err) || errors.IsForbidden(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	s, _, err := unstructured.NestedString(scheduler.Object, "spec", "defaultNodeSelector")
+	return s, err
+}
+
+// isEmptyAnnotation returns true if the object has the annotation, with an
+// empty value
+func isEmptyAnnotation(obj client.Object, annotation string) bool {
+	v, ok := obj.GetAnnotations()[annotation]
+	return ok && v == ""
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+// nodeInfo is what the node summary keeps about a node
+type nodeInfo struct {
+	// labeled is true if the node has NFD labels
+	labeled bool
+
+	// tainted is true if the node has NFD taints
+	tainted bool
+
+	// platform is the operating system and architecture of the node
+	platform platform
+}
+
+// platform identifies an operating system and architecture, as given by
+// the well-known node labels
+type platform struct {
+	os   string
+	arch string
+}
+
+// platformCounts are the node counts of a platform
+type platformCounts struct {
+	Nodes   int
+	Labeled int
+}
+
+// nodeSummary keeps aggregate counts about the nodes of the cluster. It is
+// fed by the events of the node informer, so the counts are updated
+// incrementally instead of going through all the nodes on every reconcile.
+type nodeSummary struct {
+	// synced returns true once the informer has delivered all the
+	// existing nodes
+	synced func() bool
+
+	// mu protects the fields below
+	mu     sync.RWMutex
+	nodes  map[string]nodeInfo
+	counts nodeCounts
+}
+
+var _ toolscache.ResourceEventHandler = &nodeSummary{}
+
+// newNodeSummary returns an empty node summary
+func newNodeSummary() *nodeSummary {
+	return &nodeSummary{nodes: map[string]nodeInfo{}, counts: newNodeCounts()}
+}
+
+// nodeCounts are the aggregate counts of a node summary
+type nodeCounts struct {
+	Nodes   int
+	Labeled int
+	Tainted int
+
+	// Platforms breaks the node counts down by platform
+	Platforms map[platform]platformCounts
+}
+
+// newNodeCounts returns zero counts
+func newNodeCounts() nodeCounts {
+	return nodeCounts{Platforms: map[platform]platformCounts{}}
+}
+
+// add counts a node
+func (c *nodeCounts) add(info nodeInfo) {
+	c.update(info, 1)
+}
+
+// remove uncounts a node
+func (c *nodeCounts) remove(info nodeInfo) {
+	c.update(info, -1)
+}
+
+// update adds delta to the counts the node is part of
+func (c *nodeCounts) update(info nodeInfo, delta int) {
+	p := c.Platforms[info.platform]
+	c.Nodes += delta
+	p.Nodes += delta
+	if info.labeled {
+		c.Labeled += delta
+		p.Labeled += delta
+	}
+	if info.tainted {
+		c.Tainted += delta
+	}
+	if p.Nodes == 0 {
+		delete(c.Platforms, info.platform)
+	} else {
+		c.Platforms[info.platform] = p
+	}
+}
+
+// Counts returns the current counts. It returns false if the summary
+// isn't complete yet, e.g. right after the operator started.
+func (s *nodeSummary) Counts() (nodeCounts, bool) {
+	if s == nil || s.synced == nil || !s.synced() {
+		return nodeCounts{}, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	counts := s.counts
+	counts.Platforms = make(map[platform]platformCounts, len(s.counts.Platforms))
+	for p, c := range s.counts.Platforms {
+		counts.Platforms[p] = c
+	}
+	return counts, true
+}
+
+// OnAdd implements the toolscache.ResourceEventHandler interface
+func (s *nodeSummary) OnAdd(obj interface{}) {
+	if node, ok := obj.(*corev1.Node); ok {
+		s.set(node.Name, summarizeNode(node))
+	}
+}
+
+// OnUpdate implements the toolscache.ResourceEventHandler interface
+func (s *nodeSummary) OnUpdate(_, newObj interface{}) {
+	s.OnAdd(newObj)
+}
+
+// OnDelete implements the toolscache.ResourceEventHandler interface
+func (s *nodeSummary) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if node, ok := obj.(*corev1.Node); ok {
+		s.remove(node.Name)
+	}
+}
+
+// set records the summary of a node, replacing the previous one
+func (s *nodeSummary) set(name string, info nodeInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeLocked(name)
+	s.nodes[name] = info
+	s.counts.add(info)
+}
+
+// remove forgets about a node
+func (s *nodeSummary) remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeLocked(name)
+}
+
+// removeLocked forgets about a node, s.mu must be held
+func (s *nodeSummary) removeLocked(name string) {
+	old, ok := s.nodes[name]
+	if !ok {
+		return
+	}
+	s.counts.remove(old)
+	delete(s.nodes, name)
+}
+
+// nodeCounts returns the node counts from the node summary, or computes
+// them from the nodes until the summary is complete
+func (r *NodeFeatureDiscoveryReconciler) nodeCounts(ctx context.Context) (nodeCounts, error) {
+	if counts, ok := r.nodes.Counts(); ok {
+		return counts, nil
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return nodeCounts{}, err
+	}
+	counts := newNodeCounts()
+	for i := range nodes.Items {
+		counts.add(summarizeNode(&nodes.Items[i]))
+	}
+	return counts, nil
+}
+
+// summarizeNode returns what the node summary keeps about a node
+func summarizeNode(node *corev1.Node) nodeInfo {
+	return nodeInfo{
+		labeled: hasFeatureLabels(node.Labels),
+		tainted: hasFeatureTaints(node),
+		platform: platform{
+			os:   node.Labels[corev1.LabelOSStable],
+			arch: node.Labels[corev1.LabelArchStable],
+		},
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+const (
+	// The notified events: conditions changing status, the cleanup of
+	// the nodes completing or being abandoned, and the operands being
+	// retained on deletion
+	notificationConditionsChanged = "ConditionsChanged"
+	notificationCleanupCompleted  = "CleanupCompleted"
+	notificationCleanupTimedOut   = "CleanupTimedOut"
+	notificationOperandsRetained  = "OperandsRetained"
+)
+
+// notification is the JSON payload of the notifications
+type notification struct {
+	NodeFeatureDiscovery string                `json:"nodeFeatureDiscovery"`
+	Event                string                `json:"event"`
+	Message              string                `json:"message"`
+	Transitions          []conditionTransition `json:"transitions,omitempty"`
+	Time                 metav1.Time           `json:"time"`
+}
+
+// conditionTransition describes a condition that changed status
+type conditionTransition struct {
+	Type           conditionsv1.ConditionType `json:"type"`
+	Status         corev1.ConditionStatus     `json:"status"`
+	PreviousStatus corev1.ConditionStatus     `json:"previousStatus,omitempty"`
+	Reason         string                     `json:"reason,omitempty"`
+	Message        string                     `json:"message,omitempty"`
+}
+
+// slackMessage is the payload of Slack incoming webhooks
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// notifyTransitions notifies the sinks of the CR of the conditions whose
+// status differs from the given previous conditions. Conditions showing
+// up are only notified when true, so that a new CR doesn't notify each
+// of its conditions.
+func (r *NodeFeatureDiscoveryReconciler) notifyTransitions(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, previous []conditionsv1.Condition) {
+	if len(ins.Spec.Notifications) == 0 {
+		return
+	}
+
+	transitions := []conditionTransition{}
+	for _, cond := range ins.Status.Conditions {
+		t := conditionTransition{
+			Type:    cond.Type,
+			Status:  cond.Status,
+			Reason:  cond.Reason,
+			Message: cond.Message,
+		}
+		if prev := conditionsv1.FindStatusCondition(previous, cond.Type); prev != nil {
+			if prev.Status == cond.Status {
+				continue
+			}
+			t.PreviousStatus = prev.Status
+		} else if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		transitions = append(transitions, t)
+	}
+	if len(transitions) == 0 {
+		return
+	}
+
+	for _, sink := range ins.Spec.Notifications {
+		n := notification{
+			NodeFeatureDiscovery: ins.GetNamespace() + "/" + ins.GetName(),
+			Event:                notificationConditionsChanged,
+			Time:                 metav1.Now(),
+		}
+		changes := []string{}
+		for _, t := range transitions {
+			if len(sink.Conditions) > 0 && !containsString(sink.Conditions, string(t.Type)) {
+				continue
+			}
+			n.Transitions = append(n.Transitions, t)
+			change := fmt.Sprintf("%s is %s", t.Type, t.Status)
+			if t.Reason != "" {
+				change += fmt.Sprintf(" (%s)", t.Reason)
+			}
+			changes = append(changes, change)
+		}
+		if len(n.Transitions) == 0 {
+			continue
+		}
+		n.Message = strings.Join(changes, ", ")
+		r.notifySink(ctx, ins, sink, &n)
+	}
+}
+
+// notifyEvent notifies all the sinks of the CR of the given event
+func (r *NodeFeatureDiscoveryReconciler) notifyEvent(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, event, message string) {
+	for _, sink := range ins.Spec.Notifications {
+		r.notifySink(ctx, ins, sink, &notification{
+			NodeFeatureDiscovery: ins.GetNamespace() + "/" + ins.GetName(),
+			Event:                event,
+			Message:              message,
+			Time:                 metav1.Now(),
+		})
+	}
+}
+
+// notifySink queues the notification to be POSTed to the sink.
+// Notifications are best effort: errors are only logged.
+func (r *NodeFeatureDiscoveryReconciler) notifySink(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, sink nfdv1.NotificationSink, n *notification) {
+	logger := r.Log.WithValues("notification", n.Event, "sink", sink.Name)
+
+	url, err := r.notificationURL(ctx, ins, sink)
+	if err != nil {
+		logger.Error(err, "Couldn't get the notification URL")
+		return
+	}
+
+	var payload interface{} = n
+	if sink.Format == nfdv1.NotificationSlack {
+		payload = slackMessage{Text: fmt.Sprintf("NodeFeatureDiscovery %s: %s", n.NodeFeatureDiscovery, n.Message)}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error(err, "Couldn't encode the notification")
+		return
+	}
+
+	r.sender.send(ctx, logger, url, data)
+}
+
+// notificationURL returns the URL of the sink, read from its secret if
+// it's given by reference
+func (r *NodeFeatureDiscoveryReconciler) notificationURL(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, sink nfdv1.NotificationSink) (string, error) {
+	ref := sink.URLSecretRef
+	if ref == nil {
+		if sink.URL == "" {
+			return "", fmt.Errorf("neither url nor urlSecretRef is set")
+		}
+		return sink.URL, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: ins.GetNamespace(), Name: ref.Name}, secret); err != nil {
+		return "", err
+	}
+	url, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found in Secret %q", ref.Key, ref.Name)
+	}
+	return strings.TrimSpace(string(url)), nil
+}
+
+// containsString returns true if the list contains the string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+const (
+	// conditionLabelConflict is true when feature labels of the instance
+	// are also published by other NFD instances
+	conditionLabelConflict conditionsv1.ConditionType = "LabelConflict"
+
+	reasonLabelsOwned     = "ConflictingLabelsOwned"
+	reasonLabelsLost      = "ConflictingLabelsLost"
+	reasonNoLabelConflict = "NoLabelConflict"
+
+	// maxReportedConflicts bounds the number of labels listed in the
+	// status, to keep the CR small
+	maxReportedConflicts = 10
+)
+
+// labelClaimant is an NFD instance publishing feature labels, ranked to
+// arbitrate the labels several instances publish
+type labelClaimant struct {
+	// name is the "<namespace>/<name>" of the oldest NodeFeatureDiscovery
+	// object of the instance, see unmanagedInstanceName for the instances
+	// not managed by the operator
+	name    string
+	managed bool
+	created int64
+}
+
+// before returns true if the claimant owns the labels it shares with the
+// other one: the instances managed by the operator win over the other
+// ones, then the oldest NodeFeatureDiscovery object wins, and its name
+// breaks the ties
+func (c labelClaimant) before(o labelClaimant) bool {
+	if c.managed != o.managed {
+		return c.managed
+	}
+	if c.created != o.created {
+		return c.created < o.created
+	}
+	return c.name < o.name
+}
+
+// checkLabelOwnership finds the feature labels of the instance that other
+// NFD instances publish on the same nodes, from the annotations each
+// nfd-master records its labels in, and reports them in the status and in
+// the LabelConflict condition. Each conflicting label is owned by a single
+// instance, so that all the NodeFeatureDiscovery objects agree on which
+// one has to give it up. nfd-master can't be told to leave out a single
+// label, so the conflict is left to the user to resolve.
+func (r *NodeFeatureDiscoveryReconciler) checkLabelOwnership(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) error {
+	list := &nfdv1.NodeFeatureDiscoveryList{}
+	if err := r.List(ctx, list); err != nil {
+		return err
+	}
+	claimants := map[string]labelClaimant{}
+	for i := range list.Items {
+		nfd := &list.Items[i]
+		c := labelClaimant{
+			name:    nfd.GetNamespace() + "/" + nfd.GetName(),
+			managed: true,
+			created: nfd.GetCreationTimestamp().UnixNano(),
+		}
+		if current, ok := claimants[nfd.Spec.Instance]; !ok || c.before(current) {
+			claimants[nfd.Spec.Instance] = c
+		}
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return err
+	}
+
+	// Count, for each label of the instance, the nodes it's also published
+	// on by other instances, and which ones
+	instance := ins.Spec.Instance
+	conflicts := map[string]*nfdv1.LabelConflict{}
+	others := map[string]map[string]bool{}
+	for i := range nodes.Items {
+		published := publishedFeatureLabels(&nodes.Items[i])
+		for label, instances := range published {
+			if len(instances) < 2 || !instances[instance] {
+				continue
+			}
+			if conflicts[label] == nil {
+				conflicts[label] = &nfdv1.LabelConflict{Label: label}
+				others[label] = map[string]bool{}
+			}
+			conflicts[label].Nodes++
+			for other := range instances {
+				others[label][other] = true
+			}
+		}
+	}
+
+	status := &nfdv1.LabelConflictsStatus{ConflictingLabels: len(conflicts)}
+	labels := make([]string, 0, len(conflicts))
+	for label := range conflicts {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		c := conflicts[label]
+		var owner labelClaimant
+		for i, other := range sortedKeys(others[label]) {
+			claimant, ok := claimants[other]
+			if !ok {
+				claimant = labelClaimant{name: unmanagedInstanceName(other)}
+			}
+			c.Claimants = append(c.Claimants, claimant.name)
+			if i == 0 || claimant.before(owner) {
+				owner = claimant
+			}
+		}
+		sort.Strings(c.Claimants)
+		c.Owner = owner.name
+		if owner.name != claimants[instance].name {
+			status.LostLabels++
+		}
+		if len(status.Conflicts) < maxReportedConflicts {
+			status.Conflicts = append(status.Conflicts, *c)
+		}
+	}
+	if status.ConflictingLabels == 0 {
+		status = nil
+	}
+
+	cond := conditionsv1.Condition{
+		Type:   conditionLabelConflict,
+		Status: corev1.ConditionFalse,
+		Reason: reasonNoLabelConflict,
+	}
+	if status != nil {
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = reasonLabelsOwned
+		cond.Message = fmt.Sprintf("%d feature labels are also published by other NFD instances, this instance owns them",
+			status.ConflictingLabels)
+		if status.LostLabels > 0 {
+			cond.Reason = reasonLabelsLost
+			cond.Message = fmt.Sprintf("%d feature labels are also published by other NFD instances, %d of them are owned by another instance",
+				status.ConflictingLabels, status.LostLabels)
+		}
+	}
+
+	found := conditionsv1.FindStatusCondition(ins.Status.Conditions, conditionLabelConflict)
+	condChanged := found == nil || found.Status != cond.Status || found.Reason != cond.Reason || found.Message != cond.Message
+	if !condChanged && equality.Semantic.DeepEqual(ins.Status.LabelConflicts, status) {
+		return nil
+	}
+	if condChanged {
+		if cond.Reason == reasonLabelsLost {
+			r.warn(ins, reasonLabelsLost, cond.Message)
+		}
+		conditionsv1.SetStatusCondition(&ins.Status.Conditions, cond)
+	}
+	ins.Status.LabelConflicts = status
+	return r.Status().Update(ctx, ins)
+}
+
+// publishedFeatureLabels returns the instances publishing each feature
+// label of the node, read from the feature labels annotations of all the
+// nfd-master instances
+func publishedFeatureLabels(node *corev1.Node) map[string]map[string]bool {
+	published := map[string]map[string]bool{}
+	for key, value := range node.Annotations {
+		var instance string
+		switch {
+		case key == featureLabelsAnnotation:
+		case strings.HasSuffix(key, "."+featureLabelsAnnotation):
+			instance = strings.TrimSuffix(key, "."+featureLabelsAnnotation)
+		default:
+			continue
+		}
+		for _, name := range strings.Split(value, ",") {
+			if name == "" {
+				continue
+			}
+			if !strings.Contains(name, "/") {
+				name = featureLabelPrefix + name
+			}
+			if published[name] == nil {
+				published[name] = map[string]bool{}
+			}
+			published[name][instance] = true
+		}
+	}
+	return published
+}
+
+// unmanagedInstanceName names an NFD instance no NodeFeatureDiscovery
+// object deploys in the status
+func unmanagedInstanceName(instance string) string {
+	if instance == "" {
+		return "instance <default>"
+	}
+	return "instance " + instance
+}
+
+// sortedKeys returns the keys of the set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+const (
+	// reasonPaused is the reason of the conditions, and of the event,
+	// reporting that the operands aren't reconciled
+	reasonPaused = "Paused"
+
+	pausedMessage = "reconciliation is paused, the operand resources aren't applied until spec.paused is turned off"
+)
+
+// reportSuspended reflects a CR whose operands aren't reconciled, as it's
+// paused or unmanaged, in the conditions, and in an event when it gets
+// suspended. Available and Degraded are left as is, as the operands keep
+// running as they were.
+func (r *NodeFeatureDiscoveryReconciler) reportSuspended(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, reason, message string) error {
+	found := conditionsv1.FindStatusCondition(ins.Status.Conditions, conditionsv1.ConditionProgressing)
+	if found == nil || found.Reason != reason {
+		r.event(ins, reason, message)
+	}
+
+	conds := []conditionsv1.Condition{
+		condition(conditionsv1.ConditionProgressing, false, reason, message),
+		condition(conditionsv1.ConditionUpgradeable, false, reason, message),
+	}
+	if conditionsv1.FindStatusCondition(ins.Status.Conditions, conditionsv1.ConditionAvailable) == nil {
+		conds = append(conds, condition(conditionsv1.ConditionAvailable, false, reason, message))
+	}
+	return r.setConditions(ctx, ins, conds...)
+}
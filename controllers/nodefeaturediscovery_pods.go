@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+)
+
+// reasonOperandPodsFailing is the Degraded reason of operand pods stuck
+// crash-looping or failing to pull their image
+const reasonOperandPodsFailing = "OperandPodsFailing"
+
+// operandApps are the app labels of the operand pods
+var operandApps = []string{"nfd-master", "nfd-worker", "nfd-topology-updater", "nfd-gc"}
+
+// failingOperandPods describes the operand pods whose containers are
+// stuck in one of the deployment.FailingWaitReasons, e.g. CrashLoopBackOff.
+// The pods are grouped by operand and reason, with the message of the
+// first pod of each group, in a stable order. It returns an empty string
+// if no pod is failing.
+func (r *NodeFeatureDiscoveryReconciler) failingOperandPods(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) (string, error) {
+	type group struct {
+		pods    int
+		example string
+	}
+
+	summary := []string{}
+	for _, app := range operandApps {
+		pods := &corev1.PodList{}
+		if err := r.List(ctx, pods, client.InNamespace(ins.GetNamespace()), deployment.PodSelector(ins, app)); err != nil {
+			return "", err
+		}
+		sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+
+		groups := map[string]*group{}
+		for i := range pods.Items {
+			reason, msg := podFailure(&pods.Items[i])
+			if reason == "" {
+				continue
+			}
+			g, ok := groups[reason]
+			if !ok {
+				g = &group{example: fmt.Sprintf("%s: %s", pods.Items[i].Name, msg)}
+				groups[reason] = g
+			}
+			g.pods++
+		}
+
+		reasons := make([]string, 0, len(groups))
+		for reason := range groups {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			g := groups[reason]
+			summary = append(summary, fmt.Sprintf("%s: %d pods in %s (%s)", app, g.pods, reason, g.example))
+		}
+	}
+	return strings.Join(summary, "; "), nil
+}
+
+// podFailure returns the waiting reason of the first container of the pod
+// stuck failing, along with a message telling why. For a crash-looping
+// container, the message is the one of its last termination, which holds
+// the end of its logs.
+func podFailure(pod *corev1.Pod) (string, string) {
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, cs := range statuses {
+		waiting := cs.State.Waiting
+		if waiting == nil || !containsString(deployment.FailingWaitReasons, waiting.Reason) {
+			continue
+		}
+		msg := waiting.Message
+		if last := cs.LastTerminationState.Terminated; last != nil && last.Message != "" {
+			msg = fmt.Sprintf("exit code %d: %s", last.ExitCode, last.Message)
+		}
+		if i := strings.IndexByte(strings.TrimSpace(msg), '\n'); i >= 0 {
+			msg = strings.TrimSpace(msg)[:i]
+		}
+		return waiting.Reason, fmt.Sprintf("container %s: %s", cs.Name, strings.TrimSpace(msg))
+	}
+	return "", ""
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	authv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// permission is an API permission the operator needs
+type permission struct {
+	group    string
+	resource string
+	verbs    []string
+}
+
+// String implements the fmt.Stringer interface
+func (p permission) String() string {
+	return p.group + "/" + p.resource
+}
+
+var (
+	// basePermissions are the permissions the operator always needs,
+	// as granted by the kubebuilder:rbac markers of the reconciler, which
+	// TestBasePermissions checks against config/rbac/role.yaml
+	basePermissions = []permission{
+		{"nfd.kubernetes.io", "nodefeaturediscoveries", []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+		{"nfd.kubernetes.io", "nodefeaturediscoveries/status", []string{"get", "update", "patch"}},
+		{"nfd.kubernetes.io", "nodefeaturediscoveries/finalizers", []string{"update"}},
+		{"", "pods", []string{"get", "list", "watch", "patch", "update", "delete"}},
+		{"", "nodes", []string{"get", "list", "watch", "patch", "update"}},
+		{"", "nodes/status", []string{"patch", "update"}},
+		{"", "namespaces", []string{"get", "list", "watch", "create", "patch"}},
+		{"", "configmaps", []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+		{"", "secrets", []string{"get", "list", "watch"}},
+		{"", "serviceaccounts", []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+		{"", "services", []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+		{"", "events", []string{"create", "patch"}},
+		{"apps", "daemonsets", []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+		{"apps", "deployments", []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+		{"batch", "jobs", []string{"get", "list", "watch", "create", "delete"}},
+		{"policy", "poddisruptionbudgets", []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+		{"coordination.k8s.io", "leases", []string{"get", "list", "watch", "create", "update", "delete"}},
+		{"rbac.authorization.k8s.io", "roles", []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+		{"rbac.authorization.k8s.io", "rolebindings", []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+		{"rbac.authorization.k8s.io", "clusterroles", []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+		{"rbac.authorization.k8s.io", "clusterrolebindings", []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+	}
+
+	// topologyPermissions are only needed when the NodeResourceTopology
+	// API is available, for nfd-master to publish the objects reported
+	// by nfd-topology-updater, and for the operator to count them
+	topologyPermissions = []permission{
+		{"topology.node.k8s.io", "noderesourcetopologies", []string{"get", "list", "create", "update"}},
+	}
+
+	// taintsPermissions are only needed when the NodeFeatureRule API is
+	// available, for nfd-master to read the taints of the rules
+	taintsPermissions = []permission{
+		{"nfd.k8s-sigs.io", "nodefeaturerules", []string{"get", "list", "watch"}},
+	}
+
+	// nodeFeaturePermissions are only needed when the NodeFeature API is
+	// available, for nfd-master and nfd-worker to use it. Installing its
+	// CRDs is only checked once they're needed, by the reconcile.
+	nodeFeaturePermissions = []permission{
+		{"nfd.k8s-sigs.io", "nodefeatures", []string{"get", "list", "watch", "create", "update"}},
+		{"nfd.k8s-sigs.io", "nodefeaturerules", []string{"get", "list", "watch"}},
+	}
+
+	// certManagerPermissions are only needed when cert-manager is
+	// installed, to have it issue the operand certificates
+	certManagerPermissions = []permission{
+		{"cert-manager.io", "certificates", []string{"get", "create", "update", "patch"}},
+		{"cert-manager.io", "issuers", []string{"get", "create", "update", "patch"}},
+	}
+
+	// gcPermissions are granted to nfd-gc, for each of the APIs of the
+	// objects it deletes that is available
+	gcPermissions = []struct {
+		kind schema.GroupKind
+		permission
+	}{
+		{schema.GroupKind{Group: "topology.node.k8s.io", Kind: "NodeResourceTopology"}, permission{"topology.node.k8s.io", "noderesourcetopologies", []string{"list", "delete"}}},
+		{schema.GroupKind{Group: "nfd.k8s-sigs.io", Kind: "NodeFeature"}, permission{"nfd.k8s-sigs.io", "nodefeatures", []string{"list", "delete"}}},
+	}
+
+	// openshiftPermissions are only needed when the OpenShift security
+	// API is available
+	openshiftPermissions = []permission{
+		{"security.openshift.io", "securitycontextconstraints", []string{"get", "list", "watch", "create", "update", "patch", "use"}},
+	}
+
+	// consolePluginPermissions are only needed when the OpenShift console
+	// serves dynamic plugins
+	consolePluginPermissions = []permission{
+		{"console.openshift.io", "consoleplugins", []string{"get", "create", "patch"}},
+	}
+)
+
+// RBACPreflight verifies, using SelfSubjectAccessReviews, that the
+// operator has all the permissions needed by the enabled features. Its
+// Check method can be used as a readiness checker.
+type RBACPreflight struct {
+	client client.Client
+	mapper meta.RESTMapper
+
+	// mu protects passed
+	mu     sync.Mutex
+	passed bool
+}
+
+// NewRBACPreflight returns a new RBACPreflight
+func NewRBACPreflight(c client.Client, mapper meta.RESTMapper) *RBACPreflight {
+	return &RBACPreflight{client: c, mapper: mapper}
+}
+
+// Check returns an error listing the missing permissions, if any. Once all
+// permissions have been granted the result is cached, so the API server is
+// not queried on every readiness probe.
+func (p *RBACPreflight) Check(_ *http.Request) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.passed {
+		return nil
+	}
+
+	missing, err := p.missingPermissions(context.TODO())
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing permissions: %s", strings.Join(missing, ", "))
+	}
+
+	p.passed = true
+	return nil
+}
+
+// missingPermissions returns the list of permissions, formatted as
+// "<verb> <group>/<resource>", that are not granted to the operator
+func (p *RBACPreflight) missingPermissions(ctx context.Context) ([]string, error) {
+	required := basePermissions
+	if p.hasAPI("security.openshift.io", "SecurityContextConstraints") {
+		required = append(required, openshiftPermissions...)
+	}
+	if p.hasAPI("console.openshift.io", "ConsolePlugin") {
+		required = append(required, consolePluginPermissions...)
+	}
+	if p.hasAPI("topology.node.k8s.io", "NodeResourceTopology") {
+		required = append(required, topologyPermissions...)
+	}
+	if p.hasAPI("nfd.k8s-sigs.io", "NodeFeatureRule") {
+		required = append(required, taintsPermissions...)
+	}
+	if p.hasAPI("nfd.k8s-sigs.io", "NodeFeature") {
+		required = append(required, nodeFeaturePermissions...)
+	}
+	if p.hasAPI("cert-manager.io", "Certificate") {
+		required = append(required, certManagerPermissions...)
+	}
+	for _, gc := range gcPermissions {
+		if p.hasAPI(gc.kind.Group, gc.kind.Kind) {
+			required = append(required, gc.permission)
+		}
+	}
+
+	missing := []string{}
+	for _, perm := range required {
+		for _, verb := range perm.verbs {
+			resource, subresource := perm.resource, ""
+			if i := strings.Index(resource, "/"); i >= 0 {
+				resource, subresource = resource[:i], resource[i+1:]
+			}
+
+			review := &authv1.SelfSubjectAccessReview{
+				Spec: authv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authv1.ResourceAttributes{
+						Group:       perm.group,
+						Resource:    resource,
+						Subresource: subresource,
+						Verb:        verb,
+					},
+				},
+			}
+			if err := p.client.Create(ctx, review); err != nil {
+				return nil, err
+			}
+			if !review.Status.Allowed {
+				missing = append(missing, verb+" "+perm.String())
+			}
+		}
+	}
+	return missing, nil
+}
+
+// hasAPI returns true if the given kind is served by the API server
+func (p *RBACPreflight) hasAPI(group, kind string) bool {
+	_, err := p.mapper.RESTMapping(schema.GroupKind{Group: group, Kind: kind})
+	return err == nil
+}
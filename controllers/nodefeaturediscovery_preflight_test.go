@@ -0,0 +1,75 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// TestBasePermissions checks that the preflight checks the permissions
+// granted by the ClusterRole generated from the kubebuilder:rbac markers,
+// no more and no less
+func TestBasePermissions(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("..", "config", "rbac", "role.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	role := &rbacv1.ClusterRole{}
+	if err := yaml.Unmarshal(data, role); err != nil {
+		t.Fatal(err)
+	}
+
+	granted := map[string]bool{}
+	for _, rule := range role.Rules {
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				for _, verb := range rule.Verbs {
+					granted[verb+" "+permission{group, resource, nil}.String()] = true
+				}
+			}
+		}
+	}
+	checked := map[string]bool{}
+	for _, perm := range basePermissions {
+		for _, verb := range perm.verbs {
+			checked[verb+" "+perm.String()] = true
+		}
+	}
+
+	diff := func(a, b map[string]bool) []string {
+		missing := []string{}
+		for k := range a {
+			if !b[k] {
+				missing = append(missing, k)
+			}
+		}
+		sort.Strings(missing)
+		return missing
+	}
+	if missing := diff(granted, checked); len(missing) > 0 {
+		t.Errorf("permissions granted but not checked: %v", missing)
+	}
+	if extra := diff(checked, granted); len(extra) > 0 {
+		t.Errorf("permissions checked but not granted: %v", extra)
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+const (
+	// applyProgressAnnotation records, on the CR, the state being
+	// applied as "<generation>/<state>", or "<generation>/completed" once
+	// all the states are ready. It lets an operator taking over, e.g.
+	// after a crash, resume a rollout where it stopped.
+	applyProgressAnnotation = "nfd.kubernetes.io/apply-progress"
+
+	// applyCompleted is the state recorded once all states are ready
+	applyCompleted = "completed"
+)
+
+// resumeApply skips the states already applied by a previous operator
+// instance, on the first reconcile of the CR by this instance. The marker
+// is only trusted if the spec didn't change since it was recorded.
+func (r *NodeFeatureDiscoveryReconciler) resumeApply(ins *nfdv1.NodeFeatureDiscovery) {
+	key := types.NamespacedName{Namespace: ins.Namespace, Name: ins.Name}
+	if r.resumed == nil {
+		r.resumed = map[types.NamespacedName]bool{}
+	}
+	if r.resumed[key] {
+		return
+	}
+	r.resumed[key] = true
+
+	generation, state, ok := parseApplyProgress(ins.GetAnnotations()[applyProgressAnnotation])
+	if !ok || generation != ins.GetGeneration() || state == applyCompleted {
+		return
+	}
+	if nfd.ResumeFrom(state) {
+		r.Log.Info("Resuming the rollout", "state", state)
+	}
+}
+
+// recordApplyProgress records the state being applied on the CR, if it
+// changed
+func (r *NodeFeatureDiscoveryReconciler) recordApplyProgress(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, state string) error {
+	value := fmt.Sprintf("%d/%s", ins.GetGeneration(), state)
+	if ins.GetAnnotations()[applyProgressAnnotation] == value {
+		return nil
+	}
+
+	patch := client.MergeFrom(ins.DeepCopy())
+	if ins.Annotations == nil {
+		ins.Annotations = map[string]string{}
+	}
+	ins.Annotations[applyProgressAnnotation] = value
+	return r.Patch(ctx, ins, patch)
+}
+
+// parseApplyProgress parses the value of the apply progress annotation
+func parseApplyProgress(value string) (int64, string, bool) {
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return 0, "", false
+	}
+	generation, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return generation, parts[1], true
+}
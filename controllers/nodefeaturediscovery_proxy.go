@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+)
+
+// clusterProxy returns an empty OpenShift cluster-wide proxy config, to
+// watch it
+func clusterProxy() *unstructured.Unstructured {
+	proxy := &unstructured.Unstructured{}
+	proxy.SetGroupVersionKind(deployment.ClusterProxyGVK)
+	return proxy
+}
+
+// proxyStatusChanged only lets through the events of the cluster proxy
+// config in use changing the proxy settings, which are read from its
+// status, so that its other updates don't trigger reconciles
+var proxyStatusChanged = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		return e.Object.GetName() == deployment.ClusterProxyName
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		if e.ObjectNew.GetName() != deployment.ClusterProxyName {
+			return false
+		}
+		oldProxy, ok := e.ObjectOld.(*unstructured.Unstructured)
+		if !ok {
+			return true
+		}
+		newProxy, ok := e.ObjectNew.(*unstructured.Unstructured)
+		if !ok {
+			return true
+		}
+		return !reflect.DeepEqual(oldProxy.Object["status"], newProxy.Object["status"])
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return e.Object.GetName() == deployment.ClusterProxyName
+	},
+	GenericFunc: func(e event.GenericEvent) bool {
+		return false
+	},
+}
+
+// proxyToRequests maps the cluster proxy config to reconcile requests for
+// the NodeFeatureDiscovery CRs using it, i.e. not setting their own proxy,
+// so that the proxy variables of the operands are updated
+func (r *NodeFeatureDiscoveryReconciler) proxyToRequests(obj client.Object) []reconcile.Request {
+	nfdList := &nfdv1.NodeFeatureDiscoveryList{}
+	if err := r.List(context.TODO(), nfdList); err != nil {
+		r.Log.Error(err, "could not list NodeFeatureDiscovery objects")
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, i := range nfdList.Items {
+		if i.Spec.Operand.Proxy != nil {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: i.Namespace, Name: i.Name},
+		})
+	}
+	return requests
+}
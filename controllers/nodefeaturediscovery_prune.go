@@ -0,0 +1,145 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+)
+
+const (
+	// reasonPruneFailed is the reason of the event reporting a prune Job
+	// that didn't complete
+	reasonPruneFailed = "PruneFailed"
+
+	// prunePollInterval is the delay between the checks of the prune Job
+	prunePollInterval = 5 * time.Second
+)
+
+// pruneEnabled returns true if the prune Job runs on deletion
+func pruneEnabled(ins *nfdv1.NodeFeatureDiscovery) bool {
+	return ins.Spec.Cleanup.Prune == nil || *ins.Spec.Cleanup.Prune
+}
+
+// pruneNeeded returns true if the prune Job is enabled and hasn't run yet
+func pruneNeeded(ins *nfdv1.NodeFeatureDiscovery) bool {
+	return pruneEnabled(ins) && (ins.Status.Cleanup == nil || !ins.Status.Cleanup.Pruned)
+}
+
+// runPrune creates the prune Job, and its ServiceAccount and cluster RBAC,
+// unless they exist, and returns true once the Job is done. The Job and
+// its RBAC are then deleted, and the status records that the prune is
+// done. A failed Job is reported in a warning event, the cleanup of the
+// nodes by the operator removing what it can afterwards.
+func (r *NodeFeatureDiscoveryReconciler) runPrune(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) (bool, error) {
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: ins.GetNamespace(), Name: deployment.PruneName(ins)}, job)
+	if errors.IsNotFound(err) {
+		r.Log.Info("Running the prune Job", "Job", deployment.PruneName(ins))
+		return false, r.createPrune(ctx, ins)
+	} else if err != nil {
+		return false, err
+	}
+
+	if cond := jobCondition(job, batchv1.JobFailed); cond != nil {
+		r.warn(ins, reasonPruneFailed, fmt.Sprintf("prune Job %s failed, removing the NFD labels without it: %s", job.Name, cond.Message))
+	} else if jobCondition(job, batchv1.JobComplete) == nil {
+		return false, nil
+	} else {
+		r.Log.Info("Prune Job completed", "Job", job.Name)
+	}
+
+	if err := r.deletePrune(ctx, ins); err != nil {
+		return false, err
+	}
+	if ins.Status.Cleanup == nil {
+		ins.Status.Cleanup = &nfdv1.CleanupStatus{}
+	}
+	ins.Status.Cleanup.Pruned = true
+	return true, r.Status().Update(ctx, ins)
+}
+
+// createPrune creates the prune Job and the objects it needs. The
+// namespaced ones are owned by the NodeFeatureDiscovery object, so that
+// they're garbage collected along with it if the Job outlives it.
+func (r *NodeFeatureDiscoveryReconciler) createPrune(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) error {
+	job, err := deployment.PruneJob(ins)
+	if err != nil {
+		return err
+	}
+	sa := deployment.PruneServiceAccount(ins)
+	for _, obj := range []client.Object{sa, job} {
+		if err := controllerutil.SetControllerReference(ins, obj, r.Scheme); err != nil {
+			return err
+		}
+	}
+
+	objs := []client.Object{
+		sa,
+		deployment.PruneClusterRole(ins),
+		deployment.PruneClusterRoleBinding(ins),
+		job,
+	}
+	for _, obj := range objs {
+		if err := r.Create(ctx, obj); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// deletePrune deletes the prune Job, along with its pods, and the objects
+// it needs
+func (r *NodeFeatureDiscoveryReconciler) deletePrune(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) error {
+	name := deployment.PruneName(ins)
+	objs := []client.Object{
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ins.GetNamespace()}},
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: name}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: name}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ins.GetNamespace()}},
+	}
+	for _, obj := range objs {
+		err := r.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// jobCondition returns the given condition of the Job if it's true, or nil
+func jobCondition(job *batchv1.Job, condType batchv1.JobConditionType) *batchv1.JobCondition {
+	for i := range job.Status.Conditions {
+		if c := &job.Status.Conditions[i]; c.Type == condType && c.Status == corev1.ConditionTrue {
+			return c
+		}
+	}
+	return nil
+}
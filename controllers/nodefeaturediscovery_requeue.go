@@ -0,0 +1,50 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// baseNotReadyDelay and maxNotReadyDelay bound the backoff of the
+	// reconciles of a CR waiting for its resources to be ready. The
+	// changes of the operand workloads trigger a reconcile anyway, so
+	// the requeue is only a fallback for the resources that aren't
+	// watched.
+	baseNotReadyDelay = 2 * time.Second
+	maxNotReadyDelay  = 2 * time.Minute
+)
+
+// notReadyDelay returns the delay before reconciling again a CR waiting
+// for its resources to be ready, which doubles with each consecutive wait
+func (r *NodeFeatureDiscoveryReconciler) notReadyDelay(key types.NamespacedName) time.Duration {
+	if r.waits == nil {
+		r.waits = map[types.NamespacedName]int{}
+	}
+	delay := baseNotReadyDelay
+	for i := 0; i < r.waits[key] && delay < maxNotReadyDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxNotReadyDelay {
+		delay = maxNotReadyDelay
+	}
+	r.waits[key]++
+	return delay
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+const (
+	// senderQueueSize bounds the number of POSTs waiting to be sent. The
+	// ones beyond are dropped.
+	senderQueueSize = 100
+
+	// senderTimeout bounds the time spent sending a POST
+	senderTimeout = 10 * time.Second
+)
+
+// post is a JSON payload to POST to an endpoint given in a CR
+type post struct {
+	ctx    context.Context
+	url    string
+	data   []byte
+	logger logr.Logger
+}
+
+// sender POSTs the notifications and the telemetry reports to the
+// endpoints given in the CRs. The POSTs are queued and sent one at a time
+// in the background, so that a slow or unreachable endpoint doesn't hold
+// up the reconciles. They're best effort: failures are only logged, and
+// the POSTs are dropped when the queue is full. The endpoints are checked
+// again before sending, as the URLs read from secrets aren't validated by
+// the webhook, and the internal addresses are refused when connecting.
+type sender struct {
+	queue  chan post
+	client *http.Client
+}
+
+// newSender returns a sender, whose POSTs are sent once it's started by
+// the manager
+func newSender() *sender {
+	dialer := &net.Dialer{
+		Timeout: senderTimeout,
+		Control: refuseInternalAddress(proxyHosts()),
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	return &sender{
+		queue: make(chan post, senderQueueSize),
+		client: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 10 {
+					return fmt.Errorf("stopped after 10 redirects")
+				}
+				return nfdv1.ValidateEndpoint(req.URL.String())
+			},
+		},
+	}
+}
+
+// send queues the JSON payload to be POSTed to the given URL, giving up
+// after senderTimeout or once ctx is done. Nothing is sent by the
+// reconcilers not set up with a manager, e.g. in the tests.
+func (s *sender) send(ctx context.Context, logger logr.Logger, url string, data []byte) {
+	if s == nil {
+		return
+	}
+	if err := nfdv1.ValidateEndpoint(url); err != nil {
+		logger.Error(err, "Refusing to send to the endpoint")
+		return
+	}
+	select {
+	case s.queue <- post{ctx: ctx, url: url, data: data, logger: logger}:
+	default:
+		logger.Info("Too many POSTs pending, dropping", "pending", len(s.queue))
+	}
+}
+
+// Start sends the queued POSTs until ctx is done. It implements the
+// manager Runnable, so that only the leader sends.
+func (s *sender) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case p := <-s.queue:
+			if err := s.post(p); err != nil {
+				p.logger.Error(err, "Couldn't send to the endpoint")
+			}
+		}
+	}
+}
+
+// post sends a queued POST
+func (s *sender) post(p post) error {
+	ctx, cancel := context.WithTimeout(p.ctx, senderTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(p.data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected HTTP status %q", resp.Status)
+	}
+	return nil
+}
+
+// proxyHosts returns the hosts of the HTTPS proxy set in the environment,
+// which may have an internal address
+func proxyHosts() map[string]bool {
+	hosts := map[string]bool{}
+	for _, env := range []string{"HTTPS_PROXY", "https_proxy"} {
+		if u, err := url.Parse(os.Getenv(env)); err == nil && u.Hostname() != "" {
+			hosts[u.Hostname()] = true
+		}
+	}
+	return hosts
+}
+
+// refuseInternalAddress returns a dialer control function refusing the
+// connections to internal addresses, see nfdv1.InternalAddress, but to the
+// given proxies, so that the host names of the endpoints can't resolve to
+// the cluster services or to the cloud metadata servers
+func refuseInternalAddress(proxies map[string]bool) func(network, address string, _ syscall.RawConn) error {
+	resolved := map[string]bool{}
+	for host := range proxies {
+		addrs, err := net.LookupHost(host)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			resolved[addr] = true
+		}
+	}
+
+	return func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || resolved[host] || proxies[host] {
+			return nil
+		}
+		if nfdv1.InternalAddress(ip) {
+			return fmt.Errorf("refusing to connect to the internal address %s", host)
+		}
+		return nil
+	}
+}
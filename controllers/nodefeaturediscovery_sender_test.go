@@ -0,0 +1,67 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestSenderQueue(t *testing.T) {
+	s := newSender()
+	logger := logf.Log.WithName("sender-test")
+
+	s.send(context.TODO(), logger, "http://hooks.example.com/nfd", []byte("{}"))
+	if len(s.queue) != 0 {
+		t.Fatal("invalid endpoint queued")
+	}
+
+	// The POSTs beyond the size of the queue are dropped rather than
+	// holding up the caller
+	for i := 0; i < senderQueueSize+1; i++ {
+		s.send(context.TODO(), logger, "https://hooks.example.com/nfd", []byte("{}"))
+	}
+	if len(s.queue) != senderQueueSize {
+		t.Errorf("got %d queued POSTs, want %d", len(s.queue), senderQueueSize)
+	}
+
+	// Nothing is sent without a sender
+	var none *sender
+	none.send(context.TODO(), logger, "https://hooks.example.com/nfd", []byte("{}"))
+}
+
+func TestSenderRefusesInternalAddress(t *testing.T) {
+	called := false
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	s := newSender()
+	err := s.post(post{ctx: context.TODO(), url: srv.URL, data: []byte("{}"), logger: logf.Log})
+	if err == nil || !strings.Contains(err.Error(), "internal address") {
+		t.Errorf("expected the loopback address to be refused, got %v", err)
+	}
+	if called {
+		t.Error("the endpoint was reached")
+	}
+}
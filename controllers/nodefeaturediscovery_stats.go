@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+)
+
+// reconcileStatsInterval is the minimum interval between two updates of
+// the reconcile statistics that don't report a new error. Each update of
+// the status triggers another reconcile, so updating them on every
+// reconcile would never let the CR settle.
+const reconcileStatsInterval = time.Minute
+
+// recordReconcile counts a reconcile of the CR and reports it in
+// status.reconcile, along with its error, if any. Resources that aren't
+// ready yet aren't errors. The reconciles are counted in memory and
+// added to the status when it's updated, i.e. when the error changes or
+// once the last update is older than reconcileStatsInterval.
+func (r *NodeFeatureDiscoveryReconciler) recordReconcile(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, err error) error {
+	key := types.NamespacedName{Namespace: ins.GetNamespace(), Name: ins.GetName()}
+	if r.reconciles == nil {
+		r.reconciles = map[types.NamespacedName]int64{}
+	}
+	r.reconciles[key]++
+
+	if errors.Is(err, deployment.ErrResourceNotReady) {
+		err = nil
+	}
+
+	stats := ins.Status.Reconcile
+	if stats == nil {
+		stats = &nfdv1.ReconcileStatus{}
+	}
+	newError := err != nil && err.Error() != stats.LastError
+	if !newError && time.Since(stats.LastReconcileTime.Time) < reconcileStatsInterval {
+		return nil
+	}
+
+	stats.LastReconcileTime = metav1.Now()
+	stats.ReconcileCount += r.reconciles[key]
+	if err != nil {
+		stats.LastError = err.Error()
+		stats.LastErrorTime = &stats.LastReconcileTime
+	}
+	ins.Status.Reconcile = stats
+	if err := r.Status().Update(ctx, ins); err != nil {
+		return err
+	}
+	delete(r.reconciles, key)
+	return nil
+}
@@ -0,0 +1,355 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/version"
+)
+
+// Reasons of the Available, Progressing, Degraded and Upgradeable
+// conditions
+const (
+	reasonOperandsReady      = "OperandsReady"
+	reasonOperandsNotReady   = "OperandsNotReady"
+	reasonRolloutInProgress  = "RolloutInProgress"
+	reasonReconcileCompleted = "ReconcileCompleted"
+	reasonReconcileFailed    = "ReconcileFailed"
+	reasonAsExpected         = "AsExpected"
+	reasonAssetDecodeFailed  = "AssetDecodeFailed"
+	reasonAssetLoadFailed    = "AssetLoadFailed"
+)
+
+// reasonDriftReverted is the reason of the events reporting the manual
+// changes to the operand workloads that were reverted
+const reasonDriftReverted = "DriftReverted"
+
+// reasonAdopted is the reason of the events reporting the operand
+// workloads deployed outside of the operator that were taken over
+const reasonAdopted = "Adopted"
+
+// setConditions sets the given conditions on the CR and updates its
+// status, unless none of them changed. The CR becoming degraded, or
+// recovering, is recorded in an event. The timestamps are ignored, so that
+// setting the same conditions again doesn't trigger another reconcile.
+func (r *NodeFeatureDiscoveryReconciler) setConditions(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, conds ...conditionsv1.Condition) error {
+	modified := false
+	for _, cond := range conds {
+		found := conditionsv1.FindStatusCondition(ins.Status.Conditions, cond.Type)
+		if found != nil && found.Status == cond.Status && found.Reason == cond.Reason && found.Message == cond.Message {
+			continue
+		}
+		r.reportConditionTransition(ins, found, cond)
+		conditionsv1.SetStatusCondition(&ins.Status.Conditions, cond)
+		modified = true
+	}
+	if !modified {
+		return nil
+	}
+	return r.Status().Update(ctx, ins)
+}
+
+// reportReconcileError reflects a reconcile that didn't complete in the
+// conditions: a resource that isn't ready yet keeps the rollout
+// progressing, whereas any other error degrades the CR, e.g. assets that
+// can't be read, until a retry succeeds. Available is left as is, as the
+// operands of the previous rollout may still be serving.
+func (r *NodeFeatureDiscoveryReconciler) reportReconcileError(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, err error) error {
+	if errors.Is(err, deployment.ErrResourceNotReady) {
+		return r.reportWaiting(ctx, ins, err)
+	}
+
+	reason := reasonReconcileFailed
+	var assetsErr *deployment.AssetsError
+	if errors.As(err, &assetsErr) {
+		reason = reasonAssetLoadFailed
+	}
+
+	conds := []conditionsv1.Condition{}
+	if conditionsv1.FindStatusCondition(ins.Status.Conditions, conditionsv1.ConditionAvailable) == nil {
+		conds = append(conds, condition(conditionsv1.ConditionAvailable, false, reasonRolloutInProgress, ""))
+	}
+	if !deployment.UpgradeInProgress(ins) {
+		conds = append(conds, condition(conditionsv1.ConditionProgressing, false, reason, ""))
+	}
+	conds = append(conds,
+		condition(conditionsv1.ConditionDegraded, true, reason, err.Error()),
+		condition(conditionsv1.ConditionUpgradeable, false, reason, err.Error()))
+	return r.setConditions(ctx, ins, conds...)
+}
+
+// reportWaiting reflects a reconcile waiting for the resources of the
+// current state to be ready in the conditions, which keeps the rollout
+// progressing. The message names the resource that isn't ready, when err
+// tells it. Available is left as is, as for the reconcile errors.
+func (r *NodeFeatureDiscoveryReconciler) reportWaiting(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, err error) error {
+	conds := []conditionsv1.Condition{}
+	if conditionsv1.FindStatusCondition(ins.Status.Conditions, conditionsv1.ConditionAvailable) == nil {
+		conds = append(conds, condition(conditionsv1.ConditionAvailable, false, reasonRolloutInProgress, ""))
+	}
+
+	msg := fmt.Sprintf("waiting for the resources of state %q", nfd.State())
+	var notReady *deployment.NotReadyError
+	if errors.As(err, &notReady) {
+		msg = fmt.Sprintf("%s: %s", msg, notReady)
+	}
+	if !deployment.UpgradeInProgress(ins) {
+		conds = append(conds, condition(conditionsv1.ConditionProgressing, true, reasonRolloutInProgress, msg))
+	}
+	deg, err := r.degraded(ctx, ins)
+	if err != nil {
+		return err
+	}
+	conds = append(conds, deg, condition(conditionsv1.ConditionUpgradeable, false, reasonRolloutInProgress, msg))
+	return r.setConditions(ctx, ins, conds...)
+}
+
+// reportApplied reflects the readiness of the operands in the conditions
+// once all the states are applied, and records their version once they're
+// all ready. Available is set by the verification of the rollout instead,
+// when it's enabled.
+func (r *NodeFeatureDiscoveryReconciler) reportApplied(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) error {
+	notReady, err := r.notReadyOperands(ctx, ins)
+	if err != nil {
+		return err
+	}
+
+	deg, err := r.degraded(ctx, ins)
+	if err != nil {
+		return err
+	}
+	conds := []conditionsv1.Condition{deg}
+	if len(notReady) > 0 {
+		msg := "operands not ready: " + strings.Join(notReady, ", ")
+		if !ins.Spec.Verification.Enabled {
+			conds = append(conds, condition(conditionsv1.ConditionAvailable, false, reasonOperandsNotReady, msg))
+		}
+		if !deployment.UpgradeInProgress(ins) {
+			conds = append(conds, condition(conditionsv1.ConditionProgressing, true, reasonRolloutInProgress, msg))
+		}
+		conds = append(conds, condition(conditionsv1.ConditionUpgradeable, false, reasonOperandsNotReady, msg))
+		return r.setConditions(ctx, ins, conds...)
+	}
+
+	// The operands of the spec are all rolled out, record their version
+	operandVersion := deployment.OperandVersion(ins)
+	operandImage := ins.Spec.Operand.ImagePath()
+	if ins.Status.OperandVersion != operandVersion || ins.Status.OperandImage != operandImage ||
+		ins.Status.OperatorVersion != version.Version {
+		ins.Status.OperandVersion = operandVersion
+		ins.Status.OperandImage = operandImage
+		ins.Status.OperatorVersion = version.Version
+		if err := r.Status().Update(ctx, ins); err != nil {
+			return err
+		}
+	}
+
+	if !ins.Spec.Verification.Enabled {
+		conds = append(conds, condition(conditionsv1.ConditionAvailable, true, reasonOperandsReady, ""))
+	}
+	upgradeable := condition(conditionsv1.ConditionUpgradeable, true, reasonAsExpected, "")
+	if !deployment.UpgradeInProgress(ins) {
+		conds = append(conds, condition(conditionsv1.ConditionProgressing, false, reasonReconcileCompleted, ""))
+	} else {
+		upgradeable = condition(conditionsv1.ConditionUpgradeable, false, reasonRolloutInProgress, "operand upgrade in progress")
+	}
+	if deg.Status == corev1.ConditionTrue {
+		upgradeable = condition(conditionsv1.ConditionUpgradeable, false, deg.Reason, deg.Message)
+	}
+	conds = append(conds, upgradeable)
+	return r.setConditions(ctx, ins, conds...)
+}
+
+// degraded returns the Degraded condition of a reconcile that didn't fail.
+// The CR is still degraded if some assets couldn't be decoded, as their
+// resources are missing from the rollout, or if operand pods are stuck
+// failing.
+func (r *NodeFeatureDiscoveryReconciler) degraded(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) (conditionsv1.Condition, error) {
+	if err := nfd.DecodeError(); err != nil {
+		return condition(conditionsv1.ConditionDegraded, true, reasonAssetDecodeFailed, err.Error()), nil
+	}
+
+	failing, err := r.failingOperandPods(ctx, ins)
+	if err != nil {
+		return conditionsv1.Condition{}, err
+	}
+	if failing == "" {
+		return condition(conditionsv1.ConditionDegraded, false, reasonAsExpected, ""), nil
+	}
+	return condition(conditionsv1.ConditionDegraded, true, reasonOperandPodsFailing, failing), nil
+}
+
+// reportComponents records in the status the readiness of the operands
+// whose states were applied by this reconcile, along with the rollout of
+// nfd-master and nfd-worker, with a single update unless nothing changed.
+// The transition time only changes when an operand becomes ready or not
+// ready.
+func (r *NodeFeatureDiscoveryReconciler) reportComponents(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) error {
+	modified := false
+	rollouts := nfd.Rollouts()
+	if rollouts.Master != nil && (ins.Status.Master == nil || *ins.Status.Master != *rollouts.Master) {
+		ins.Status.Master = rollouts.Master
+		modified = true
+	}
+	if rollouts.Worker != nil && (ins.Status.Worker == nil || *ins.Status.Worker != *rollouts.Worker) {
+		ins.Status.Worker = rollouts.Worker
+		modified = true
+	}
+	for name, res := range nfd.Components() {
+		found, ok := ins.Status.Components[name]
+		if res == nil {
+			if ok {
+				delete(ins.Status.Components, name)
+				modified = true
+			}
+			continue
+		}
+		if ok && found.Ready == res.Ready && found.Message == res.Message {
+			continue
+		}
+
+		status := nfdv1.ComponentStatus{
+			Ready:              res.Ready,
+			Message:            res.Message,
+			LastTransitionTime: metav1.Now(),
+		}
+		if ok && found.Ready == res.Ready {
+			status.LastTransitionTime = found.LastTransitionTime
+		}
+		var previous *nfdv1.ComponentStatus
+		if ok {
+			previous = &found
+		}
+		r.reportComponentTransition(ins, name, previous, status)
+		if ins.Status.Components == nil {
+			ins.Status.Components = map[string]nfdv1.ComponentStatus{}
+		}
+		ins.Status.Components[name] = status
+		modified = true
+	}
+	if !modified {
+		return nil
+	}
+	return r.Status().Update(ctx, ins)
+}
+
+// reportDrift emits a warning event for each operand workload whose
+// manual changes were reverted by this reconcile, listing the fields that
+// differed from the rendered spec
+func (r *NodeFeatureDiscoveryReconciler) reportDrift(ins *nfdv1.NodeFeatureDiscovery) {
+	drifts := nfd.Drifts()
+	objs := make([]string, 0, len(drifts))
+	for obj := range drifts {
+		objs = append(objs, obj)
+	}
+	sort.Strings(objs)
+	for _, obj := range objs {
+		r.warn(ins, reasonDriftReverted, fmt.Sprintf("reverted the manual changes to %s: %s", obj, strings.Join(drifts[obj], ", ")))
+	}
+}
+
+// reportAdoptions emits an event for each operand workload deployed
+// outside of the operator that this reconcile adopted, or deleted as a
+// duplicate of an adopted one
+func (r *NodeFeatureDiscoveryReconciler) reportAdoptions(ins *nfdv1.NodeFeatureDiscovery) {
+	adoptions := nfd.Adoptions()
+	objs := make([]string, 0, len(adoptions))
+	for obj := range adoptions {
+		objs = append(objs, obj)
+	}
+	sort.Strings(objs)
+	for _, obj := range objs {
+		r.event(ins, reasonAdopted, fmt.Sprintf("%s deployed outside of the operator: %s", obj, adoptions[obj]))
+	}
+}
+
+// notReadyOperands returns the operand workloads of the CR whose pods
+// aren't all available
+func (r *NodeFeatureDiscoveryReconciler) notReadyOperands(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) ([]string, error) {
+	notReady := []string{}
+
+	deployments := []string{"nfd-master"}
+	if ins.Spec.GC.Enable {
+		deployments = append(deployments, "nfd-gc")
+	}
+	for _, name := range deployments {
+		d := &appsv1.Deployment{}
+		err := r.Get(ctx, types.NamespacedName{Namespace: ins.GetNamespace(), Name: deployment.InstanceName(ins, name)}, d)
+		if k8serrors.IsNotFound(err) {
+			notReady = append(notReady, name)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		replicas := int32(1)
+		if d.Spec.Replicas != nil {
+			replicas = *d.Spec.Replicas
+		}
+		if d.Status.ObservedGeneration < d.Generation || d.Status.UpdatedReplicas < replicas || d.Status.AvailableReplicas < replicas {
+			notReady = append(notReady, fmt.Sprintf("%s (%d/%d available)", name, d.Status.AvailableReplicas, replicas))
+		}
+	}
+
+	daemonSets := []string{"nfd-worker"}
+	if ins.Spec.TopologyUpdater.Enable {
+		daemonSets = append(daemonSets, "nfd-topology-updater")
+	}
+	for _, name := range daemonSets {
+		ds := &appsv1.DaemonSet{}
+		err := r.Get(ctx, types.NamespacedName{Namespace: ins.GetNamespace(), Name: deployment.InstanceName(ins, name)}, ds)
+		if k8serrors.IsNotFound(err) {
+			notReady = append(notReady, name)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		s := ds.Status
+		if s.ObservedGeneration < ds.Generation || s.UpdatedNumberScheduled < s.DesiredNumberScheduled || s.NumberAvailable < s.DesiredNumberScheduled {
+			notReady = append(notReady, fmt.Sprintf("%s (%d/%d available)", name, s.NumberAvailable, s.DesiredNumberScheduled))
+		}
+	}
+
+	return notReady, nil
+}
+
+// condition returns a condition of the given type and status
+func condition(t conditionsv1.ConditionType, status bool, reason, message string) conditionsv1.Condition {
+	c := conditionsv1.Condition{
+		Type:    t,
+		Status:  corev1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	}
+	if status {
+		c.Status = corev1.ConditionTrue
+	}
+	return c
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// featureTaintPrefix is the prefix of the keys of the taints set by
+// nfd-master
+const featureTaintPrefix = "feature.node.kubernetes.io/"
+
+// removeFeatureTaints removes the taints set by nfd-master from the nodes
+// once tainting has been turned off, as nfd-master only stops adding new
+// ones. The taints are kept as long as another NFD instance has tainting
+// enabled, since there's no telling which instance set them.
+func (r *NodeFeatureDiscoveryReconciler) removeFeatureTaints(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) error {
+	// Nothing to do in the common case, where no node is tainted
+	if counts, ok := r.nodes.Counts(); ok && counts.Tainted == 0 {
+		return nil
+	}
+
+	list := &nfdv1.NodeFeatureDiscoveryList{}
+	if err := r.List(ctx, list); err != nil {
+		return err
+	}
+	for _, other := range list.Items {
+		if other.GetUID() != ins.GetUID() && other.Spec.EnableTaints {
+			return nil
+		}
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return err
+	}
+	for _, node := range nodes.Items {
+		if !hasFeatureTaints(&node) {
+			continue
+		}
+		r.Log.Info("Tainting disabled, removing the feature taints", "node", node.Name)
+		if err := r.removeNodeFeatureTaints(ctx, node.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeNodeFeatureTaints removes the taints set by nfd-master from the
+// given node. The taints are a list, replaced as a whole by the patch, so
+// the patch is only applied to the version of the node it was computed
+// from.
+func (r *NodeFeatureDiscoveryReconciler) removeNodeFeatureTaints(ctx context.Context, name string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node := &corev1.Node{}
+		if err := r.Get(ctx, types.NamespacedName{Name: name}, node); err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if !hasFeatureTaints(node) {
+			return nil
+		}
+
+		patch := client.MergeFromWithOptions(node.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		taints := []corev1.Taint{}
+		for _, t := range node.Spec.Taints {
+			if !strings.HasPrefix(t.Key, featureTaintPrefix) {
+				taints = append(taints, t)
+			}
+		}
+		node.Spec.Taints = taints
+
+		err := r.Patch(ctx, node, patch)
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	})
+}
+
+// hasFeatureTaints returns true if the node has taints set by nfd-master
+func hasFeatureTaints(node *corev1.Node) bool {
+	for _, t := range node.Spec.Taints {
+		if strings.HasPrefix(t.Key, featureTaintPrefix) {
+			return true
+		}
+	}
+	return false
+}
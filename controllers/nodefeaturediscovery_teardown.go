@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+)
+
+// reasonTearingDown is the reason of the Available and Progressing
+// conditions of a NodeFeatureDiscovery object being deleted
+const reasonTearingDown = "TearingDown"
+
+// The teardown phases, as reported in the Progressing condition
+const (
+	teardownStoppingWorkers = "stopping the operands publishing the labels"
+	teardownPruning         = "running the prune Job"
+	teardownCleaningNodes   = "removing the NFD labels from the nodes, see status.cleanup"
+	teardownRemovingMaster  = "removing nfd-master and the RBAC"
+)
+
+// teardownPollInterval is the delay between the checks of the operand
+// pods being stopped
+const teardownPollInterval = 5 * time.Second
+
+// operandWorkloads are the operands whose workloads the finalizer deletes.
+// The other ones are garbage collected along with the NodeFeatureDiscovery
+// object.
+var operandWorkloads = []string{"nfd-worker", "nfd-master"}
+
+// publishers returns the operands publishing the NFD labels, which are
+// stopped before the nodes are cleaned up. With the NodeFeature API,
+// nfd-master labels the nodes from the NodeFeature objects nfd-worker
+// leaves behind, so it's stopped along with nfd-worker. It's stopped as
+// well before the prune Job runs, as both update the same nodes and
+// nfd-master would publish again what the Job removes.
+func publishers(ins *nfdv1.NodeFeatureDiscovery) []string {
+	if ins.Spec.EnableNodeFeatureAPI || pruneNeeded(ins) {
+		return operandWorkloads
+	}
+	return []string{"nfd-worker"}
+}
+
+// stopPublishers deletes the workloads of the operands publishing the NFD
+// labels and returns true once their pods are gone. The pods of an
+// instance still deployed by another NodeFeatureDiscovery object aren't
+// waited for, as they keep running.
+func (r *NodeFeatureDiscoveryReconciler) stopPublishers(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, inUse bool) (bool, error) {
+	apps := publishers(ins)
+	if err := r.deleteOperands(ctx, ins, apps...); err != nil {
+		return false, err
+	}
+	if inUse {
+		return true, nil
+	}
+
+	for _, app := range apps {
+		pods := &corev1.PodList{}
+		if err := r.List(ctx, pods, client.InNamespace(ins.GetNamespace()), deployment.PodSelector(ins, app)); err != nil {
+			return false, err
+		}
+		if len(pods.Items) > 0 {
+			r.Log.Info("Waiting for the operand pods to stop", "app", app, "pods", len(pods.Items))
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// reportTeardown reflects the teardown phase of a NodeFeatureDiscovery
+// object being deleted in the conditions. Degraded is left as is, so that
+// a failure of the operands is still reported.
+func (r *NodeFeatureDiscoveryReconciler) reportTeardown(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, phase string) error {
+	return r.setConditions(ctx, ins,
+		condition(conditionsv1.ConditionAvailable, false, reasonTearingDown, "the NodeFeatureDiscovery object is being deleted"),
+		condition(conditionsv1.ConditionProgressing, true, reasonTearingDown, phase))
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/version"
+)
+
+const (
+	// telemetryConfigMapPrefix is the prefix of the name of the ConfigMap
+	// the telemetry report is written to if none is given in the CR,
+	// followed by the name of the CR
+	telemetryConfigMapPrefix = "nfd-telemetry-"
+
+	// telemetryReportKey is the ConfigMap key holding the report
+	telemetryReportKey = "report.json"
+
+	// featureLabelPrefix is the prefix of the labels published by NFD
+	featureLabelPrefix = "feature.node.kubernetes.io/"
+)
+
+// telemetryReport is the anonymized, aggregate data reported when
+// telemetry is enabled. It must never contain node names or any other
+// data identifying the cluster.
+type telemetryReport struct {
+	OperatorVersion  string   `json:"operatorVersion"`
+	OperandVersion   string   `json:"operandVersion"`
+	Components       []string `json:"components"`
+	NodeCount        int      `json:"nodeCount"`
+	LabeledNodeCount int      `json:"labeledNodeCount"`
+}
+
+// reportTelemetry builds the telemetry report for the given CR and, if it
+// changed, writes it to the telemetry ConfigMap, then queues it to be sent
+// to the configured endpoint, if any. The ConfigMap is the report; the
+// endpoint is only notified, on a best effort basis, see sender.
+// Telemetry is best effort as well: errors are only logged.
+func (r *NodeFeatureDiscoveryReconciler) reportTelemetry(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) {
+	logger := r.Log.WithValues("telemetry", ins.Name)
+
+	report, err := r.buildTelemetryReport(ctx, ins)
+	if err != nil {
+		logger.Error(err, "Couldn't build telemetry report")
+		return
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		logger.Error(err, "Couldn't encode telemetry report")
+		return
+	}
+
+	found, err := r.getTelemetryConfigMap(ctx, ins)
+	if err != nil {
+		logger.Error(err, "Couldn't read telemetry ConfigMap")
+		return
+	}
+	if found != nil && found.Data[telemetryReportKey] == string(data) {
+		return
+	}
+
+	if err := r.writeTelemetryConfigMap(ctx, ins, found, string(data)); err != nil {
+		logger.Error(err, "Couldn't write telemetry ConfigMap")
+		return
+	}
+	if endpoint := ins.Spec.Telemetry.Endpoint; endpoint != "" {
+		r.sender.send(ctx, logger.WithValues("endpoint", endpoint), endpoint, data)
+	}
+}
+
+// buildTelemetryReport gathers the aggregate data for the telemetry report
+func (r *NodeFeatureDiscoveryReconciler) buildTelemetryReport(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) (*telemetryReport, error) {
+	counts, err := r.nodeCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	components := []string{"nfd-master", "nfd-worker"}
+	if ins.Spec.TopologyUpdater.Enable {
+		components = append(components, "nfd-topology-updater")
+	}
+	if ins.Spec.GC.Enable {
+		components = append(components, "nfd-gc")
+	}
+
+	return &telemetryReport{
+		OperatorVersion:  version.Version,
+		OperandVersion:   deployment.ImageTag(ins.Spec.Operand.ImagePath()),
+		Components:       components,
+		NodeCount:        counts.Nodes,
+		LabeledNodeCount: counts.Labeled,
+	}, nil
+}
+
+// telemetryConfigMapName returns the name of the telemetry ConfigMap of
+// the CR
+func telemetryConfigMapName(ins *nfdv1.NodeFeatureDiscovery) string {
+	if ins.Spec.Telemetry.ConfigMapName != "" {
+		return ins.Spec.Telemetry.ConfigMapName
+	}
+	return telemetryConfigMapPrefix + ins.GetName()
+}
+
+// getTelemetryConfigMap returns the telemetry ConfigMap of the CR, or nil
+// if it doesn't exist. A ConfigMap controlled by another object, e.g.
+// another CR configured with the same name, is reported as an error, so
+// that the CRs don't overwrite each other's report.
+func (r *NodeFeatureDiscoveryReconciler) getTelemetryConfigMap(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) (*corev1.ConfigMap, error) {
+	found := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: ins.GetNamespace(), Name: telemetryConfigMapName(ins)}, found)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if owner := metav1.GetControllerOf(found); owner != nil && owner.UID != ins.GetUID() {
+		return nil, fmt.Errorf("ConfigMap %s is controlled by %s %s", found.Name, owner.Kind, owner.Name)
+	}
+	return found, nil
+}
+
+// writeTelemetryConfigMap creates the telemetry ConfigMap, or updates the
+// one found, with the given report
+func (r *NodeFeatureDiscoveryReconciler) writeTelemetryConfigMap(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, found *corev1.ConfigMap, report string) error {
+	obj := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: telemetryConfigMapName(ins), Namespace: ins.GetNamespace()},
+		Data:       map[string]string{telemetryReportKey: report},
+	}
+	if err := controllerutil.SetControllerReference(ins, obj, r.Scheme); err != nil {
+		return err
+	}
+
+	if found == nil {
+		return r.Create(ctx, obj)
+	}
+	obj.ResourceVersion = found.ResourceVersion
+	return r.Update(ctx, obj)
+}
+
+// hasFeatureLabels returns true if any of the given labels was published
+// by NFD
+func hasFeatureLabels(labels map[string]string) bool {
+	for k := range labels {
+		if strings.HasPrefix(k, featureLabelPrefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+)
+
+// reasonLabelNsPolicyViolation is the reason of the conditions of a CR
+// that isn't reconciled because it breaks the label namespace policy of
+// its namespace
+const reasonLabelNsPolicyViolation = "LabelNsPolicyViolation"
+
+// checkLabelNsPolicy checks the NodeFeatureDiscovery object against the
+// label namespace policy of its namespace, see
+// nfdv1.AllowedLabelNsAnnotation. It returns true if a policy applies, in
+// which case nfd-master is denied the other label namespaces, and why the
+// object breaks it, if it does: it requests label namespaces that aren't
+// allowed, or the operand is too old for nfd-master to enforce the policy.
+func (r *NodeFeatureDiscoveryReconciler) checkLabelNsPolicy(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) (bool, string, error) {
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ins.GetNamespace()}, ns); err != nil {
+		return false, "", err
+	}
+
+	allowed, ok := nfdv1.LabelNsPolicy(ns)
+	if !ok {
+		return false, "", nil
+	}
+
+	violations := []string{}
+	for _, err := range ins.Spec.ValidateLabelNsPolicy(field.NewPath("spec"), allowed) {
+		violations = append(violations, err.Error())
+	}
+	if !deployment.FlagSupported(ins, "nfd-master", "--deny-label-ns") {
+		violations = append(violations, fmt.Sprintf("operand version %s doesn't support --deny-label-ns, which enforces the %s annotation of the namespace",
+			deployment.OperandVersion(ins), nfdv1.AllowedLabelNsAnnotation))
+	}
+	return true, strings.Join(violations, "; "), nil
+}
+
+// rejectLabelNsPolicy reports that ins isn't reconciled because it breaks
+// the label namespace policy of its namespace. Available is left as is,
+// as the operands of the previous rollout may still be serving.
+func (r *NodeFeatureDiscoveryReconciler) rejectLabelNsPolicy(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, msg string) error {
+	return r.setConditions(ctx, ins,
+		condition(conditionsv1.ConditionProgressing, false, reasonLabelNsPolicyViolation, msg),
+		condition(conditionsv1.ConditionDegraded, true, reasonLabelNsPolicyViolation, msg),
+		condition(conditionsv1.ConditionUpgradeable, false, reasonLabelNsPolicyViolation, msg))
+}
+
+// labelNsPolicyChanged only lets through the updates of the namespaces
+// changing their label namespace policy
+var labelNsPolicyChanged = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		return false
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return e.ObjectOld.GetAnnotations()[nfdv1.AllowedLabelNsAnnotation] !=
+			e.ObjectNew.GetAnnotations()[nfdv1.AllowedLabelNsAnnotation]
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return false
+	},
+	GenericFunc: func(e event.GenericEvent) bool {
+		return false
+	},
+}
+
+// namespaceToRequests maps a namespace to reconcile requests for the
+// NodeFeatureDiscovery objects living in it
+func (r *NodeFeatureDiscoveryReconciler) namespaceToRequests(obj client.Object) []reconcile.Request {
+	nfdList := &nfdv1.NodeFeatureDiscoveryList{}
+	if err := r.List(context.TODO(), nfdList, client.InNamespace(obj.GetName())); err != nil {
+		r.Log.Error(err, "could not list NodeFeatureDiscovery objects", "Namespace", obj.GetName())
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, i := range nfdList.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: i.Namespace, Name: i.Name},
+		})
+	}
+	return requests
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+)
+
+// nodeResourceTopologyGK is the kind of the objects nfd-master publishes
+// for nfd-topology-updater. Its version is looked up, as it changed across
+// the releases of the CRD.
+var nodeResourceTopologyGK = schema.GroupKind{Group: "topology.node.k8s.io", Kind: "NodeResourceTopology"}
+
+// updateTopologyStatus reports the health of nfd-topology-updater in the
+// status of the CR: the readiness of its DaemonSet, and how many of the
+// nodes it runs on have a NodeResourceTopology object. The status is only
+// updated when it changed, and dropped when nfd-topology-updater is
+// disabled.
+func (r *NodeFeatureDiscoveryReconciler) updateTopologyStatus(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) error {
+	var status *nfdv1.TopologyUpdaterStatus
+	if ins.Spec.TopologyUpdater.Enable {
+		s, err := r.topologyStatus(ctx, ins)
+		if err != nil {
+			return err
+		}
+		status = s
+	}
+
+	if equality.Semantic.DeepEqual(ins.Status.TopologyUpdater, status) {
+		return nil
+	}
+	ins.Status.TopologyUpdater = status
+	return r.Status().Update(ctx, ins)
+}
+
+// topologyStatus returns the health of nfd-topology-updater
+func (r *NodeFeatureDiscoveryReconciler) topologyStatus(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) (*nfdv1.TopologyUpdaterStatus, error) {
+	status := &nfdv1.TopologyUpdaterStatus{}
+
+	ds := &appsv1.DaemonSet{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: ins.GetNamespace(), Name: deployment.InstanceName(ins, "nfd-topology-updater")}, ds)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	status.DesiredNumberScheduled = ds.Status.DesiredNumberScheduled
+	status.NumberReady = ds.Status.NumberReady
+	status.NumberUnavailable = ds.Status.NumberUnavailable
+
+	mapping, err := r.RESTMapper().RESTMapping(nodeResourceTopologyGK)
+	if meta.IsNoMatchError(err) {
+		status.Message = "the NodeResourceTopology CRD isn't installed"
+		return status, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Only the objects of existing nodes are counted, as those of the
+	// nodes that are gone are left until nfd-gc deletes them
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, n := range nodes.Items {
+		names[n.Name] = true
+	}
+
+	topologies := &unstructured.UnstructuredList{}
+	topologies.SetGroupVersionKind(mapping.GroupVersionKind.GroupVersion().WithKind(nodeResourceTopologyGK.Kind + "List"))
+	if err := r.List(ctx, topologies); err != nil {
+		return nil, err
+	}
+	for _, t := range topologies.Items {
+		if names[t.GetName()] {
+			status.NodeResourceTopologies++
+		}
+	}
+
+	if status.NodeResourceTopologies < int(status.DesiredNumberScheduled) {
+		status.Message = "some nodes running nfd-topology-updater have no NodeResourceTopology object"
+	}
+	return status, nil
+}
@@ -0,0 +1,366 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+const (
+	// triggerResync, triggerRequeue and triggerRetry are the causes of
+	// the reconciles that weren't triggered by a watch event: the
+	// periodic resync of the informers, a requeue asked for by a
+	// previous reconcile (e.g. for the heartbeat) and the retry of a
+	// failed reconcile
+	triggerResync  = "resync"
+	triggerRequeue = "requeue"
+	triggerRetry   = "retry"
+
+	// maxTriggerCauses bounds the number of causes logged for a single
+	// reconcile, as a rollout on a large cluster updates many pods at
+	// once
+	maxTriggerCauses = 5
+
+	// triggerSummaryWindow and triggerSummaryThreshold define a busy CR:
+	// one reconciled more than the threshold within the window. The
+	// causes of its reconciles are then summarized in an Event, rather
+	// than recording an Event per reconcile, which would be throttled
+	// along with the warnings about the CR.
+	triggerSummaryWindow    = 10 * time.Minute
+	triggerSummaryThreshold = 30
+
+	reasonReconcileTriggers = "FrequentReconciles"
+)
+
+// triggerCause describes a watch event that led to a reconcile
+type triggerCause struct {
+	// kind is the kind of the object the event is about
+	kind string
+
+	// name is the name of the object the event is about
+	name string
+
+	// action is what happened to the object
+	action string
+}
+
+// String returns the cause as logged, e.g. "DaemonSet/nfd-worker updated"
+func (c triggerCause) String() string {
+	return fmt.Sprintf("%s/%s %s", c.kind, c.name, c.action)
+}
+
+// category returns the cause without the object name, used to summarize
+// the causes of many reconciles
+func (c triggerCause) category() string {
+	if c.action == triggerResync {
+		return triggerResync
+	}
+	return c.kind + " " + c.action
+}
+
+// triggerStats are the reconciles of a CR within the current summary
+// window
+type triggerStats struct {
+	start      time.Time
+	reconciles int
+	categories map[string]int
+}
+
+// triggerTracker records the watch events that led to the reconcile of
+// each CR. The requests of the work queue only carry the name of the CR,
+// so the event handlers record the cause of the requests they add, and
+// the reconcile takes the causes recorded for its CR.
+type triggerTracker struct {
+	// mu protects the fields below
+	mu sync.Mutex
+
+	// pending holds the causes recorded since the last reconcile
+	pending map[types.NamespacedName][]triggerCause
+
+	// failed records the CRs whose last reconcile failed
+	failed map[types.NamespacedName]bool
+
+	// stats holds the reconciles of the current summary window
+	stats map[types.NamespacedName]*triggerStats
+}
+
+// newTriggerTracker returns an empty trigger tracker
+func newTriggerTracker() *triggerTracker {
+	return &triggerTracker{
+		pending: map[types.NamespacedName][]triggerCause{},
+		failed:  map[types.NamespacedName]bool{},
+		stats:   map[types.NamespacedName]*triggerStats{},
+	}
+}
+
+// record records a cause for the next reconcile of the CR
+func (t *triggerTracker) record(key types.NamespacedName, cause triggerCause) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, c := range t.pending[key] {
+		if c == cause {
+			return
+		}
+	}
+	t.pending[key] = append(t.pending[key], cause)
+}
+
+// take returns the causes of the reconcile of the CR about to start and
+// forgets about them. Reconciles with no recorded cause are requeues or
+// retries of the previous reconcile.
+func (t *triggerTracker) take(key types.NamespacedName) []string {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	causes := t.pending[key]
+	delete(t.pending, key)
+
+	categories := []string{}
+	for _, c := range causes {
+		categories = append(categories, c.category())
+	}
+	if len(causes) == 0 {
+		cause := triggerRequeue
+		if t.failed[key] {
+			cause = triggerRetry
+		}
+		categories = append(categories, cause)
+	}
+	t.count(key, categories)
+
+	logged := []string{}
+	for i, c := range causes {
+		if i == maxTriggerCauses {
+			logged = append(logged, fmt.Sprintf("%d more", len(causes)-i))
+			break
+		}
+		logged = append(logged, c.String())
+	}
+	if len(causes) == 0 {
+		logged = categories
+	}
+	return logged
+}
+
+// count adds a reconcile to the stats of the CR, t.mu must be held
+func (t *triggerTracker) count(key types.NamespacedName, categories []string) {
+	stats, ok := t.stats[key]
+	if !ok || time.Since(stats.start) > triggerSummaryWindow {
+		stats = &triggerStats{start: time.Now(), categories: map[string]int{}}
+		t.stats[key] = stats
+	}
+	stats.reconciles++
+	for _, c := range categories {
+		stats.categories[c]++
+	}
+}
+
+// done records the outcome of the reconcile of the CR
+func (t *triggerTracker) done(key types.NamespacedName, err error) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err != nil {
+		t.failed[key] = true
+	} else {
+		delete(t.failed, key)
+	}
+}
+
+// forget drops everything recorded about a CR once it's gone
+func (t *triggerTracker) forget(key types.NamespacedName) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.pending, key)
+	delete(t.failed, key)
+	delete(t.stats, key)
+}
+
+// summary returns a summary of the causes of the reconciles of the CR if
+// it has been reconciled more than the threshold within the current
+// window. The stats are reset once summarized, so that the summary is
+// reported at most once per window.
+func (t *triggerTracker) summary(key types.NamespacedName) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats, ok := t.stats[key]
+	if !ok || stats.reconciles <= triggerSummaryThreshold {
+		return "", false
+	}
+	delete(t.stats, key)
+
+	// Most frequent causes first
+	categories := []string{}
+	for c := range stats.categories {
+		categories = append(categories, c)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		ci, cj := stats.categories[categories[i]], stats.categories[categories[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return categories[i] < categories[j]
+	})
+	causes := []string{}
+	for _, c := range categories {
+		causes = append(causes, fmt.Sprintf("%s (%d)", c, stats.categories[c]))
+	}
+
+	return fmt.Sprintf("%d reconciles in %s, triggered by: %s",
+		stats.reconciles, time.Since(stats.start).Round(time.Second), strings.Join(causes, ", ")), true
+}
+
+// predicate returns a predicate recording the events about the CRs
+// themselves. It doesn't filter out any event.
+func (t *triggerTracker) predicate(kind string) predicate.Predicate {
+	recordFor := func(obj client.Object, action string) bool {
+		if obj != nil {
+			t.record(client.ObjectKeyFromObject(obj), triggerCause{kind: kind, name: obj.GetName(), action: action})
+		}
+		return true
+	}
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return recordFor(e.Object, "created")
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return recordFor(e.ObjectNew, updateAction(e))
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return recordFor(e.Object, "deleted")
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return recordFor(e.Object, "generic")
+		},
+	}
+}
+
+// handler wraps an event handler, recording the events about objects of
+// the given kind as the cause of the requests it adds
+func (t *triggerTracker) handler(kind string, h handler.EventHandler) handler.EventHandler {
+	queue := func(q workqueue.RateLimitingInterface, obj client.Object, action string) workqueue.RateLimitingInterface {
+		if obj == nil {
+			return q
+		}
+		return &triggerQueue{
+			RateLimitingInterface: q,
+			tracker:               t,
+			cause:                 triggerCause{kind: kind, name: obj.GetName(), action: action},
+		}
+	}
+	return handler.Funcs{
+		CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+			h.Create(e, queue(q, e.Object, "created"))
+		},
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			h.Update(e, queue(q, e.ObjectNew, updateAction(e)))
+		},
+		DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+			h.Delete(e, queue(q, e.Object, "deleted"))
+		},
+		GenericFunc: func(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+			h.Generic(e, queue(q, e.Object, "generic"))
+		},
+	}
+}
+
+// updateAction tells an actual update apart from the periodic resync of
+// the informers, which replays the objects unchanged
+func updateAction(e event.UpdateEvent) string {
+	if e.ObjectOld != nil && e.ObjectNew != nil &&
+		e.ObjectOld.GetResourceVersion() == e.ObjectNew.GetResourceVersion() {
+		return triggerResync
+	}
+	return "updated"
+}
+
+// triggerQueue records the cause of the requests added to the work queue
+type triggerQueue struct {
+	workqueue.RateLimitingInterface
+
+	tracker *triggerTracker
+	cause   triggerCause
+}
+
+// Add implements the workqueue.Interface interface
+func (q *triggerQueue) Add(item interface{}) {
+	q.recordFor(item)
+	q.RateLimitingInterface.Add(item)
+}
+
+// AddAfter implements the workqueue.DelayingInterface interface
+func (q *triggerQueue) AddAfter(item interface{}, duration time.Duration) {
+	q.recordFor(item)
+	q.RateLimitingInterface.AddAfter(item, duration)
+}
+
+// AddRateLimited implements the workqueue.RateLimitingInterface interface
+func (q *triggerQueue) AddRateLimited(item interface{}) {
+	q.recordFor(item)
+	q.RateLimitingInterface.AddRateLimited(item)
+}
+
+// recordFor records the cause for the CR of the request
+func (q *triggerQueue) recordFor(item interface{}) {
+	if req, ok := item.(reconcile.Request); ok {
+		q.tracker.record(req.NamespacedName, q.cause)
+	}
+}
+
+// reportTriggers records an Event summarizing the causes of the
+// reconciles of a busy CR, so that a high reconcile rate can be
+// attributed without going through the operator logs
+func (r *NodeFeatureDiscoveryReconciler) reportTriggers(ins *nfdv1.NodeFeatureDiscovery) {
+	msg, ok := r.triggers.summary(client.ObjectKeyFromObject(ins))
+	if !ok {
+		return
+	}
+	r.event(ins, reasonReconcileTriggers, msg)
+}
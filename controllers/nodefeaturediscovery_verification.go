@@ -0,0 +1,199 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
+)
+
+const (
+	// defaultVerificationLabel and defaultVerificationTimeout are used
+	// if the CR doesn't define them. The kernel version is labeled by
+	// all the NFD versions, with the default worker configuration.
+	defaultVerificationLabel   = nfdv1.DefaultVerificationLabel
+	defaultVerificationTimeout = nfdv1.DefaultVerificationTimeout
+
+	// verificationPollInterval is the time between two checks of the
+	// sampled node while the label is waited for
+	verificationPollInterval = 10 * time.Second
+
+	reasonVerificationPending   = "VerificationPending"
+	reasonVerificationSucceeded = "VerificationSucceeded"
+	reasonVerificationFailed    = "VerificationFailed"
+)
+
+// verifyRollout checks, once the operands are ready, that the baseline
+// label shows up on a node running nfd-worker, and only then sets the
+// Available condition. The verification restarts with each generation of
+// the CR. A failed verification keeps being checked on the following
+// reconciles, so that a late label still makes the operands available.
+func (r *NodeFeatureDiscoveryReconciler) verifyRollout(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery) (ctrl.Result, error) {
+	if !ins.Spec.Verification.Enabled {
+		if ins.Status.Verification == nil {
+			return ctrl.Result{}, nil
+		}
+		ins.Status.Verification = nil
+		return ctrl.Result{}, r.Status().Update(ctx, ins)
+	}
+
+	label := ins.Spec.Verification.Label
+	if label == "" {
+		label = defaultVerificationLabel
+	}
+	timeout := defaultVerificationTimeout
+	if ins.Spec.Verification.Timeout != nil {
+		timeout = ins.Spec.Verification.Timeout.Duration
+	}
+
+	status := &nfdv1.VerificationStatus{
+		ObservedGeneration: ins.GetGeneration(),
+		Result:             nfdv1.VerificationPending,
+		Label:              label,
+		StartTime:          metav1.Now(),
+	}
+	if last := ins.Status.Verification; last != nil && last.ObservedGeneration == ins.GetGeneration() && last.Label == label {
+		status = last.DeepCopy()
+		if status.Result == nfdv1.VerificationSucceeded {
+			return ctrl.Result{}, nil
+		}
+	}
+
+	node, err := r.verificationNode(ctx, ins, status.Node)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	labeled := false
+	if node != nil {
+		_, labeled = node.Labels[label]
+	}
+
+	result := ctrl.Result{}
+	switch {
+	case labeled:
+		now := metav1.Now()
+		status.Result = nfdv1.VerificationSucceeded
+		status.Node = node.Name
+		status.CompletionTime = &now
+		status.Message = fmt.Sprintf("label %s found on node %s", label, node.Name)
+	case time.Since(status.StartTime.Time) < timeout:
+		status.Result = nfdv1.VerificationPending
+		status.Message = "no ready nfd-worker pod yet"
+		if node != nil {
+			status.Node = node.Name
+			status.Message = fmt.Sprintf("waiting for label %s on node %s", label, node.Name)
+		}
+		result.RequeueAfter = verificationPollInterval
+	case status.Result != nfdv1.VerificationFailed:
+		now := metav1.Now()
+		status.Result = nfdv1.VerificationFailed
+		status.CompletionTime = &now
+		status.Message = fmt.Sprintf("no ready nfd-worker pod within %s", timeout)
+		if node != nil {
+			status.Node = node.Name
+			status.Message = fmt.Sprintf("label %s not found on node %s within %s", label, node.Name, timeout)
+		}
+		r.warn(ins, reasonVerificationFailed, status.Message)
+	}
+
+	cond := conditionsv1.Condition{
+		Type:    conditionsv1.ConditionAvailable,
+		Status:  corev1.ConditionFalse,
+		Reason:  reasonVerificationPending,
+		Message: status.Message,
+	}
+	switch status.Result {
+	case nfdv1.VerificationSucceeded:
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = reasonVerificationSucceeded
+	case nfdv1.VerificationFailed:
+		cond.Reason = reasonVerificationFailed
+	}
+
+	found := conditionsv1.FindStatusCondition(ins.Status.Conditions, conditionsv1.ConditionAvailable)
+	condChanged := found == nil || found.Status != cond.Status || found.Reason != cond.Reason || found.Message != cond.Message
+	if !condChanged && equality.Semantic.DeepEqual(ins.Status.Verification, status) {
+		return result, nil
+	}
+	if condChanged {
+		conditionsv1.SetStatusCondition(&ins.Status.Conditions, cond)
+	}
+	ins.Status.Verification = status
+	return result, r.Status().Update(ctx, ins)
+}
+
+// verificationNode returns the node sampled for the verification: the
+// previously sampled one if it's still there, or the node of a random
+// ready nfd-worker pod. It returns nil if no nfd-worker pod is ready.
+func (r *NodeFeatureDiscoveryReconciler) verificationNode(ctx context.Context, ins *nfdv1.NodeFeatureDiscovery, name string) (*corev1.Node, error) {
+	node := &corev1.Node{}
+	if name != "" {
+		err := r.Get(ctx, types.NamespacedName{Name: name}, node)
+		if err == nil {
+			return node, nil
+		} else if !errors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(ins.GetNamespace()), deployment.PodSelector(ins, "nfd-worker")); err != nil {
+		return nil, err
+	}
+	ready := []string{}
+	for i := range pods.Items {
+		if pods.Items[i].Spec.NodeName != "" && podReady(&pods.Items[i]) {
+			ready = append(ready, pods.Items[i].Spec.NodeName)
+		}
+	}
+	if len(ready) == 0 {
+		return nil, nil
+	}
+
+	name = ready[rand.Intn(len(ready))]
+	if err := r.Get(ctx, types.NamespacedName{Name: name}, node); errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// podReady returns true if the Ready condition of the pod is true
+func podReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -18,19 +18,29 @@ package main
 
 import (
 	"flag"
+	"net"
 	"os"
+	"strconv"
+	"time"
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	nfdkubernetesiov1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	nfdkubernetesiov1alpha2 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1alpha2"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/build/assets"
 	"github.com/kubernetes-sigs/node-feature-discovery-operator/controllers"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/pkg/deployment"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -49,6 +59,8 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(nfdkubernetesiov1.AddToScheme(scheme))
+	utilruntime.Must(nfdkubernetesiov1alpha2.AddToScheme(scheme))
+	utilruntime.Must(deployment.Add3dpartyResourcesToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -57,6 +69,12 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var assetsDir string
+	var embeddedAssets bool
+	var heartbeatInterval time.Duration
+	var consolePluginAddr string
+	var consolePluginCertDir string
+	var consolePluginService string
 
 	// Setup CLI arguments
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the Prometheus "+
@@ -67,6 +85,19 @@ func main() {
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&assetsDir, "assets-dir", "/opt/nfd", "The directory holding the "+
+		"manifests of the operand resources.")
+	flag.BoolVar(&embeddedAssets, "embedded-assets", false, "Use the manifests of the operand "+
+		"resources built into the operator binary instead of the ones in --assets-dir.")
+	flag.DurationVar(&heartbeatInterval, "heartbeat-interval", 5*time.Minute, "The interval "+
+		"between two updates of the heartbeat of the NodeFeatureDiscovery conditions.")
+	flag.StringVar(&consolePluginAddr, "console-plugin-bind-address", ":9001", "The address the "+
+		"OpenShift console plugin is served on.")
+	flag.StringVar(&consolePluginCertDir, "console-plugin-cert-dir", "/var/run/console-plugin/serving-certs",
+		"The directory holding the tls.crt and tls.key serving certificate of the console plugin.")
+	flag.StringVar(&consolePluginService, "console-plugin-service", "nfd-console-plugin", "The name of "+
+		"the Service, in the namespace of the operator, forwarding to the console plugin, on the "+
+		"port of --console-plugin-bind-address.")
 
 	// opts is created using zap to set the operator's logging
 	opts := zap.Options{
@@ -88,6 +119,10 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "39f5e5c3.nodefeaturediscoveries.nfd.kubernetes.io",
+
+		// The few secrets the operator reads are read from the API
+		// server, rather than caching all the secrets of the cluster
+		ClientDisableCacheFor: []client.Object{&corev1.Secret{}},
 	})
 
 	if err != nil {
@@ -95,16 +130,74 @@ func main() {
 		os.Exit(1)
 	}
 
+	// The operand manifests are read from the filesystem by default, so
+	// that they can be customized by mounting a volume
+	var assetsProvider deployment.AssetsProvider = deployment.NewDirAssets(assetsDir)
+	if embeddedAssets {
+		assetsProvider = &deployment.FSAssets{FS: assets.FS, StateDirs: deployment.DefaultStates}
+	}
+
+	// The platform specific resources, e.g. the SecurityContextConstraints
+	// of OpenShift, are only managed when the cluster serves their API
+	dc, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create discovery client")
+		os.Exit(1)
+	}
+	platform, err := deployment.DetectPlatform(dc)
+	if err != nil {
+		setupLog.Error(err, "unable to detect the platform")
+		os.Exit(1)
+	}
+	setupLog.Info("Detected the platform", "securityContextConstraints", platform.SecurityContextConstraints,
+		"consolePlugin", platform.ConsolePlugin)
+
 	if err = (&controllers.NodeFeatureDiscoveryReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("NodeFeatureDiscovery"),
-		Scheme: mgr.GetScheme(),
+		Client:            mgr.GetClient(),
+		Log:               ctrl.Log.WithName("controllers").WithName("NodeFeatureDiscovery"),
+		Scheme:            mgr.GetScheme(),
+		Recorder:          mgr.GetEventRecorderFor("node-feature-discovery-operator"),
+		Assets:            assetsProvider,
+		HeartbeatInterval: heartbeatInterval,
+		Platform:          platform,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NodeFeatureDiscovery")
 		os.Exit(1)
 	}
+
+	// The webhooks default and validate the specs at admission time, and
+	// convert the objects between the API versions. They need the serving
+	// certificates, so they can be turned off when running the operator
+	// locally.
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&nfdkubernetesiov1.NodeFeatureDiscovery{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "NodeFeatureDiscovery")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
+	// The OpenShift console gets the NodeFeatureDiscovery status view from
+	// the plugin served by the operator
+	if platform.ConsolePlugin {
+		port, err := bindPort(consolePluginAddr)
+		if err != nil {
+			setupLog.Error(err, "invalid console plugin bind address")
+			os.Exit(1)
+		}
+		if err := mgr.Add(&controllers.ConsolePluginServer{
+			Client:  mgr.GetClient(),
+			Log:     ctrl.Log.WithName("consoleplugin"),
+			Addr:    consolePluginAddr,
+			CertDir: consolePluginCertDir,
+			Service: types.NamespacedName{Namespace: os.Getenv("POD_NAMESPACE"), Name: consolePluginService},
+			Port:    port,
+		}); err != nil {
+			setupLog.Error(err, "unable to serve the console plugin")
+			os.Exit(1)
+		}
+	}
+
 	// Next, add a Healthz checker to the manager. Healthz is a health and liveness package
 	// that the operator will use to periodically check the health of its pods, etc.
 	if err := mgr.AddHealthzCheck("health", healthz.Ping); err != nil {
@@ -119,6 +212,18 @@ func main() {
 		os.Exit(1)
 	}
 
+	// The operator is not ready until it has been granted all the permissions it
+	// needs, so that missing RBAC rules are reported up front with a precise list
+	// instead of as Forbidden errors in the middle of a reconcile.
+	preflight := controllers.NewRBACPreflight(mgr.GetClient(), mgr.GetRESTMapper())
+	if err := preflight.Check(nil); err != nil {
+		setupLog.Error(err, "RBAC preflight check failed, the operator will not be ready")
+	}
+	if err := mgr.AddReadyzCheck("rbac", preflight.Check); err != nil {
+		setupLog.Error(err, "unable to set up RBAC ready check")
+		os.Exit(1)
+	}
+
 	// Register signal handler for SIGINT and SIGTERM to terminate the manager
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -126,3 +231,13 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// bindPort returns the port of a bind address, e.g. 9001 for ":9001"
+func bindPort(addr string) (int32, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return 0, err
+	}
+	p, err := strconv.ParseInt(port, 10, 32)
+	return int32(p), err
+}
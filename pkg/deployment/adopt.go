@@ -0,0 +1,173 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The well-known labels of the operand workloads deployed from the NFD
+// manifests, "app: nfd-worker", and from the NFD Helm chart,
+// "app.kubernetes.io/name: node-feature-discovery" along with
+// "role: worker"
+const (
+	manifestsAppLabel = "app"
+	helmNameLabel     = "app.kubernetes.io/name"
+	helmNameValue     = "node-feature-discovery"
+	helmRoleLabel     = "role"
+)
+
+// adoptableComponents are the operands whose workloads can be adopted,
+// named after their assets
+var adoptableComponents = map[string]bool{
+	"nfd-master":           true,
+	"nfd-worker":           true,
+	"nfd-topology-updater": true,
+	"nfd-gc":               true,
+}
+
+// foreignComponent returns the operand, named after its asset, run by a
+// workload deployed outside of the operator, i.e. not controlled by any
+// object, according to its well-known labels. It returns "" if the
+// workload isn't such an NFD operand.
+func foreignComponent(obj metav1.Object) string {
+	if metav1.GetControllerOf(obj) != nil {
+		return ""
+	}
+
+	l := obj.GetLabels()
+	component := l[manifestsAppLabel]
+	if l[helmNameLabel] == helmNameValue {
+		component = "nfd-" + l[helmRoleLabel]
+	}
+	if !adoptableComponents[component] {
+		return ""
+	}
+	return component
+}
+
+// adoptWorkload takes over found, the live workload with the name of the
+// desired one, if it was deployed outside of the operator and adoption is
+// requested in the CR. The apply of the desired workload then sets the
+// owner reference and makes the operator the manager of the fields it
+// sets. As the selector of a workload can't be changed, found is deleted
+// first if its selector differs, and adopt returns true so that the
+// desired workload is created once it's gone. Its pods are orphaned,
+// rather than deleted, when the desired selector matches them, so that the
+// new workload takes them over and rolls them. It returns whether found is
+// adopted, and whether it was deleted. The adoption is recorded, to be
+// reported by the reconciler.
+func adoptWorkload(n NFD, kind, component string, found client.Object, foundSelector, selector *metav1.LabelSelector, podLabels map[string]string) (bool, bool, error) {
+	if !n.ins.Spec.AdoptExisting || foreignComponent(found) != component {
+		return false, false, nil
+	}
+	n.recordAdoption(kind, found.GetName(), "adopted")
+
+	if equalSelectors(foundSelector, selector) {
+		log.Info("Adopting the workload deployed outside of the operator", "Kind", kind, "Name", found.GetName())
+		return true, false, nil
+	}
+
+	propagation := metav1.DeletePropagationBackground
+	if sel, err := metav1.LabelSelectorAsSelector(selector); err == nil && sel.Matches(labels.Set(podLabels)) {
+		propagation = metav1.DeletePropagationOrphan
+	}
+	log.Info("Adopting the workload deployed outside of the operator, recreating it with the operator's selector",
+		"Kind", kind, "Name", found.GetName(), "Propagation", propagation)
+	err := n.client.Delete(context.TODO(), found, client.PropagationPolicy(propagation))
+	if err != nil && !errors.IsNotFound(err) {
+		return true, false, err
+	}
+	return true, true, nil
+}
+
+// equalSelectors returns true if both selectors match the same labels
+func equalSelectors(a, b *metav1.LabelSelector) bool {
+	sa, err := metav1.LabelSelectorAsSelector(a)
+	if err != nil {
+		return false
+	}
+	sb, err := metav1.LabelSelectorAsSelector(b)
+	if err != nil {
+		return false
+	}
+	return sa.String() == sb.String()
+}
+
+// deleteDuplicates deletes the workloads deployed outside of the operator
+// that run the same operand as the adopted workload of the given kind and
+// name, once adoption is requested in the CR. It's called once the
+// adopted workload is available, so that the operand keeps running during
+// the handover.
+func deleteDuplicates(n NFD, component, kind, name string) error {
+	if !n.ins.Spec.AdoptExisting {
+		return nil
+	}
+
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := n.client.List(context.TODO(), daemonSets, client.InNamespace(n.ins.GetNamespace())); err != nil {
+		return err
+	}
+	deployments := &appsv1.DeploymentList{}
+	if err := n.client.List(context.TODO(), deployments, client.InNamespace(n.ins.GetNamespace())); err != nil {
+		return err
+	}
+
+	duplicates := []client.Object{}
+	for i := range daemonSets.Items {
+		if ds := &daemonSets.Items[i]; !(kind == "DaemonSet" && ds.Name == name) && foreignComponent(ds) == component {
+			duplicates = append(duplicates, ds)
+		}
+	}
+	for i := range deployments.Items {
+		if d := &deployments.Items[i]; !(kind == "Deployment" && d.Name == name) && foreignComponent(d) == component {
+			duplicates = append(duplicates, d)
+		}
+	}
+
+	for _, obj := range duplicates {
+		log.Info("Deleting the duplicate of an adopted workload", "Kind", kindOf(obj), "Name", obj.GetName(), "Operand", component)
+		err := n.client.Delete(context.TODO(), obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		n.recordAdoption(kindOf(obj), obj.GetName(), "deleted as a duplicate of the adopted "+component)
+	}
+	return nil
+}
+
+// recordAdoption records what was done to the workload of the given kind
+// and name deployed outside of the operator
+func (n *NFD) recordAdoption(kind, name, action string) {
+	if n.adoptions != nil {
+		n.adoptions[fmt.Sprintf("%s/%s", kind, name)] = action
+	}
+}
+
+// Adoptions returns the workloads deployed outside of the operator that
+// were adopted, or deleted as duplicates of adopted ones, since the call
+// to Init, as "<kind>/<name>", along with what was done
+func (n *NFD) Adoptions() map[string]string {
+	return n.adoptions
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// fieldManager is the field manager of the operator, which owns the
+// fields of the operand resources it renders
+const fieldManager = "node-feature-discovery-operator"
+
+// apply creates or updates obj with a server-side apply, so that the
+// operator only owns the fields it renders and leaves alone the ones set
+// by the API server defaults, the webhooks and the other controllers.
+// Conflicts are forced, as the CR is the source of truth of the rendered
+// fields. The status is left out, and obj then reflects the live object.
+func apply(n NFD, obj client.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, n.scheme)
+	if err != nil {
+		return err
+	}
+
+	u := &unstructured.Unstructured{}
+	if o, ok := obj.(*unstructured.Unstructured); ok {
+		u = o.DeepCopy()
+	} else {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return err
+		}
+		u.SetUnstructuredContent(content)
+	}
+	u.SetGroupVersionKind(gvk)
+	u.SetResourceVersion("")
+	u.SetManagedFields(nil)
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(u.Object, "status")
+
+	prev, known := liveObject(n, gvk, obj)
+	if err := n.client.Patch(context.TODO(), u, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership); err != nil {
+		return err
+	}
+	if known {
+		recordChange(n, gvk.Kind, prev, u)
+	}
+
+	if o, ok := obj.(*unstructured.Unstructured); ok {
+		o.SetUnstructuredContent(u.Object)
+		return nil
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj)
+}
+
+// liveObject returns the live version of obj, or nil if it doesn't exist
+// yet, so that recordChange can tell a creation from an update. It returns
+// false if that can't be told: the unstructured objects aren't read, as
+// the cached client would start watching their kind.
+func liveObject(n NFD, gvk schema.GroupVersionKind, obj client.Object) (client.Object, bool) {
+	if _, ok := obj.(*unstructured.Unstructured); ok {
+		return nil, false
+	}
+	o, err := n.scheme.New(gvk)
+	if err != nil {
+		return nil, false
+	}
+	live, ok := o.(client.Object)
+	if !ok {
+		return nil, false
+	}
+
+	err = n.client.Get(context.TODO(), client.ObjectKeyFromObject(obj), live)
+	if errors.IsNotFound(err) {
+		return nil, true
+	} else if err != nil {
+		return nil, false
+	}
+	return live, true
+}
+
+// recordChange records that the apply of an object of the current state
+// created it, or changed it. The generation tells the changes of the
+// objects having a spec, as their status is updated by other controllers,
+// and the resource version the changes of the other ones.
+func recordChange(n NFD, kind string, prev, applied client.Object) {
+	if n.changes == nil {
+		return
+	}
+
+	change := ""
+	switch {
+	case prev == nil:
+		change = "created"
+	case applied.GetGeneration() > 0 && prev.GetGeneration() != applied.GetGeneration():
+		change = "updated"
+	case applied.GetGeneration() == 0 && prev.GetResourceVersion() != applied.GetResourceVersion():
+		change = "updated"
+	default:
+		return
+	}
+	state := n.states[n.idx]
+	n.changes[state] = append(n.changes[state], fmt.Sprintf("%s %s %s", change, kind, applied.GetName()))
+}
+
+// Changes returns the objects created or updated since the last call to
+// Init, by state, e.g. "created DaemonSet nfd-worker"
+func (n *NFD) Changes() map[string][]string {
+	return n.changes
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// applyClient turns the server-side apply patches, which the fake client
+// doesn't support, into creates and updates
+type applyClient struct {
+	client.Client
+}
+
+func (c applyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+	err := c.Get(ctx, client.ObjectKeyFromObject(obj), live)
+	if errors.IsNotFound(err) {
+		return c.Create(ctx, obj)
+	} else if err != nil {
+		return err
+	}
+	obj.SetResourceVersion(live.GetResourceVersion())
+	return c.Update(ctx, obj)
+}
+
+// testScheme returns a scheme with the types the controls handle
+func testScheme(t *testing.T) *runtime.Scheme {
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := nfdv1.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// testInstance returns a NodeFeatureDiscovery object for the tests
+func testInstance() *nfdv1.NodeFeatureDiscovery {
+	return &nfdv1.NodeFeatureDiscovery{
+		ObjectMeta: metav1.ObjectMeta{Name: "nfd-instance", Namespace: "nfd", UID: "uid"},
+	}
+}
+
+// testNFD returns an NFD object using the fake client, whose assets are
+// the given ones
+func testNFD(t *testing.T, assets AssetsProvider, objs ...runtime.Object) (*NFD, client.Client) {
+	s := testScheme(t)
+	c := applyClient{fake.NewFakeClientWithScheme(s, objs...)}
+	n := &NFD{}
+	if err := n.Init(c, s, assets, Platform{}, testInstance()); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	return n, c
+}
+
+func TestApply(t *testing.T) {
+	assets := &MemoryAssets{}
+	assets.Add("worker", []byte(serviceAccountManifest))
+	n, c := testNFD(t, assets)
+
+	obj := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "nfd-worker", Namespace: "nfd"}}
+	if err := apply(*n, obj); err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if obj.GetResourceVersion() == "" {
+		t.Error("applied object doesn't reflect the live one")
+	}
+	if err := c.Get(context.TODO(), client.ObjectKeyFromObject(obj), &corev1.ServiceAccount{}); err != nil {
+		t.Errorf("object not created: %v", err)
+	}
+
+	changes := n.Changes()["worker"]
+	if len(changes) != 1 || changes[0] != "created ServiceAccount nfd-worker" {
+		t.Errorf("unexpected changes %v", changes)
+	}
+}
+
+func TestRecordChange(t *testing.T) {
+	meta := func(generation int64, resourceVersion string) client.Object {
+		return &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+			Name:            "nfd-worker",
+			Generation:      generation,
+			ResourceVersion: resourceVersion,
+		}}
+	}
+
+	tests := []struct {
+		name          string
+		prev, applied client.Object
+		want          []string
+	}{
+		{"created", nil, meta(0, "1"), []string{"created ServiceAccount nfd-worker"}},
+		{"generation changed", meta(1, "1"), meta(2, "2"), []string{"updated ServiceAccount nfd-worker"}},
+		{"only status changed", meta(1, "1"), meta(1, "2"), nil},
+		{"resource version changed", meta(0, "1"), meta(0, "2"), []string{"updated ServiceAccount nfd-worker"}},
+		{"unchanged", meta(0, "1"), meta(0, "1"), nil},
+	}
+	for _, tc := range tests {
+		n := NFD{states: []string{"worker"}, changes: map[string][]string{}}
+		recordChange(n, "ServiceAccount", tc.prev, tc.applied)
+		got := n.Changes()["worker"]
+		if len(got) != len(tc.want) || (len(got) > 0 && got[0] != tc.want[0]) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// Nothing is recorded before Init
+	recordChange(NFD{states: []string{"worker"}}, "ServiceAccount", nil, meta(0, "1"))
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultStates are the asset directories applied, in order, by the
+// filesystem and embedded assets providers
+var DefaultStates = []string{"nodefeatureapi", "certmanager", "master", "worker", "topologyupdater", "gc", "console", "custom"}
+
+// AssetsProvider provides the manifests of the operand resources. The
+// manifests are grouped in states, which are applied in order: the
+// resources of a state are only applied once those of the previous states
+// are ready. Other sources, e.g. OCI images, can be supported by
+// implementing this interface.
+type AssetsProvider interface {
+	// States returns the names of the states, in the order they are
+	// applied
+	States(ctx context.Context) ([]string, error)
+
+	// Assets returns the raw YAML manifests of a state
+	Assets(ctx context.Context, state string) ([][]byte, error)
+}
+
+// FSAssets reads the assets from a filesystem, one directory per state.
+// The manifests of a state are all the files found, recursively, in its
+// directory. States without a directory are skipped.
+type FSAssets struct {
+	// FS holds the state directories
+	FS fs.FS
+
+	// StateDirs are the names of the state directories, in the order
+	// they are applied
+	StateDirs []string
+}
+
+// NewDirAssets returns a provider reading the default states from a
+// directory, e.g. /opt/nfd in the operator image
+func NewDirAssets(dir string) *FSAssets {
+	return &FSAssets{FS: os.DirFS(dir), StateDirs: DefaultStates}
+}
+
+// States implements AssetsProvider
+func (a *FSAssets) States(_ context.Context) ([]string, error) {
+	states := []string{}
+	for _, s := range a.StateDirs {
+		info, err := fs.Stat(a.FS, s)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			states = append(states, s)
+		}
+	}
+	return states, nil
+}
+
+// Assets implements AssetsProvider
+func (a *FSAssets) Assets(_ context.Context, state string) ([][]byte, error) {
+	manifests := [][]byte{}
+	err := fs.WalkDir(a.FS, state, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip the hidden "..data" entries of ConfigMap volumes,
+		// which duplicate the actual files
+		if strings.HasPrefix(d.Name(), "..") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		buffer, err := fs.ReadFile(a.FS, p)
+		if err != nil {
+			return err
+		}
+		manifests = append(manifests, buffer)
+		return nil
+	})
+	return manifests, err
+}
+
+// ConfigMapAssets reads the assets from ConfigMaps, one ConfigMap per
+// state. The manifests of a state are the values of its ConfigMap, in the
+// alphabetical order of their keys.
+type ConfigMapAssets struct {
+	// Reader is used to get the ConfigMaps
+	Reader client.Reader
+
+	// Namespace of the ConfigMaps
+	Namespace string
+
+	// Names of the ConfigMaps, in the order the states are applied
+	Names []string
+}
+
+// States implements AssetsProvider
+func (a *ConfigMapAssets) States(_ context.Context) ([]string, error) {
+	return a.Names, nil
+}
+
+// Assets implements AssetsProvider
+func (a *ConfigMapAssets) Assets(ctx context.Context, state string) ([][]byte, error) {
+	cm := &corev1.ConfigMap{}
+	if err := a.Reader.Get(ctx, types.NamespacedName{Namespace: a.Namespace, Name: state}, cm); err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for k := range cm.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	manifests := [][]byte{}
+	for _, k := range keys {
+		manifests = append(manifests, []byte(cm.Data[k]))
+	}
+	return manifests, nil
+}
+
+// MemoryAssets holds the assets in memory, e.g. for tests or for
+// operators generating the manifests themselves
+type MemoryAssets struct {
+	states    []string
+	manifests map[string][][]byte
+}
+
+// Add appends manifests to a state, adding the state after the existing
+// ones if needed
+func (a *MemoryAssets) Add(state string, manifests ...[]byte) {
+	if a.manifests == nil {
+		a.manifests = map[string][][]byte{}
+	}
+	if _, ok := a.manifests[state]; !ok {
+		a.states = append(a.states, state)
+	}
+	a.manifests[state] = append(a.manifests[state], manifests...)
+}
+
+// States implements AssetsProvider
+func (a *MemoryAssets) States(_ context.Context) ([]string, error) {
+	return a.states, nil
+}
+
+// Assets implements AssetsProvider
+func (a *MemoryAssets) Assets(_ context.Context, state string) ([][]byte, error) {
+	m, ok := a.manifests[state]
+	if !ok {
+		return nil, fmt.Errorf("unknown state %q", state)
+	}
+	return m, nil
+}
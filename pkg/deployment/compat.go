@@ -0,0 +1,191 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/yaml"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// flagVersions are the NFD versions that introduced the command line
+// flags set by the operator, per operand. The flags not listed here are
+// supported by all the NFD versions the operator deploys.
+var flagVersions = map[string]map[string]*version.Version{
+	"nfd-master": {
+		"--resource-labels":        version.MustParseGeneric("v0.7.0"),
+		"--instance":               version.MustParseGeneric("v0.8.0"),
+		"--enable-taints":          version.MustParseGeneric("v0.11.0"),
+		"--options":                version.MustParseGeneric("v0.11.0"),
+		"--deny-label-ns":          version.MustParseGeneric("v0.12.0"),
+		"--enable-leader-election": version.MustParseGeneric("v0.12.0"),
+		"--feature-gates":          version.MustParseGeneric("v0.14.0"),
+		"--enable-nodefeature-api": version.MustParseGeneric("v0.12.0"),
+	},
+	"nfd-worker": {
+		"--feature-gates":          version.MustParseGeneric("v0.14.0"),
+		"--enable-nodefeature-api": version.MustParseGeneric("v0.12.0"),
+	},
+}
+
+// flagRemovals are the NFD versions that removed the command line flags
+// set by the operator, per operand
+var flagRemovals = map[string]map[string]*version.Version{
+	"nfd-master": {
+		"--enable-nodefeature-api": version.MustParseGeneric("v0.14.0"),
+	},
+	"nfd-worker": {
+		"--enable-nodefeature-api": version.MustParseGeneric("v0.14.0"),
+	},
+}
+
+// nodeFeatureAPIGate is the feature gate replacing the
+// --enable-nodefeature-api flag from NFD v0.14 on, where it's enabled by
+// default
+const nodeFeatureAPIGate = "NodeFeatureAPI"
+
+// labelSourcesVersion is the NFD version that renamed the core.sources
+// setting of the nfd-worker configuration to core.labelSources
+var labelSourcesVersion = version.MustParseGeneric("v0.10.0")
+
+// ImageTag returns the tag (or digest) of the given image reference, or
+// "unknown" if there's none
+func ImageTag(image string) string {
+	if i := strings.LastIndex(image, "@"); i >= 0 {
+		return image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return "unknown"
+}
+
+// OperandVersion returns the version of the operand, as given in the CR
+// or by the tag of the operand image, without requiring it to be a valid
+// version
+func OperandVersion(ins *nfdv1.NodeFeatureDiscovery) string {
+	if v := ins.Spec.Operand.Version; v != "" {
+		return v
+	}
+	return ImageTag(ins.Spec.Operand.ImagePath())
+}
+
+// operandVersion returns the version of the operand, as given in the CR
+// or by the tag of the operand image. It returns nil if the version is
+// unknown, e.g. for a "latest" or "master" tag, in which case the operand
+// is assumed to be recent.
+func operandVersion(ins *nfdv1.NodeFeatureDiscovery) *version.Version {
+	parsed, err := version.ParseGeneric(OperandVersion(ins))
+	if err != nil {
+		return nil
+	}
+	return parsed
+}
+
+// FlagSupported returns true if the given flag of the given operand, e.g.
+// "--deny-label-ns" of "nfd-master", is supported by the operand version.
+// An unknown version is assumed to be recent.
+func FlagSupported(ins *nfdv1.NodeFeatureDiscovery, name, flag string) bool {
+	v := operandVersion(ins)
+	if min, ok := flagVersions[name][flag]; ok && v != nil && v.LessThan(min) {
+		return false
+	}
+	if max, ok := flagRemovals[name][flag]; ok && (v == nil || !v.LessThan(max)) {
+		return false
+	}
+	return true
+}
+
+// compatibleArgs drops the flags of the given operand that its version
+// doesn't support, as the operand would fail to start with them. The
+// removed flags are dropped for an unknown version as well, since it's
+// assumed to be recent.
+func compatibleArgs(ins *nfdv1.NodeFeatureDiscovery, name string, args []string) []string {
+	v := operandVersion(ins)
+
+	compatible := []string{}
+	for _, arg := range args {
+		flag := strings.SplitN(arg, "=", 2)[0]
+		if min, ok := flagVersions[name][flag]; ok && v != nil && v.LessThan(min) {
+			log.Info("Flag not supported by the operand version, skipping", "operand", name, "flag", flag, "version", v.String())
+			continue
+		}
+		if max, ok := flagRemovals[name][flag]; ok && (v == nil || !v.LessThan(max)) {
+			log.Info("Flag removed from the operand version, skipping", "operand", name, "flag", flag)
+			continue
+		}
+		compatible = append(compatible, arg)
+	}
+	return compatible
+}
+
+// featureGates returns the feature gates of the operands. Unless set by
+// the user, the NodeFeatureAPI gate is enabled along with
+// spec.enableNodeFeatureApi, as the operator only installs the NodeFeature
+// CRDs according to the latter. It's left out otherwise, so that the
+// operands keep the default of their version, which may not allow to
+// change it.
+func featureGates(ins *nfdv1.NodeFeatureDiscovery) map[string]bool {
+	gates := map[string]bool{}
+	for k, v := range ins.Spec.FeatureGates {
+		gates[k] = v
+	}
+	if _, ok := gates[nodeFeatureAPIGate]; !ok && ins.Spec.EnableNodeFeatureAPI {
+		gates[nodeFeatureAPIGate] = true
+	}
+	return gates
+}
+
+// compatibleWorkerConfig renames the core.sources setting of an
+// nfd-worker configuration to core.labelSources, or the other way around,
+// to match the version of the operand. The configuration is returned as
+// is when there's nothing to rename, or when it can't be parsed, in which
+// case nfd-worker reports the error.
+func compatibleWorkerConfig(ins *nfdv1.NodeFeatureDiscovery, data string) string {
+	from, to := "sources", "labelSources"
+	if v := operandVersion(ins); v != nil && v.LessThan(labelSourcesVersion) {
+		from, to = to, from
+	}
+
+	config := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		return data
+	}
+	core, ok := config["core"].(map[string]interface{})
+	if !ok {
+		return data
+	}
+	value, ok := core[from]
+	if !ok {
+		return data
+	}
+	if _, ok := core[to]; ok {
+		return data
+	}
+
+	core[to] = value
+	delete(core, from)
+	renamed, err := yaml.Marshal(config)
+	if err != nil {
+		return data
+	}
+	log.Info("Renamed the worker configuration setting for the operand version", "from", "core."+from, "to", "core."+to)
+	return string(renamed)
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"reflect"
+	"testing"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// versionedInstance returns a NodeFeatureDiscovery object deploying the
+// given NFD version
+func versionedInstance(version string) *nfdv1.NodeFeatureDiscovery {
+	ins := testInstance()
+	ins.Spec.Operand.Version = version
+	return ins
+}
+
+func TestCompatibleArgs(t *testing.T) {
+	args := []string{"--port=12000", "--instance=x", "--enable-nodefeature-api", "--feature-gates=NodeFeatureAPI=true"}
+
+	tests := []struct {
+		version string
+		want    []string
+	}{
+		{"v0.7.0", []string{"--port=12000"}},
+		{"v0.12.1", []string{"--port=12000", "--instance=x", "--enable-nodefeature-api"}},
+		{"v0.14.0", []string{"--port=12000", "--instance=x", "--feature-gates=NodeFeatureAPI=true"}},
+		{"latest", []string{"--port=12000", "--instance=x", "--feature-gates=NodeFeatureAPI=true"}},
+	}
+	for _, tc := range tests {
+		got := compatibleArgs(versionedInstance(tc.version), "nfd-master", args)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.version, got, tc.want)
+		}
+	}
+}
+
+func TestFlagSupported(t *testing.T) {
+	if FlagSupported(versionedInstance("v0.11.0"), "nfd-master", "--deny-label-ns") {
+		t.Error("--deny-label-ns supported by v0.11.0")
+	}
+	if !FlagSupported(versionedInstance("v0.12.0"), "nfd-master", "--deny-label-ns") {
+		t.Error("--deny-label-ns not supported by v0.12.0")
+	}
+	if FlagSupported(versionedInstance("master"), "nfd-worker", "--enable-nodefeature-api") {
+		t.Error("removed flag supported by an unknown version")
+	}
+}
+
+func TestFeatureGates(t *testing.T) {
+	tests := []struct {
+		name   string
+		enable bool
+		gates  map[string]bool
+		want   map[string]bool
+	}{
+		{"disabled", false, nil, map[string]bool{}},
+		{"enabled", true, nil, map[string]bool{nodeFeatureAPIGate: true}},
+		{"set by the user", true, map[string]bool{nodeFeatureAPIGate: false}, map[string]bool{nodeFeatureAPIGate: false}},
+		{"other gates", false, map[string]bool{"Foo": true}, map[string]bool{"Foo": true}},
+	}
+	for _, tc := range tests {
+		ins := testInstance()
+		ins.Spec.EnableNodeFeatureAPI = tc.enable
+		ins.Spec.FeatureGates = tc.gates
+		if got := featureGates(ins); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCompatibleWorkerConfig(t *testing.T) {
+	sources := "core:\n  sources:\n  - cpu\n"
+	labelSources := "core:\n  labelSources:\n  - cpu\n"
+	both := "core:\n  labelSources:\n  - cpu\n  sources:\n  - pci\n"
+
+	tests := []struct {
+		name    string
+		version string
+		data    string
+		want    string
+	}{
+		{"renamed for a recent version", "v0.10.0", sources, labelSources},
+		{"renamed for an old version", "v0.9.0", labelSources, sources},
+		{"renamed for an unknown version", "latest", sources, labelSources},
+		{"already named for the version", "v0.10.0", labelSources, labelSources},
+		{"both set", "v0.10.0", both, both},
+		{"invalid", "v0.10.0", "core: [", "core: ["},
+	}
+	for _, tc := range tests {
+		if got := compatibleWorkerConfig(versionedInstance(tc.version), tc.data); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
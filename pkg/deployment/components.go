@@ -0,0 +1,77 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// componentStates maps the states deploying an operand to the name of
+// the operand in status.components
+var componentStates = map[string]string{
+	"master":          "master",
+	"worker":          "worker",
+	"topologyupdater": "topologyUpdater",
+	"gc":              "gc",
+}
+
+// ComponentResult is the outcome of the control functions of the state of
+// an operand
+type ComponentResult struct {
+	// Ready is true if all the resources of the state are ready
+	Ready bool
+
+	// Message tells why the resources aren't ready
+	Message string
+}
+
+// setComponentResult records the outcome of the current state, if it
+// deploys an operand. A nil result records that the operand is disabled.
+func (n *NFD) setComponentResult(res *ComponentResult) {
+	name, ok := componentStates[n.states[n.idx]]
+	if !ok {
+		return
+	}
+	if n.components == nil {
+		n.components = map[string]*ComponentResult{}
+	}
+	n.components[name] = res
+}
+
+// Components returns the outcome of the states applied since the last
+// call to Init, by operand. The operands whose state wasn't reached are
+// missing, and the disabled ones are mapped to nil.
+func (n *NFD) Components() map[string]*ComponentResult {
+	return n.components
+}
+
+// Rollouts is the status of the nfd-master and nfd-worker workloads, as
+// read while applying their states. The status of the workloads whose
+// state wasn't reached is nil.
+type Rollouts struct {
+	Master *nfdv1.MasterStatus
+	Worker *nfdv1.WorkerStatus
+}
+
+// Rollouts returns the status of the operand workloads read since the last
+// call to Init, for the caller to report in the status of the CR
+func (n *NFD) Rollouts() Rollouts {
+	if n.rollouts == nil {
+		return Rollouts{}
+	}
+	return *n.rollouts
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+func TestMasterStatus(t *testing.T) {
+	assets := &MemoryAssets{}
+	assets.Add("master", []byte(serviceAccountManifest))
+	holder := "nfd-master-0"
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: MasterLeaseName, Namespace: "nfd"},
+		Spec:       coordinationv1.LeaseSpec{HolderIdentity: &holder},
+	}
+
+	// The NodeFeatureDiscovery object isn't known to the client: the
+	// status is only returned, for the controller to report it
+	n, _ := testNFD(t, assets, lease)
+	if got := n.Rollouts(); got.Master != nil || got.Worker != nil {
+		t.Fatalf("got rollouts %+v before applying the states", got)
+	}
+
+	d := &appsv1.Deployment{Status: appsv1.DeploymentStatus{Replicas: 2, ReadyReplicas: 1, AvailableReplicas: 1}}
+	got, err := masterStatus(*n, d)
+	if err != nil {
+		t.Fatalf("masterStatus failed: %v", err)
+	}
+	want := nfdv1.MasterStatus{Replicas: 2, ReadyReplicas: 1, AvailableReplicas: 1}
+	if *got != want {
+		t.Errorf("got %+v, want %+v", *got, want)
+	}
+
+	// The leader is only reported with leader election
+	n.ins.Spec.Master.EnableLeaderElection = true
+	got, err = masterStatus(*n, d)
+	if err != nil {
+		t.Fatalf("masterStatus failed: %v", err)
+	}
+	want.Leader = holder
+	if *got != want {
+		t.Errorf("got %+v, want %+v", *got, want)
+	}
+}
+
+func TestWorkerStatus(t *testing.T) {
+	ds := &appsv1.DaemonSet{Status: appsv1.DaemonSetStatus{
+		DesiredNumberScheduled: 3,
+		NumberReady:            2,
+		UpdatedNumberScheduled: 1,
+		NumberUnavailable:      1,
+	}}
+	want := nfdv1.WorkerStatus{DesiredNumberScheduled: 3, NumberReady: 2, UpdatedNumberScheduled: 1, NumberUnavailable: 1}
+	if got := workerStatus(ds); *got != want {
+		t.Errorf("got %+v, want %+v", *got, want)
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/version"
+)
+
+// ConsolePluginGVK is the OpenShift console dynamic plugin declaration.
+// As the console API only exists on OpenShift, the ConsolePlugin is
+// handled as an unstructured object.
+var ConsolePluginGVK = schema.GroupVersionKind{Group: "console.openshift.io", Version: "v1", Kind: "ConsolePlugin"}
+
+// ConsolePluginName is the name of the ConsolePlugin, which is to be
+// listed in the plugins of the console operator config to enable it
+const ConsolePluginName = "nfd-console-plugin"
+
+// ApplyConsolePlugin creates or updates the ConsolePlugin pointing the
+// console to the plugin files served behind the given Service port. It
+// isn't owned by any NodeFeatureDiscovery, being shared by all of them.
+func ApplyConsolePlugin(ctx context.Context, c client.Client, service types.NamespacedName, port int32) error {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(ConsolePluginGVK)
+	obj.SetName(ConsolePluginName)
+	obj.SetLabels(map[string]string{
+		managedByLabel: managedByValue,
+		partOfLabel:    partOfValue,
+		versionLabel:   version.Version,
+	})
+	obj.Object["spec"] = map[string]interface{}{
+		"displayName": "Node Feature Discovery",
+		"backend": map[string]interface{}{
+			"type": "Service",
+			"service": map[string]interface{}{
+				"name":      service.Name,
+				"namespace": service.Namespace,
+				"port":      int64(port),
+				"basePath":  "/",
+			},
+		},
+	}
+
+	return c.Patch(ctx, obj, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership)
+}
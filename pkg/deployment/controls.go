@@ -0,0 +1,1857 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	secv1 "github.com/openshift/api/security/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+	"github.com/kubernetes-sigs/node-feature-discovery-operator/version"
+)
+
+type controlFunc []func(n NFD) (ResourceStatus, error)
+
+const (
+	// workerConfigFile is the name of the nfd-worker configuration file
+	workerConfigFile = "nfd-worker.conf"
+
+	// workerConfigHashAnnotation holds the hash of the nfd-worker
+	// configuration on the nfd-worker pod template
+	workerConfigHashAnnotation = "nfd.kubernetes.io/worker-config-hash"
+
+	// masterConfigFile is the name of the nfd-master configuration file
+	masterConfigFile = "nfd-master.conf"
+
+	// instanceLabel identifies the operand pods of an NFD instance
+	instanceLabel = "nfd.kubernetes.io/instance"
+
+	// masterConfigHashAnnotation holds the hash of the nfd-master
+	// configuration on the nfd-master pod template
+	masterConfigHashAnnotation = "nfd.kubernetes.io/master-config-hash"
+
+	// MasterLeaseName is the name of the Lease used for the leader
+	// election between the nfd-master replicas
+	MasterLeaseName = "nfd-master.nfd.kubernetes.io"
+
+	// The recommended labels of the managed objects, used by inventory
+	// and policy tools to group the NFD footprint. See
+	// https://kubernetes.io/docs/concepts/overview/working-with-objects/common-labels/
+	managedByLabel = "app.kubernetes.io/managed-by"
+	partOfLabel    = "app.kubernetes.io/part-of"
+	appInstLabel   = "app.kubernetes.io/instance"
+	versionLabel   = "app.kubernetes.io/version"
+
+	managedByValue = "node-feature-discovery-operator"
+	partOfValue    = "node-feature-discovery"
+)
+
+// ResourceStatus defines the status of the resource as being
+// Ready or NotReady
+type ResourceStatus int
+
+const (
+	Ready ResourceStatus = iota
+	NotReady
+
+	defaultServicePort int = nfdv1.DefaultServicePort
+)
+
+// String implements the fmt.Stringer interface and returns describes
+// ResourceStatus as a string.
+func (s ResourceStatus) String() string {
+	names := [...]string{
+		"Ready",
+		"NotReady"}
+
+	if s < Ready || s > NotReady {
+		return "Unkown Resources Status"
+	}
+	return names[s]
+}
+
+// Namespace checks if the Namespace for NFD exists and creates it
+// if it doesn't exist
+func Namespace(n NFD) (ResourceStatus, error) {
+
+	// state represents the resource's 'control' function index
+	state := n.idx
+
+	// It is assumed that the index has already been verified to be a
+	// Namespace object, so let's get the resource's Namespace object
+	obj := n.resources[state].Namespace
+	setCommonLabels(n.ins, &obj)
+
+	// found states if the Namespace was found
+	found := &corev1.Namespace{}
+	logger := log.WithValues("Namespace", obj.Name, "Namespace", "Cluster")
+
+	if err := recordManifest(n, "Namespace", &obj); err != nil {
+		return NotReady, err
+	}
+
+	// Look for the Namespace to see if it exists, and if so, check if
+	// it's Ready/NotReady. If the Namespace does not exist, then
+	// attempt to create it
+	logger.Info("Looking for")
+	err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}, found)
+	if err != nil && errors.IsNotFound(err) && !n.ins.Spec.NamespaceManaged() {
+		return NotReady, fmt.Errorf("namespace %q does not exist and spec.manageNamespace is false", obj.Name)
+	} else if err != nil && errors.IsNotFound(err) {
+		logger.Info("Not found, creating ")
+		err = apply(n, &obj)
+		if err != nil {
+			logger.Info("Couldn't create")
+			return NotReady, err
+		}
+		return Ready, nil
+	} else if err != nil {
+		return NotReady, err
+	}
+
+	logger.Info("Found, skipping update")
+
+	return Ready, nil
+}
+
+// ServiceAccount checks the readiness of the NFD ServiceAccount and creates it if it doesn't exist
+func ServiceAccount(n NFD) (ResourceStatus, error) {
+
+	// state represents the resource's 'control' function index
+	state := n.idx
+
+	// It is assumed that the index has already been verified to be a
+	// ServiceAccount object, so let's get the resource's ServiceAccount
+	// object
+	obj := n.resources[state].ServiceAccount
+
+	// It is also assumed that our service account has a defined Namespace
+	obj.SetNamespace(n.ins.GetNamespace())
+	obj.SetName(InstanceName(n.ins, obj.GetName()))
+	setCommonLabels(n.ins, &obj)
+
+	// found states if the ServiceAccount was found
+	found := &corev1.ServiceAccount{}
+	logger := log.WithValues("ServiceAccount", obj.Name, "Namespace", obj.Namespace)
+
+	// The ServiceAccount is provided by the user, so don't create one,
+	// and remove the one created previously, if any. ServiceAccounts not
+	// owned by the NFD instance, e.g. the user's one, are left alone.
+	if n.ins.Spec.Operand.ServiceAccountName != "" {
+		err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}, found)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return Ready, nil
+			}
+			return NotReady, err
+		}
+		if !metav1.IsControlledBy(found, n.ins) {
+			return Ready, nil
+		}
+		logger.Info("ServiceAccount provided by the user, deleting")
+		err = n.client.Delete(context.TODO(), found)
+		if err != nil && !errors.IsNotFound(err) {
+			return NotReady, err
+		}
+		return Ready, nil
+	}
+
+	if err := recordManifest(n, "ServiceAccount", &obj); err != nil {
+		return NotReady, err
+	}
+
+	logger.Info("Looking for")
+
+	// SetControllerReference sets the owner as a Controller OwnerReference
+	// and is used for garbage collection of the controlled object. It is
+	// also used to reconcile the owner object on changes to the controlled
+	// object. If we cannot set the owner, then return NotReady
+	if err := controllerutil.SetControllerReference(n.ins, &obj, n.scheme); err != nil {
+		return NotReady, err
+	}
+
+	// Look for the ServiceAccount to see if it exists, and if so, check if
+	// it's Ready/NotReady. If the ServiceAccount does not exist, then
+	// attempt to create it
+	err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		logger.Info("Not found, creating ")
+		err = apply(n, &obj)
+		if err != nil {
+			logger.Info("Couldn't create")
+			return NotReady, err
+		}
+		return Ready, nil
+	} else if err != nil {
+		return NotReady, err
+	}
+
+	logger.Info("Found, skipping update")
+
+	return Ready, nil
+}
+
+// ClusterRole checks if the ClusterRole exists, and creates it if it doesn't
+func ClusterRole(n NFD) (ResourceStatus, error) {
+
+	// state represents the resource's 'control' function index
+	state := n.idx
+
+	// It is assumed that the index has already been verified to be a
+	// ClusterRole object, so let's get a copy of the resource's
+	// ClusterRole object (its rules get modified below)
+	obj := *n.resources[state].ClusterRole.DeepCopy()
+
+	// Grant the permissions needed by the enabled features only
+	obj.Rules = append(obj.Rules, featureClusterRules(n.ins, obj.GetName())...)
+	obj.SetName(InstanceName(n.ins, obj.GetName()))
+	setCommonLabels(n.ins, &obj)
+
+	// found states if the ClusterRole was found
+	found := &rbacv1.ClusterRole{}
+	logger := log.WithValues("ClusterRole", obj.Name, "Namespace", obj.Namespace)
+
+	if err := recordManifest(n, "ClusterRole", &obj); err != nil {
+		return NotReady, err
+	}
+
+	logger.Info("Looking for")
+
+	// Look for the ClusterRole to see if it exists, and if so, check
+	// if it's Ready/NotReady. If the ClusterRole does not exist, then
+	// attempt to create it
+	err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: "", Name: obj.Name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		logger.Info("Not found, creating")
+		err = apply(n, &obj)
+		if err != nil {
+			logger.Info("Couldn't create")
+			return NotReady, err
+		}
+		return Ready, nil
+	} else if err != nil {
+		return NotReady, err
+	}
+
+	// If we found the ClusterRole, let's attempt to update it
+	logger.Info("Found, updating")
+	err = apply(n, &obj)
+	if err != nil {
+		return NotReady, err
+	}
+
+	return Ready, nil
+}
+
+// ClusterRoleBinding checks if a ClusterRoleBinding exists and creates one if it doesn't
+func ClusterRoleBinding(n NFD) (ResourceStatus, error) {
+
+	// state represents the resource's 'control' function index
+	state := n.idx
+
+	// It is assumed that the index has already been verified to be a
+	// ClusterRoleBinding object, so let's get the resource's
+	// ClusterRoleBinding object
+	obj := *n.resources[state].ClusterRoleBinding.DeepCopy()
+
+	// Bind the ClusterRole and ServiceAccounts of this instance
+	obj.SetName(InstanceName(n.ins, obj.GetName()))
+	obj.RoleRef.Name = InstanceName(n.ins, obj.RoleRef.Name)
+	setSubjects(n.ins, obj.Subjects)
+	setCommonLabels(n.ins, &obj)
+
+	// found states if the ClusterRoleBinding was found
+	found := &rbacv1.ClusterRoleBinding{}
+	logger := log.WithValues("ClusterRoleBinding", obj.Name, "Namespace", obj.Namespace)
+
+	if err := recordManifest(n, "ClusterRoleBinding", &obj); err != nil {
+		return NotReady, err
+	}
+
+	logger.Info("Looking for")
+
+	// Look for the ClusterRoleBinding to see if it exists, and if so,
+	// check if it's Ready/NotReady. If the ClusterRoleBinding does not
+	// exist, then attempt to create it
+	err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: "", Name: obj.Name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		logger.Info("Not found, creating")
+		err = apply(n, &obj)
+		if err != nil {
+			logger.Info("Couldn't create")
+			return NotReady, err
+		}
+		return Ready, nil
+	} else if err != nil {
+		return NotReady, err
+	}
+
+	// If we found the ClusterRoleBinding, let's attempt to update it
+	logger.Info("Found, updating")
+	err = apply(n, &obj)
+	if err != nil {
+		return NotReady, err
+	}
+
+	return Ready, nil
+}
+
+// Role checks if a Role exists and creates a Role if it doesn't
+func Role(n NFD) (ResourceStatus, error) {
+
+	// state represents the resource's 'control' function index
+	state := n.idx
+
+	// It is assumed that the index has already been verified to be a
+	// Role object, so let's get a copy of the resource's Role object (its
+	// rules get modified below)
+	obj := *n.resources[state].Role.DeepCopy()
+
+	// The Namespace should already be defined, so let's set the
+	// namespace to the namespace defined in the Role object
+	obj.SetNamespace(n.ins.GetNamespace())
+
+	// Grant the permissions needed by the enabled features only
+	obj.Rules = append(obj.Rules, featureRules(n.ins, obj.GetName())...)
+	obj.SetName(InstanceName(n.ins, obj.GetName()))
+	setCommonLabels(n.ins, &obj)
+
+	// found states if the Role was found
+	found := &rbacv1.Role{}
+	logger := log.WithValues("Role", obj.Name, "Namespace", obj.Namespace)
+
+	if err := recordManifest(n, "Role", &obj); err != nil {
+		return NotReady, err
+	}
+
+	logger.Info("Looking for")
+
+	// SetControllerReference sets the owner as a Controller OwnerReference
+	// and is used for garbage collection of the controlled object. It is
+	// also used to reconcile the owner object on changes to the controlled
+	// object. If we cannot set the owner, then return NotReady
+	if err := controllerutil.SetControllerReference(n.ins, &obj, n.scheme); err != nil {
+		return NotReady, err
+	}
+
+	// Look for the Role to see if it exists, and if so, check if it's
+	// Ready/NotReady. If the Role does not exist, then attempt to create it
+	err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		logger.Info("Not found, creating")
+		err = apply(n, &obj)
+		if err != nil {
+			logger.Info("Couldn't create")
+			return NotReady, err
+		}
+		return Ready, nil
+	} else if err != nil {
+		return NotReady, err
+	}
+
+	// If we found the Role, let's attempt to update it
+	logger.Info("Found, updating")
+	err = apply(n, &obj)
+	if err != nil {
+		return NotReady, err
+	}
+
+	return Ready, nil
+}
+
+// RoleBinding checks if a RoleBinding exists and creates a RoleBinding if it doesn't
+func RoleBinding(n NFD) (ResourceStatus, error) {
+
+	// state represents the resource's 'control' function index
+	state := n.idx
+
+	// It is assumed that the index has already been verified to be a
+	// RoleBinding object, so let's get the resource's RoleBinding
+	// object
+	obj := *n.resources[state].RoleBinding.DeepCopy()
+
+	// The Namespace should already be defined, so let's set the
+	// namespace to the namespace defined in the
+	obj.SetNamespace(n.ins.GetNamespace())
+
+	// Bind the Role and ServiceAccounts of this instance
+	obj.SetName(InstanceName(n.ins, obj.GetName()))
+	obj.RoleRef.Name = InstanceName(n.ins, obj.RoleRef.Name)
+	setSubjects(n.ins, obj.Subjects)
+	setCommonLabels(n.ins, &obj)
+
+	// found states if the RoleBinding was found
+	found := &rbacv1.RoleBinding{}
+	logger := log.WithValues("RoleBinding", obj.Name, "Namespace", obj.Namespace)
+
+	if err := recordManifest(n, "RoleBinding", &obj); err != nil {
+		return NotReady, err
+	}
+
+	logger.Info("Looking for")
+
+	// SetControllerReference sets the owner as a Controller OwnerReference
+	// and is used for garbage collection of the controlled object. It is
+	// also used to reconcile the owner object on changes to the controlled
+	if err := controllerutil.SetControllerReference(n.ins, &obj, n.scheme); err != nil {
+		return NotReady, err
+	}
+
+	// Look for the RoleBinding to see if it exists, and if so, check if
+	// it's Ready/NotReady. If the RoleBinding does not exist, then attempt
+	// to create it
+	err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		logger.Info("Not found, creating")
+		err = apply(n, &obj)
+		if err != nil {
+			logger.Info("Couldn't create")
+			return NotReady, err
+		}
+		return Ready, nil
+	} else if err != nil {
+		return NotReady, err
+	}
+
+	// If we found the RoleBinding, let's attempt to update it
+	logger.Info("Found, updating")
+	err = apply(n, &obj)
+	if err != nil {
+		return NotReady, err
+	}
+
+	return Ready, nil
+}
+
+// ConfigMap checks if a ConfigMap exists and creates one if it doesn't
+func ConfigMap(n NFD) (ResourceStatus, error) {
+
+	// state represents the resource's 'control' function index
+	state := n.idx
+
+	// It is assumed that the index has already been verified to be a
+	// ConfigMap object, so let's get a copy of the resource's ConfigMap
+	// object (its data gets modified below)
+	obj := *n.resources[state].ConfigMap.DeepCopy()
+
+	// The Namespace should already be defined, so let's set the
+	// namespace to the namespace defined in the ConfigMap object
+	obj.SetNamespace(n.ins.GetNamespace())
+
+	// Update ConfigMap with the operand configuration from the CR, which
+	// is the source of truth. Keep the default configuration from the
+	// asset if none was given.
+	configData, ref, key := operandConfig(n.ins, obj.Name)
+	if configData != "" && obj.Name == "nfd-worker" {
+		configData = compatibleWorkerConfig(n.ins, configData)
+	}
+	if configData != "" {
+		obj.Data[key] = configData
+	}
+	obj.SetName(InstanceName(n.ins, obj.GetName()))
+	setCommonLabels(n.ins, &obj)
+
+	// found states if the ConfigMap was found
+	found := &corev1.ConfigMap{}
+	logger := log.WithValues("ConfigMap", obj.Name, "Namespace", obj.Namespace)
+
+	// The worker configuration is provided by the user in their own
+	// ConfigMap, so don't generate one, and remove the one generated
+	// previously, if any (unless the user's ConfigMap has the same name)
+	if ref != nil {
+		if ref.Name == obj.Name {
+			return Ready, nil
+		}
+		logger.Info("Configuration provided by the user, deleting")
+		err := n.client.Delete(context.TODO(), &obj)
+		if err != nil && !errors.IsNotFound(err) {
+			return NotReady, err
+		}
+		return Ready, nil
+	}
+
+	if err := recordManifest(n, "ConfigMap", &obj); err != nil {
+		return NotReady, err
+	}
+
+	logger.Info("Looking for")
+
+	// SetControllerReference sets the owner as a Controller OwnerReference
+	// and is used for garbage collection of the controlled object. It is
+	// also used to reconcile the owner object on changes to the controlled
+	// object. If we cannot set the owner, then return NotReady
+	if err := controllerutil.SetControllerReference(n.ins, &obj, n.scheme); err != nil {
+		return NotReady, err
+	}
+
+	// Look for the ConfigMap to see if it exists, and if so, check if it's
+	// Ready/NotReady. If the ConfigMap does not exist, then attempt to create
+	// it
+	err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		logger.Info("Not found, creating")
+		err = apply(n, &obj)
+		if err != nil {
+			logger.Info("Couldn't create")
+			return NotReady, err
+		}
+		return Ready, nil
+	} else if err != nil {
+		return NotReady, err
+	}
+
+	// If we found the ConfigMap, let's attempt to update it
+	logger.Info("Found, updating")
+	err = apply(n, &obj)
+	if err != nil {
+		return NotReady, err
+	}
+
+	return Ready, nil
+}
+
+// DaemonSet checks the readiness of a DaemonSet and creates one if it doesn't exist
+func DaemonSet(n NFD) (ResourceStatus, error) {
+
+	// state represents the resource's 'control' function index
+	state := n.idx
+
+	// It is assumed that the index has already been verified to be a
+	// DaemonSet object, so let's get a copy of the resource's DaemonSet
+	// object (the pod template gets modified below)
+	obj := *n.resources[state].DaemonSet.DeepCopy()
+
+	// The options below depend on the operand, identified by the name
+	// of the asset. The resources of the DaemonSet are then renamed
+	// after the instance, and the instance label is added to its
+	// selector, so that parallel deployments don't collide.
+	name := obj.GetName()
+	setInstance(n.ins, &obj, obj.Spec.Selector, &obj.Spec.Template)
+	setCommonLabels(n.ins, &obj)
+	if err := setPodTemplate(n, name, &obj.Spec.Template); err != nil {
+		return NotReady, err
+	}
+
+	// Roll the workers with the requested strategy, or in batches of
+	// the requested size
+	if name == "nfd-worker" {
+		if strategy := n.ins.Spec.Worker.UpdateStrategy; strategy != nil {
+			obj.Spec.UpdateStrategy = *strategy.DeepCopy()
+		} else if size := n.ins.Spec.Upgrade.WorkerBatchSize; size != nil {
+			obj.Spec.UpdateStrategy = appsv1.DaemonSetUpdateStrategy{
+				Type:          appsv1.RollingUpdateDaemonSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDaemonSet{MaxUnavailable: size},
+			}
+		}
+	}
+
+	// Set namespace based on the NFD namespace. (And again,
+	// it is assumed that the Namespace has already been
+	// determined before this function was called.)
+	obj.SetNamespace(n.ins.GetNamespace())
+	if err := setTemplateHash(&obj, &obj.Spec.Template); err != nil {
+		return NotReady, err
+	}
+
+	// found states if the DaemonSet was found
+	found := &appsv1.DaemonSet{}
+	logger := log.WithValues("DaemonSet", obj.Name, "Namespace", obj.Namespace)
+
+	if err := recordManifest(n, "DaemonSet", &obj); err != nil {
+		return NotReady, err
+	}
+
+	logger.Info("Looking for")
+
+	// SetControllerReference sets the owner as a Controller OwnerReference
+	// and is used for garbage collection of the controlled object. It is
+	// also used to reconcile the owner object on changes to the controlled
+	// object. If we cannot set the owner, then return NotReady
+	if err := controllerutil.SetControllerReference(n.ins, &obj, n.scheme); err != nil {
+		return NotReady, err
+	}
+
+	// Look for the DaemonSet to see if it exists, and if so, check if it's
+	// Ready/NotReady. If the DaemonSet does not exist, then attempt to
+	// create it
+	err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		logger.Info("Not found, creating")
+		err = apply(n, &obj)
+		if err != nil {
+			logger.Info("Couldn't create")
+			return NotReady, err
+		}
+		return Ready, nil
+	} else if err != nil {
+		return NotReady, err
+	}
+
+	// A DaemonSet deployed outside of the operator is taken over, if
+	// requested, rather than reported as drifted
+	adopted, deleted, err := adoptWorkload(n, "DaemonSet", name, found, found.Spec.Selector, obj.Spec.Selector, found.Spec.Template.Labels)
+	if err != nil {
+		return NotReady, err
+	}
+	if deleted {
+		return NotReady, notReady("DaemonSet", obj.Name, "deleted to be adopted, waiting for it to be created again")
+	}
+
+	// The status of the DaemonSet isn't changed by the update below, so
+	// the rollout progress of the workers can be reported from there
+	if name == "nfd-worker" {
+		n.rollouts.Worker = workerStatus(found)
+	}
+
+	// Operand upgrades are orchestrated: the workers are only updated
+	// once the upgraded nfd-master is available and the existing
+	// workers keep working with it
+	if stat, err := workerUpgradeGate(n, name, found, &obj); stat != Ready || err != nil {
+		return stat, err
+	}
+
+	// Keep the DaemonSet as is while its pod template changes too often
+	proceed, err := guardTemplateChange(n, "DaemonSet", found, &obj)
+	if err != nil {
+		return NotReady, err
+	}
+	if !proceed {
+		logger.Info("Pod template changing too often, not updating")
+		return upgradeProgress(n, name, found)
+	}
+
+	// If we found the DaemonSet, let's attempt to update it, which
+	// reverts any manual change
+	if !adopted {
+		if err := recordDrift(n, "DaemonSet", obj.Name, obj.Spec, found.Spec); err != nil {
+			return NotReady, err
+		}
+	}
+	logger.Info("Found, updating")
+	err = apply(n, &obj)
+	if err != nil {
+		return NotReady, err
+	}
+
+	// The duplicates of an adopted operand go away once it's rolled out
+	if rolloutComplete(&obj) {
+		if err := deleteDuplicates(n, name, "DaemonSet", obj.Name); err != nil {
+			return NotReady, err
+		}
+	}
+
+	return upgradeProgress(n, name, &obj)
+}
+
+// Deployment checks the readiness of a Deployment and creates one if it
+// doesn't exist
+func Deployment(n NFD) (ResourceStatus, error) {
+
+	// state represents the resource's 'control' function index
+	state := n.idx
+
+	// It is assumed that the index has already been verified to be a
+	// Deployment object, so let's get a copy of the resource's
+	// Deployment object (the pod template gets modified below)
+	obj := *n.resources[state].Deployment.DeepCopy()
+
+	// The options below depend on the operand, identified by the name
+	// of the asset, as for the DaemonSets
+	name := obj.GetName()
+	setInstance(n.ins, &obj, obj.Spec.Selector, &obj.Spec.Template)
+	setCommonLabels(n.ins, &obj)
+	if err := setPodTemplate(n, name, &obj.Spec.Template); err != nil {
+		return NotReady, err
+	}
+	if name == "nfd-master" && n.ins.Spec.Master.Replicas != nil {
+		obj.Spec.Replicas = n.ins.Spec.Master.Replicas
+	}
+
+	obj.SetNamespace(n.ins.GetNamespace())
+	if err := setTemplateHash(&obj, &obj.Spec.Template); err != nil {
+		return NotReady, err
+	}
+
+	// found states if the Deployment was found
+	found := &appsv1.Deployment{}
+	logger := log.WithValues("Deployment", obj.Name, "Namespace", obj.Namespace)
+
+	if err := recordManifest(n, "Deployment", &obj); err != nil {
+		return NotReady, err
+	}
+
+	logger.Info("Looking for")
+
+	if err := controllerutil.SetControllerReference(n.ins, &obj, n.scheme); err != nil {
+		return NotReady, err
+	}
+
+	// Look for the Deployment to see if it exists. If it does not
+	// exist, then attempt to create it, otherwise update it
+	err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		logger.Info("Not found, creating")
+		err = apply(n, &obj)
+		if err != nil {
+			logger.Info("Couldn't create")
+			return NotReady, err
+		}
+	} else if err != nil {
+		return NotReady, err
+	} else if adopted, deleted, err := adoptWorkload(n, "Deployment", name, found, found.Spec.Selector, obj.Spec.Selector, found.Spec.Template.Labels); err != nil {
+		return NotReady, err
+	} else if deleted {
+		// The adopted Deployment is created again once it's gone
+		return NotReady, notReady("Deployment", obj.Name, "deleted to be adopted, waiting for it to be created again")
+	} else if proceed, err := guardTemplateChange(n, "Deployment", found, &obj); err != nil {
+		return NotReady, err
+	} else if !proceed {
+		// Keep the Deployment as is while its pod template changes
+		// too often
+		logger.Info("Pod template changing too often, not updating")
+		obj = *found
+	} else {
+		if !adopted {
+			if err := recordDrift(n, "Deployment", obj.Name, obj.Spec, found.Spec); err != nil {
+				return NotReady, err
+			}
+		}
+		logger.Info("Found, updating")
+		err = apply(n, &obj)
+		if err != nil {
+			return NotReady, err
+		}
+	}
+
+	// obj now reflects the live object. The duplicates of an adopted
+	// operand go away once it's rolled out.
+	if deploymentRolloutComplete(&obj) {
+		if err := deleteDuplicates(n, name, "Deployment", obj.Name); err != nil {
+			return NotReady, err
+		}
+	}
+	if name != "nfd-master" {
+		return Ready, nil
+	}
+	master, err := masterStatus(n, &obj)
+	if err != nil {
+		return NotReady, err
+	}
+	n.rollouts.Master = master
+
+	// nfd-master used to run from a DaemonSet, remove it once the
+	// Deployment has taken over
+	if obj.Status.AvailableReplicas > 0 {
+		if err := deleteOwnedDaemonSet(n, obj.Name); err != nil {
+			return NotReady, err
+		}
+	}
+
+	return masterUpgradeProgress(n, templateImage(&obj.Spec.Template), deploymentRolloutComplete(&obj),
+		notReady("Deployment", obj.Name, "%s", deploymentProgress(&obj)))
+}
+
+// masterStatus returns the readiness of the nfd-master replicas, and the
+// leader if they run with leader election
+func masterStatus(n NFD, d *appsv1.Deployment) (*nfdv1.MasterStatus, error) {
+	status := &nfdv1.MasterStatus{
+		Replicas:          d.Status.Replicas,
+		ReadyReplicas:     d.Status.ReadyReplicas,
+		AvailableReplicas: d.Status.AvailableReplicas,
+	}
+	if n.ins.Spec.Master.EnableLeaderElection {
+		lease := &coordinationv1.Lease{}
+		err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: n.ins.GetNamespace(), Name: MasterLeaseName}, lease)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		if err == nil && lease.Spec.HolderIdentity != nil {
+			status.Leader = *lease.Spec.HolderIdentity
+		}
+	}
+	return status, nil
+}
+
+// workerStatus returns the rollout progress of the nfd-worker DaemonSet
+func workerStatus(ds *appsv1.DaemonSet) *nfdv1.WorkerStatus {
+	return &nfdv1.WorkerStatus{
+		DesiredNumberScheduled: ds.Status.DesiredNumberScheduled,
+		NumberReady:            ds.Status.NumberReady,
+		UpdatedNumberScheduled: ds.Status.UpdatedNumberScheduled,
+		NumberUnavailable:      ds.Status.NumberUnavailable,
+	}
+}
+
+// deleteOwnedDaemonSet deletes the named DaemonSet, if it's controlled by
+// the NFD instance
+func deleteOwnedDaemonSet(n NFD, name string) error {
+	ds := &appsv1.DaemonSet{}
+	err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: n.ins.GetNamespace(), Name: name}, ds)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(ds, n.ins) {
+		return nil
+	}
+	log.Info("Deleting the DaemonSet replaced by a Deployment", "DaemonSet", name)
+	err = n.client.Delete(context.TODO(), ds)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// setPodTemplate applies the options of the CR to the pod template of the
+// operand whose asset has the given name, e.g. "nfd-master"
+func setPodTemplate(n NFD, name string, template *corev1.PodTemplateSpec) error {
+	setServiceAccount(n.ins, &template.Spec)
+	setPodMetadata(n.ins, template)
+
+	// Update the NFD operand image
+	template.Spec.Containers[0].Image = n.ins.Spec.Operand.ImagePath()
+
+	// Update the image pull policy
+	if n.ins.Spec.Operand.ImagePullPolicy != "" {
+		template.Spec.Containers[0].ImagePullPolicy = n.ins.Spec.Operand.ImagePolicy(n.ins.Spec.Operand.ImagePullPolicy)
+	}
+
+	// Apply the security context overrides of the operand, if any
+	if err := setSecurityContext(n.ins, name, &template.Spec); err != nil {
+		return err
+	}
+
+	// Connect through the cluster proxy, if any
+	if err := setProxy(n, template); err != nil {
+		return err
+	}
+
+	// Mount the certificate of the operand, if mutual TLS is enabled
+	if err := setTLS(n, name, template); err != nil {
+		return err
+	}
+
+	// Update nfd-master service port
+	if name == "nfd-master" {
+		var args []string
+
+		// Listen on the port the Service forwards to, and declare it
+		// on the container
+		port := masterPort(n.ins)
+		args = append(args, fmt.Sprintf("--port=%d", port))
+		template.Spec.Containers[0].Ports = []corev1.ContainerPort{{
+			Name:          "grpc",
+			ContainerPort: int32(port),
+			Protocol:      corev1.ProtocolTCP,
+		}}
+
+		// Check if running as instance. If not, then it is
+		// expected that n.ins.Spec.Instance will return ""
+		// https://kubernetes-sigs.github.io/node-feature-discovery/v0.8/advanced/master-commandline-reference.html#-instance
+		if n.ins.Spec.Instance != "" {
+			args = append(args, fmt.Sprintf("--instance=%s", n.ins.Spec.Instance))
+		}
+
+		// Restrict the published labels, if requested
+		if n.ins.Spec.LabelWhiteList != "" {
+			args = append(args, fmt.Sprintf("--label-whitelist=%s", n.ins.Spec.LabelWhiteList))
+		}
+
+		// Allow labels in additional namespaces, if requested
+		if len(n.ins.Spec.ExtraLabelNs) > 0 {
+			args = append(args, fmt.Sprintf("--extra-label-ns=%s", strings.Join(n.ins.Spec.ExtraLabelNs, ",")))
+		}
+
+		// Block labels in some namespaces, if requested, and in all the
+		// other ones than the default and extra ones if the namespace
+		// of the CR has a label namespace policy
+		deny := n.ins.Spec.DenyLabelNs
+		if n.labelNsIsolated {
+			deny = append(append([]string{}, deny...), "*")
+		}
+		if len(deny) > 0 {
+			args = append(args, fmt.Sprintf("--deny-label-ns=%s", strings.Join(deny, ",")))
+		}
+
+		// Advertise some features as extended resources, if requested
+		if len(n.ins.Spec.ResourceLabels) > 0 {
+			args = append(args, fmt.Sprintf("--resource-labels=%s", strings.Join(n.ins.Spec.ResourceLabels, ",")))
+		}
+
+		// Toggle the NFD features, if requested
+		if gates := featureGates(n.ins); len(gates) > 0 {
+			args = append(args, featureGatesArg(gates))
+		}
+
+		// Taint the nodes, if requested
+		if n.ins.Spec.EnableTaints {
+			args = append(args, "--enable-taints")
+		}
+
+		// Read the features from the NodeFeature objects, if requested
+		if n.ins.Spec.EnableNodeFeatureAPI {
+			args = append(args, "--enable-nodefeature-api")
+		}
+
+		// Leave the nodes alone when the features are published to
+		// ConfigMaps instead
+		if n.ins.Spec.Publishing.Mode == nfdv1.PublishNodeGroupConfigMaps {
+			args = append(args, "--no-publish")
+		}
+
+		// Elect a leader among the replicas, if requested
+		if n.ins.Spec.Master.EnableLeaderElection {
+			args = append(args, "--enable-leader-election")
+		}
+
+		// Pass the leader election and logging tunables, if any
+		opts, err := masterOptions(n.ins.Spec.Master)
+		if err != nil {
+			return err
+		}
+		if opts != "" {
+			args = append(args, fmt.Sprintf("--options=%s", opts))
+		}
+
+		// Authenticate the workers, if mutual TLS is enabled
+		args = append(args, tlsArgs(n.ins, name)...)
+
+		// Leave out the flags the operand version doesn't know
+		// about, and append the user provided args last so that
+		// they take precedence over the ones set by the operator
+		args = compatibleArgs(n.ins, name, args)
+		args = append(args, n.ins.Spec.Master.ExtraArgs...)
+
+		// Set the args based on the port that was determined
+		// and the instance that was determined
+		template.Spec.Containers[0].Args = args
+
+		template.Spec.Affinity = mergeAffinity(
+			template.Spec.Affinity, n.ins.Spec.Master.Affinity)
+
+		setResources(&template.Spec.Containers[0], n.ins.Spec.Master.Resources)
+		template.Spec.Containers[0].Env = mergeEnv(template.Spec.Containers[0].Env, n.ins.Spec.Master.Env)
+
+		if n.ins.Spec.Master.RuntimeClassName != nil {
+			template.Spec.RuntimeClassName = n.ins.Spec.Master.RuntimeClassName
+		}
+
+		// Mount the master configuration provided by the user, if
+		// any, and restart the pods whenever the configuration
+		// changes
+		hash := ""
+		if ref := n.ins.Spec.MasterConfig.ConfigMapRef; ref != nil {
+			var err error
+			hash, err = configMapKeyHash(n, ref, masterConfigFile)
+			if err != nil {
+				return err
+			}
+			setConfigVolume(&template.Spec, "nfd-master-config", ref, masterConfigFile)
+		} else if n.ins.Spec.MasterConfig.ConfigData != "" {
+			hash = fmt.Sprintf("%x", sha256.Sum256([]byte(n.ins.Spec.MasterConfig.ConfigData)))
+		}
+		if hash != "" {
+			if template.Annotations == nil {
+				template.Annotations = map[string]string{}
+			}
+			template.Annotations[masterConfigHashAnnotation] = hash
+		}
+	}
+
+	// Update nfd-worker scheduling options
+	if name == "nfd-worker" {
+		setMasterAddress(n.ins, &template.Spec.Containers[0])
+
+		template.Spec.Tolerations = mergeTolerations(
+			template.Spec.Tolerations, n.ins.Spec.Worker.Tolerations)
+
+		if len(n.ins.Spec.Worker.NodeSelector) > 0 {
+			if template.Spec.NodeSelector == nil {
+				template.Spec.NodeSelector = map[string]string{}
+			}
+			for k, v := range n.ins.Spec.Worker.NodeSelector {
+				template.Spec.NodeSelector[k] = v
+			}
+		}
+
+		template.Spec.Affinity = mergeAffinity(
+			template.Spec.Affinity, n.ins.Spec.Worker.Affinity)
+
+		setResources(&template.Spec.Containers[0], n.ins.Spec.Worker.Resources)
+		template.Spec.Containers[0].Env = mergeEnv(template.Spec.Containers[0].Env, n.ins.Spec.Worker.Env)
+
+		// Report the end of the logs, e.g. a configuration parse error,
+		// in the status of the pod when nfd-worker fails
+		if template.Spec.Containers[0].TerminationMessagePolicy == "" {
+			template.Spec.Containers[0].TerminationMessagePolicy = corev1.TerminationMessageFallbackToLogsOnError
+		}
+
+		if n.ins.Spec.Worker.RuntimeClassName != nil {
+			template.Spec.RuntimeClassName = n.ins.Spec.Worker.RuntimeClassName
+		}
+
+		// Mount the worker configuration provided by the user, if
+		// any, and restart the pods whenever the configuration
+		// changes, whether it's in the user's ConfigMap or in the
+		// one generated from the CR
+		hash := ""
+		if ref := n.ins.Spec.WorkerConfig.ConfigMapRef; ref != nil {
+			var err error
+			hash, err = configMapKeyHash(n, ref, workerConfigFile)
+			if err != nil {
+				return err
+			}
+			setConfigVolume(&template.Spec, "nfd-worker-config", ref, workerConfigFile)
+		} else if data := n.ins.Spec.WorkerConfig.ConfigData; data != "" {
+			hash = fmt.Sprintf("%x", sha256.Sum256([]byte(compatibleWorkerConfig(n.ins, data))))
+		}
+		if hash != "" {
+			if template.Annotations == nil {
+				template.Annotations = map[string]string{}
+			}
+			template.Annotations[workerConfigHashAnnotation] = hash
+		}
+
+		// Set the feature discovery interval, if requested
+		if n.ins.Spec.Worker.SleepInterval != nil {
+			template.Spec.Containers[0].Args = append(template.Spec.Containers[0].Args,
+				fmt.Sprintf("--sleep-interval=%s", n.ins.Spec.Worker.SleepInterval.Duration))
+		}
+
+		// Toggle the NFD features, if requested
+		if gates := featureGates(n.ins); len(gates) > 0 {
+			template.Spec.Containers[0].Args = append(template.Spec.Containers[0].Args,
+				featureGatesArg(gates))
+		}
+
+		// Publish the features in NodeFeature objects, if requested.
+		// The nfd-master Service may then be gone, so don't point
+		// nfd-worker at it.
+		if n.ins.Spec.EnableNodeFeatureAPI {
+			args := []string{"--enable-nodefeature-api"}
+			for _, arg := range template.Spec.Containers[0].Args {
+				if !strings.HasPrefix(arg, "--server=") {
+					args = append(args, arg)
+				}
+			}
+			template.Spec.Containers[0].Args = args
+		}
+
+		// Authenticate to nfd-master, if mutual TLS is enabled
+		template.Spec.Containers[0].Args = append(template.Spec.Containers[0].Args, tlsArgs(n.ins, name)...)
+
+		// Leave out the flags the operand version doesn't know
+		// about, and append the user provided args last so that
+		// they take precedence over the ones set by the operator
+		template.Spec.Containers[0].Args = compatibleArgs(n.ins, name, template.Spec.Containers[0].Args)
+		template.Spec.Containers[0].Args = append(
+			template.Spec.Containers[0].Args, n.ins.Spec.Worker.ExtraArgs...)
+	}
+
+	// Update nfd-topology-updater image and args
+	if name == "nfd-topology-updater" {
+		setMasterAddress(n.ins, &template.Spec.Containers[0])
+
+		if n.ins.Spec.TopologyUpdater.Image != "" {
+			template.Spec.Containers[0].Image = n.ins.Spec.TopologyUpdater.Image
+		}
+
+		// Authenticate to nfd-master, if mutual TLS is enabled
+		template.Spec.Containers[0].Args = append(template.Spec.Containers[0].Args, tlsArgs(n.ins, name)...)
+
+		// Append the user provided args last so that they take
+		// precedence over the ones set by the operator
+		template.Spec.Containers[0].Args = append(
+			template.Spec.Containers[0].Args, n.ins.Spec.TopologyUpdater.ExtraArgs...)
+	}
+
+	// Update nfd-gc args
+	if name == "nfd-gc" {
+		if n.ins.Spec.GC.Interval != nil {
+			template.Spec.Containers[0].Args = append(template.Spec.Containers[0].Args,
+				fmt.Sprintf("--gc-interval=%s", n.ins.Spec.GC.Interval.Duration))
+		}
+
+		// Append the user provided args last so that they take
+		// precedence over the ones set by the operator
+		template.Spec.Containers[0].Args = append(
+			template.Spec.Containers[0].Args, n.ins.Spec.GC.ExtraArgs...)
+	}
+
+	return nil
+}
+
+// Service checks if a Service exists and creates one if it doesn't exist
+func Service(n NFD) (ResourceStatus, error) {
+
+	// state represents the resource's 'control' function index
+	state := n.idx
+
+	// It is assumed that the index has already been verified to be a
+	// Service object, so let's get the resource's Service object
+	obj := *n.resources[state].Service.DeepCopy()
+
+	// Select the nfd-master pods of this instance
+	obj.SetName(InstanceName(n.ins, obj.GetName()))
+	if n.ins.Spec.Instance != "" {
+		obj.Spec.Selector[instanceLabel] = n.ins.Spec.Instance
+	}
+	setCommonLabels(n.ins, &obj)
+
+	// Update ports for the Service, forwarding the Service port to the
+	// port nfd-master listens on
+	obj.Spec.Ports[0].Port = int32(servicePort(n.ins))
+	obj.Spec.Ports[0].TargetPort = intstr.FromInt(masterPort(n.ins))
+
+	// Set namespace based on the NFD namespace. (And again,
+	// it is assumed that the Namespace has already been
+	// determined before this function was called.)
+	obj.SetNamespace(n.ins.GetNamespace())
+
+	// The nfd-master Service is only used for gRPC, which the NodeFeature
+	// API replaces, so remove it when nothing connects to nfd-master
+	if n.resources[state].Service.GetName() == "nfd-master" && !masterServiceNeeded(&n.ins.Spec) {
+		key := types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}
+		if err := deleteIf(n, key, &corev1.Service{}, "Not needed, deleting", func(found client.Object) bool {
+			return metav1.IsControlledBy(found, n.ins)
+		}); err != nil {
+			return NotReady, err
+		}
+		return Ready, nil
+	}
+
+	// found states if the Service was found
+	found := &corev1.Service{}
+	logger := log.WithValues("Service", obj.Name, "Namespace", obj.Namespace)
+
+	if err := recordManifest(n, "Service", &obj); err != nil {
+		return NotReady, err
+	}
+
+	logger.Info("Looking for")
+
+	// SetControllerReference sets the owner as a Controller OwnerReference
+	// and is used for garbage collection of the controlled object. It is
+	// also used to reconcile the owner object on changes to the controlled
+	// object. If we cannot set the owner, then return NotReady
+	if err := controllerutil.SetControllerReference(n.ins, &obj, n.scheme); err != nil {
+		return NotReady, err
+	}
+
+	// Look for the Service to see if it exists, and if so, check if it's
+	// Ready/NotReady. If the Service does not exist, then attempt to create
+	// it
+	err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		logger.Info("Not found, creating")
+		err = apply(n, &obj)
+		if err != nil {
+			logger.Info("Couldn't create")
+			return NotReady, err
+		}
+		return Ready, nil
+	} else if err != nil {
+		return NotReady, err
+	}
+
+	// If we found the Service, let's attempt to update it. The cluster IP
+	// allocated to it isn't rendered, so it's kept as is.
+	logger.Info("Found, updating")
+	err = apply(n, &obj)
+	if err != nil {
+		return NotReady, err
+	}
+
+	return Ready, nil
+}
+
+// PodDisruptionBudget checks if a PodDisruptionBudget exists and creates
+// one if it doesn't exist. The budget of nfd-master is only needed, and
+// only created, when it runs more than one replica, as a single replica
+// budget would keep the nodes from being drained.
+func PodDisruptionBudget(n NFD) (ResourceStatus, error) {
+
+	// state represents the resource's 'control' function index
+	state := n.idx
+
+	// It is assumed that the index has already been verified to be a
+	// PodDisruptionBudget object, so let's get a copy of the resource's
+	// PodDisruptionBudget object
+	obj := *n.resources[state].PodDisruptionBudget.DeepCopy()
+
+	// Select the pods of this instance
+	name := obj.GetName()
+	obj.SetName(InstanceName(n.ins, name))
+	obj.SetNamespace(n.ins.GetNamespace())
+	setCommonLabels(n.ins, &obj)
+	if obj.Spec.Selector == nil {
+		obj.Spec.Selector = &metav1.LabelSelector{}
+	}
+	if n.ins.Spec.Instance != "" {
+		if obj.Spec.Selector.MatchLabels == nil {
+			obj.Spec.Selector.MatchLabels = map[string]string{}
+		}
+		obj.Spec.Selector.MatchLabels[instanceLabel] = n.ins.Spec.Instance
+	}
+
+	// found states if the PodDisruptionBudget was found
+	found := &policyv1beta1.PodDisruptionBudget{}
+	logger := log.WithValues("PodDisruptionBudget", obj.Name, "Namespace", obj.Namespace)
+
+	if name == "nfd-master" {
+		if n.ins.Spec.Master.Replicas == nil || *n.ins.Spec.Master.Replicas <= 1 {
+			logger.Info("Single replica, deleting")
+			err := n.client.Delete(context.TODO(), &obj)
+			if err != nil && !errors.IsNotFound(err) {
+				return NotReady, err
+			}
+			return Ready, nil
+		}
+		if pdb := n.ins.Spec.Master.PodDisruptionBudget; pdb != nil {
+			obj.Spec.MinAvailable = pdb.MinAvailable
+			obj.Spec.MaxUnavailable = pdb.MaxUnavailable
+		}
+	}
+
+	if err := recordManifest(n, "PodDisruptionBudget", &obj); err != nil {
+		return NotReady, err
+	}
+
+	logger.Info("Looking for")
+
+	// SetControllerReference sets the owner as a Controller OwnerReference
+	// and is used for garbage collection of the controlled object. It is
+	// also used to reconcile the owner object on changes to the controlled
+	// object. If we cannot set the owner, then return NotReady
+	if err := controllerutil.SetControllerReference(n.ins, &obj, n.scheme); err != nil {
+		return NotReady, err
+	}
+
+	// Look for the PodDisruptionBudget to see if it exists. If it does
+	// not exist, then attempt to create it, otherwise update it
+	err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		logger.Info("Not found, creating")
+		err = apply(n, &obj)
+		if err != nil {
+			logger.Info("Couldn't create")
+			return NotReady, err
+		}
+		return Ready, nil
+	} else if err != nil {
+		return NotReady, err
+	}
+
+	logger.Info("Found, updating")
+	err = apply(n, &obj)
+	if err != nil {
+		return NotReady, err
+	}
+
+	return Ready, nil
+}
+
+// SecurityContextConstraints checks if a SecurityContextConstraints exists and
+// creates one if it doesn't exist
+func SecurityContextConstraints(n NFD) (ResourceStatus, error) {
+
+	// state represents the resource's 'control' function index
+	state := n.idx
+
+	// It is assumed that the index has already been verified to be an
+	// scc object, so let's get the resource's scc object
+	obj := *n.resources[state].SecurityContextConstraints.DeepCopy()
+
+	// Set the correct namespace for SCC when installed in non default
+	// namespace, and the ServiceAccount the operand runs as
+	obj.Users[0] = "system:serviceaccount:" + n.ins.GetNamespace() + ":" + serviceAccountName(n.ins, obj.GetName())
+	obj.SetName(InstanceName(n.ins, obj.GetName()))
+	setCommonLabels(n.ins, &obj)
+
+	// found states if the scc was found
+	found := &secv1.SecurityContextConstraints{}
+	logger := log.WithValues("SecurityContextConstraints", obj.Name, "Namespace", "default")
+
+	if err := recordManifest(n, "SecurityContextConstraints", &obj); err != nil {
+		return NotReady, err
+	}
+
+	logger.Info("Looking for")
+
+	// Look for the scc to see if it exists, and if so, check if it's
+	// Ready/NotReady. If the scc does not exist, then attempt to create
+	// it
+	err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: "", Name: obj.Name}, found)
+	if err != nil && errors.IsNotFound(err) {
+		logger.Info("Not found, creating")
+		err = apply(n, &obj)
+		if err != nil {
+			logger.Info("Couldn't create", "Error", err)
+			return NotReady, err
+		}
+		return Ready, nil
+	} else if err != nil {
+		return NotReady, err
+	}
+
+	// If we found the scc, let's attempt to update it
+	logger.Info("Found, updating")
+	err = apply(n, &obj)
+	if err != nil {
+		return NotReady, err
+	}
+
+	return Ready, nil
+}
+
+// ConsoleYAMLSample checks if a ConsoleYAMLSample exists and creates one if it
+// doesn't exist. The sample is only managed when the OpenShift console API is
+// available on the cluster.
+func ConsoleYAMLSample(n NFD) (ResourceStatus, error) {
+
+	// state represents the resource's 'control' function index
+	state := n.idx
+
+	// It is assumed that the index has already been verified to be a
+	// ConsoleYAMLSample object, so let's get a copy of the resource's
+	// ConsoleYAMLSample object
+	obj := n.resources[state].ConsoleYAMLSample.DeepCopy()
+	setCommonLabels(n.ins, obj)
+
+	// found states if the ConsoleYAMLSample was found
+	found := &unstructured.Unstructured{}
+	found.SetGroupVersionKind(obj.GroupVersionKind())
+	logger := log.WithValues("ConsoleYAMLSample", obj.GetName(), "Namespace", "Cluster")
+
+	if err := recordManifest(n, "ConsoleYAMLSample", obj); err != nil {
+		return NotReady, err
+	}
+
+	logger.Info("Looking for")
+
+	// Look for the ConsoleYAMLSample to see if it exists, and if so, check
+	// if it's Ready/NotReady. If the console API is not served by the
+	// cluster there's nothing to do. If the ConsoleYAMLSample does not
+	// exist, then attempt to create it
+	err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: "", Name: obj.GetName()}, found)
+	if meta.IsNoMatchError(err) {
+		logger.Info("Console API not available, skipping")
+		return Ready, nil
+	} else if err != nil && errors.IsNotFound(err) {
+		logger.Info("Not found, creating")
+		err = apply(n, obj)
+		if err != nil {
+			logger.Info("Couldn't create")
+			return NotReady, err
+		}
+		return Ready, nil
+	} else if err != nil {
+		return NotReady, err
+	}
+
+	// If we found the ConsoleYAMLSample, let's attempt to update it
+	logger.Info("Found, updating")
+	err = apply(n, obj)
+	if err != nil {
+		return NotReady, err
+	}
+
+	return Ready, nil
+}
+
+// Unstructured returns the control function of the i-th generic object of
+// a state. The object is created or updated and its readiness is evaluated
+// according to its readinessAnnotation.
+func Unstructured(i int) func(n NFD) (ResourceStatus, error) {
+	return func(n NFD) (ResourceStatus, error) {
+
+		// state represents the resource's 'control' function index
+		state := n.idx
+
+		obj := n.resources[state].Unstructured[i].DeepCopy()
+		setCommonLabels(n.ins, obj)
+
+		// Adapt the cert-manager resources to the NFD instance
+		if obj.GroupVersionKind().Group == certManagerGroup {
+			apply, err := setCertManagerResource(n, obj)
+			if err != nil {
+				return NotReady, err
+			}
+			if !apply {
+				return Ready, nil
+			}
+		}
+
+		// Namespaced objects go to the NFD namespace and are owned by
+		// the NFD object, like all the other operand resources
+		mapping, err := n.client.RESTMapper().RESTMapping(obj.GroupVersionKind().GroupKind(), obj.GroupVersionKind().Version)
+		if meta.IsNoMatchError(err) {
+			return NotReady, fmt.Errorf("the %s API is not installed: %w", obj.GroupVersionKind().GroupVersion(), err)
+		} else if err != nil {
+			return NotReady, err
+		}
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			obj.SetNamespace(n.ins.GetNamespace())
+			if err := controllerutil.SetControllerReference(n.ins, obj, n.scheme); err != nil {
+				return NotReady, err
+			}
+		}
+
+		// found states if the object was found
+		found := &unstructured.Unstructured{}
+		found.SetGroupVersionKind(obj.GroupVersionKind())
+		logger := log.WithValues(obj.GetKind(), obj.GetName(), "Namespace", obj.GetNamespace())
+
+		if err := recordManifest(n, obj.GetKind(), obj); err != nil {
+			return NotReady, err
+		}
+
+		logger.Info("Looking for")
+
+		// Look for the object to see if it exists. If it does not
+		// exist, then attempt to create it, otherwise update it
+		err = n.client.Get(context.TODO(), types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}, found)
+		if err != nil && errors.IsNotFound(err) {
+			logger.Info("Not found, creating")
+			err = apply(n, obj)
+			if err != nil {
+				logger.Info("Couldn't create")
+				return NotReady, err
+			}
+		} else if err != nil {
+			return NotReady, err
+		} else {
+			logger.Info("Found, updating")
+			err = apply(n, obj)
+			if err != nil {
+				return NotReady, err
+			}
+		}
+
+		// obj now reflects the live object, check whether it's ready
+		ready, err := isReady(&n.resources[state].Unstructured[i], obj)
+		if err != nil {
+			return NotReady, err
+		}
+		if !ready {
+			criterion := n.resources[state].Unstructured[i].GetAnnotations()[readinessAnnotation]
+			logger.Info("Not ready yet", "readiness", criterion)
+			return NotReady, notReady(obj.GetKind(), obj.GetName(), "readiness criterion %q not met", criterion)
+		}
+
+		return Ready, nil
+	}
+}
+
+// mergeTolerations appends the tolerations in 'extra' to 'tolerations',
+// skipping the ones that are already present
+func mergeTolerations(tolerations, extra []corev1.Toleration) []corev1.Toleration {
+	for i := range extra {
+		found := false
+		for j := range tolerations {
+			if tolerations[j].MatchToleration(&extra[i]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			tolerations = append(tolerations, extra[i])
+		}
+	}
+	return tolerations
+}
+
+// featureGatesArg renders the -feature-gates flag. The gates are sorted so
+// that the pod template, and thus the pods, don't change between reconciles.
+func featureGatesArg(gates map[string]bool) string {
+	names := make([]string, 0, len(gates))
+	for name := range gates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make([]string, 0, len(names))
+	for _, name := range names {
+		values = append(values, fmt.Sprintf("%s=%t", name, gates[name]))
+	}
+	return "--feature-gates=" + strings.Join(values, ",")
+}
+
+// masterOptions renders the nfd-master configuration overrides passed with
+// the -options flag. It returns an empty string if there is nothing to
+// override.
+func masterOptions(spec nfdv1.MasterSpec) (string, error) {
+	opts := map[string]interface{}{}
+	if spec.LeaderElection != nil {
+		opts["leaderElection"] = spec.LeaderElection
+	}
+	if spec.StderrThreshold != "" {
+		opts["klog"] = map[string]string{"stderrthreshold": spec.StderrThreshold}
+	}
+	if len(opts) == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// InstanceName returns the name of an operand resource, suffixed with the
+// name of the NFD instance, if any
+func InstanceName(ins *nfdv1.NodeFeatureDiscovery, name string) string {
+	if ins.Spec.Instance == "" {
+		return name
+	}
+	return name + "-" + ins.Spec.Instance
+}
+
+// setCommonLabels stamps the recommended labels on a managed object. The
+// labels map is copied, as it may be shared with the decoded asset. Values
+// that aren't valid label values (e.g. a NodeFeatureDiscovery name longer
+// than 63 characters) are left out rather than failing the reconcile.
+func setCommonLabels(ins *nfdv1.NodeFeatureDiscovery, obj metav1.Object) {
+	labels := map[string]string{}
+	for k, v := range obj.GetLabels() {
+		labels[k] = v
+	}
+
+	common := map[string]string{
+		managedByLabel: managedByValue,
+		partOfLabel:    partOfValue,
+		appInstLabel:   ins.GetName(),
+		versionLabel:   version.Version,
+	}
+	for k, v := range common {
+		if len(validation.IsValidLabelValue(v)) == 0 {
+			labels[k] = v
+		}
+	}
+	obj.SetLabels(labels)
+}
+
+// SetCommonLabels stamps the recommended labels on an object the operator
+// manages outside of the assets
+func SetCommonLabels(ins *nfdv1.NodeFeatureDiscovery, obj metav1.Object) {
+	setCommonLabels(ins, obj)
+}
+
+// setSubjects points the ServiceAccount subjects of a binding to the
+// ServiceAccounts of the NFD instance
+func setSubjects(ins *nfdv1.NodeFeatureDiscovery, subjects []rbacv1.Subject) {
+	for i := range subjects {
+		if subjects[i].Kind != rbacv1.ServiceAccountKind {
+			continue
+		}
+		subjects[i].Name = serviceAccountName(ins, subjects[i].Name)
+		subjects[i].Namespace = ins.GetNamespace()
+	}
+}
+
+// PodSelector returns the labels of the pods of the given operand, e.g.
+// "nfd-worker", of an NFD instance
+func PodSelector(ins *nfdv1.NodeFeatureDiscovery, app string) client.MatchingLabels {
+	labels := client.MatchingLabels{"app": app}
+	if ins.Spec.Instance != "" {
+		labels[instanceLabel] = ins.Spec.Instance
+	}
+	return labels
+}
+
+// serviceAccountName returns the name of the ServiceAccount the operand
+// whose ServiceAccount asset has the given name runs as
+func serviceAccountName(ins *nfdv1.NodeFeatureDiscovery, name string) string {
+	if ins.Spec.Operand.ServiceAccountName != "" {
+		return ins.Spec.Operand.ServiceAccountName
+	}
+	return InstanceName(ins, name)
+}
+
+// setServiceAccount sets the ServiceAccount an operand pod runs as
+func setServiceAccount(ins *nfdv1.NodeFeatureDiscovery, spec *corev1.PodSpec) {
+	if spec.ServiceAccountName != "" {
+		spec.ServiceAccountName = serviceAccountName(ins, spec.ServiceAccountName)
+	}
+	if spec.DeprecatedServiceAccount != "" {
+		spec.DeprecatedServiceAccount = serviceAccountName(ins, spec.DeprecatedServiceAccount)
+	}
+}
+
+// setPodMetadata adds the labels and annotations given in the CR to an
+// operand pod template. The labels of the template are kept, as the
+// DaemonSet selects the pods with them, and the annotations set by the
+// operator afterwards override the user provided ones.
+func setPodMetadata(ins *nfdv1.NodeFeatureDiscovery, template *corev1.PodTemplateSpec) {
+	if len(ins.Spec.Operand.PodLabels) > 0 && template.Labels == nil {
+		template.Labels = map[string]string{}
+	}
+	for k, v := range ins.Spec.Operand.PodLabels {
+		if _, ok := template.Labels[k]; !ok {
+			template.Labels[k] = v
+		}
+	}
+
+	if len(ins.Spec.Operand.PodAnnotations) > 0 && template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	for k, v := range ins.Spec.Operand.PodAnnotations {
+		template.Annotations[k] = v
+	}
+}
+
+// setInstance renames an operand workload, and the resources it refers
+// to, after the NFD instance and labels its pods with the instance name
+func setInstance(ins *nfdv1.NodeFeatureDiscovery, obj metav1.Object, selector *metav1.LabelSelector, template *corev1.PodTemplateSpec) {
+	if ins.Spec.Instance == "" {
+		return
+	}
+
+	obj.SetName(InstanceName(ins, obj.GetName()))
+
+	// Both the selector and the pod labels need the instance label, the
+	// former being a subset of the latter
+	if selector.MatchLabels == nil {
+		selector.MatchLabels = map[string]string{}
+	}
+	selector.MatchLabels[instanceLabel] = ins.Spec.Instance
+	if template.Labels == nil {
+		template.Labels = map[string]string{}
+	}
+	template.Labels[instanceLabel] = ins.Spec.Instance
+
+	spec := &template.Spec
+	for i := range spec.Volumes {
+		if cm := spec.Volumes[i].ConfigMap; cm != nil {
+			cm.Name = InstanceName(ins, cm.Name)
+		}
+	}
+}
+
+// setMasterAddress points the --server argument of nfd-worker or
+// nfd-topology-updater to the nfd-master Service of the instance. The port
+// is given explicitly, rather than through the environment variables of
+// the Service, which only exist in the pods created after the Service.
+func setMasterAddress(ins *nfdv1.NodeFeatureDiscovery, container *corev1.Container) {
+	for i, arg := range container.Args {
+		if strings.HasPrefix(arg, "--server=") {
+			container.Args[i] = fmt.Sprintf("--server=%s:%d", InstanceName(ins, "nfd-master"), servicePort(ins))
+		}
+	}
+}
+
+// masterPort returns the port nfd-master listens on
+func masterPort(ins *nfdv1.NodeFeatureDiscovery) int {
+	if ins.Spec.Master.Port != 0 {
+		return int(ins.Spec.Master.Port)
+	}
+	if ins.Spec.Operand.ServicePort != 0 {
+		return ins.Spec.Operand.ServicePort
+	}
+	return defaultServicePort
+}
+
+// servicePort returns the port of the nfd-master Service
+func servicePort(ins *nfdv1.NodeFeatureDiscovery) int {
+	if ins.Spec.Operand.ServicePort != 0 {
+		return ins.Spec.Operand.ServicePort
+	}
+	return masterPort(ins)
+}
+
+// operandConfig returns the configuration given in the CR for the operand
+// ConfigMap of the given name: the inline configuration data, the
+// reference to a user provided ConfigMap and the key of the operand
+// ConfigMap holding the configuration file.
+func operandConfig(ins *nfdv1.NodeFeatureDiscovery, name string) (string, *nfdv1.ConfigMapReference, string) {
+	switch name {
+	case "nfd-worker":
+		return ins.Spec.WorkerConfig.ConfigData, ins.Spec.WorkerConfig.ConfigMapRef, "nfd-worker-conf"
+	case "nfd-master":
+		return ins.Spec.MasterConfig.ConfigData, ins.Spec.MasterConfig.ConfigMapRef, "nfd-master-conf"
+	case "nfd-topology-updater":
+		return ins.Spec.TopologyUpdater.ConfigData, nil, "nfd-topology-updater-conf"
+	}
+	return "", nil, ""
+}
+
+// configMapKeyHash returns the SHA256 hash of the content of the
+// referenced ConfigMap key, which is used to trigger a rollout of the pods
+// mounting it whenever it changes.
+func configMapKeyHash(n NFD, ref *nfdv1.ConfigMapReference, defaultKey string) (string, error) {
+	cm := &corev1.ConfigMap{}
+	err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: n.ins.GetNamespace(), Name: ref.Name}, cm)
+	if err != nil {
+		return "", fmt.Errorf("could not get ConfigMap %q: %w", ref.Name, err)
+	}
+
+	key := ref.Key
+	if key == "" {
+		key = defaultKey
+	}
+	data, ok := cm.Data[key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found in ConfigMap %q", key, ref.Name)
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(data))), nil
+}
+
+// setConfigVolume points the named ConfigMap volume of the pod to the key
+// of the referenced ConfigMap, mounted as the given file
+func setConfigVolume(spec *corev1.PodSpec, volume string, ref *nfdv1.ConfigMapReference, file string) {
+	key := ref.Key
+	if key == "" {
+		key = file
+	}
+	for i := range spec.Volumes {
+		if spec.Volumes[i].Name != volume {
+			continue
+		}
+		spec.Volumes[i].VolumeSource = corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: ref.Name},
+				Items:                []corev1.KeyToPath{{Key: key, Path: file}},
+			},
+		}
+	}
+}
+
+// setResources sets the compute resources of the given container, unless
+// no requests or limits were given in which case the asset defaults are kept
+func setResources(c *corev1.Container, resources corev1.ResourceRequirements) {
+	if len(resources.Requests) == 0 && len(resources.Limits) == 0 {
+		return
+	}
+	c.Resources = *resources.DeepCopy()
+}
+
+// mergeEnv returns the environment variables of 'env' overridden by, or
+// else completed with, the ones of 'extra'
+func mergeEnv(env, extra []corev1.EnvVar) []corev1.EnvVar {
+	for _, e := range extra {
+		replaced := false
+		for i := range env {
+			if env[i].Name == e.Name {
+				env[i] = *e.DeepCopy()
+				replaced = true
+			}
+		}
+		if !replaced {
+			env = append(env, *e.DeepCopy())
+		}
+	}
+	return env
+}
+
+// mergeAffinity deep-merges 'extra' into 'affinity'. Required node
+// affinity terms are combined so that both the original and the extra
+// constraints must be satisfied, whereas all other terms are appended.
+func mergeAffinity(affinity, extra *corev1.Affinity) *corev1.Affinity {
+	if extra == nil {
+		return affinity
+	}
+	if affinity == nil {
+		return extra.DeepCopy()
+	}
+
+	merged := affinity.DeepCopy()
+	extra = extra.DeepCopy()
+
+	if extra.NodeAffinity != nil {
+		if merged.NodeAffinity == nil {
+			merged.NodeAffinity = &corev1.NodeAffinity{}
+		}
+		merged.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = mergeNodeSelectors(
+			merged.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution,
+			extra.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution)
+		merged.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+			merged.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+			extra.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution...)
+	}
+
+	if extra.PodAffinity != nil {
+		if merged.PodAffinity == nil {
+			merged.PodAffinity = &corev1.PodAffinity{}
+		}
+		merged.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(
+			merged.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution,
+			extra.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution...)
+		merged.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+			merged.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+			extra.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution...)
+	}
+
+	if extra.PodAntiAffinity != nil {
+		if merged.PodAntiAffinity == nil {
+			merged.PodAntiAffinity = &corev1.PodAntiAffinity{}
+		}
+		merged.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(
+			merged.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution,
+			extra.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution...)
+		merged.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+			merged.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+			extra.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution...)
+	}
+
+	return merged
+}
+
+// mergeNodeSelectors returns a NodeSelector that matches only the nodes
+// matched by both 'selector' and 'extra'. Since the terms of a NodeSelector
+// are ORed, every term of 'selector' is combined with every term of 'extra'.
+func mergeNodeSelectors(selector, extra *corev1.NodeSelector) *corev1.NodeSelector {
+	if extra == nil || len(extra.NodeSelectorTerms) == 0 {
+		return selector
+	}
+	if selector == nil || len(selector.NodeSelectorTerms) == 0 {
+		return extra
+	}
+
+	merged := &corev1.NodeSelector{}
+	for _, s := range selector.NodeSelectorTerms {
+		for _, e := range extra.NodeSelectorTerms {
+			term := *s.DeepCopy()
+			term.MatchExpressions = append(term.MatchExpressions, e.MatchExpressions...)
+			term.MatchFields = append(term.MatchFields, e.MatchFields...)
+			merged.NodeSelectorTerms = append(merged.NodeSelectorTerms, term)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestFeatureGatesArg(t *testing.T) {
+	got := featureGatesArg(map[string]bool{"NodeFeatureAPI": true, "DisableAutoPrefix": false})
+	want := "--feature-gates=DisableAutoPrefix=false,NodeFeatureAPI=true"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMergeTolerations(t *testing.T) {
+	master := corev1.Toleration{Key: "node-role.kubernetes.io/master", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}
+	gpu := corev1.Toleration{Key: "nvidia.com/gpu", Operator: corev1.TolerationOpExists}
+
+	got := mergeTolerations([]corev1.Toleration{master}, []corev1.Toleration{master, gpu})
+	want := []corev1.Toleration{master, gpu}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMergeEnv(t *testing.T) {
+	env := []corev1.EnvVar{{Name: "NODE_NAME", Value: "a"}, {Name: "GOGC", Value: "100"}}
+	extra := []corev1.EnvVar{{Name: "GOGC", Value: "50"}, {Name: "HTTP_PROXY", Value: "http://proxy"}}
+
+	got := mergeEnv(env, extra)
+	want := []corev1.EnvVar{{Name: "NODE_NAME", Value: "a"}, {Name: "GOGC", Value: "50"}, {Name: "HTTP_PROXY", Value: "http://proxy"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// nodeSelector returns a NodeSelector whose terms each have one
+// requirement on the given label
+func nodeSelector(keys ...string) *corev1.NodeSelector {
+	s := &corev1.NodeSelector{}
+	for _, k := range keys {
+		s.NodeSelectorTerms = append(s.NodeSelectorTerms, corev1.NodeSelectorTerm{
+			MatchExpressions: []corev1.NodeSelectorRequirement{{Key: k, Operator: corev1.NodeSelectorOpExists}},
+		})
+	}
+	return s
+}
+
+// termKeys returns the keys of the requirements of each term
+func termKeys(s *corev1.NodeSelector) [][]string {
+	keys := [][]string{}
+	for _, term := range s.NodeSelectorTerms {
+		k := []string{}
+		for _, e := range term.MatchExpressions {
+			k = append(k, e.Key)
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func TestMergeNodeSelectors(t *testing.T) {
+	if got := mergeNodeSelectors(nodeSelector("a"), nil); !reflect.DeepEqual(got, nodeSelector("a")) {
+		t.Errorf("nil extra: got %v", got)
+	}
+	if got := mergeNodeSelectors(nil, nodeSelector("b")); !reflect.DeepEqual(got, nodeSelector("b")) {
+		t.Errorf("nil selector: got %v", got)
+	}
+
+	// (a OR b) AND (c OR d)
+	got := termKeys(mergeNodeSelectors(nodeSelector("a", "b"), nodeSelector("c", "d")))
+	want := [][]string{{"a", "c"}, {"a", "d"}, {"b", "c"}, {"b", "d"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMergeAffinity(t *testing.T) {
+	extra := &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: nodeSelector("b")},
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{Weight: 1}},
+		},
+	}
+	if got := mergeAffinity(nil, extra); !reflect.DeepEqual(got, extra) || got == extra {
+		t.Errorf("nil affinity: got %v, want a copy of the extra one", got)
+	}
+
+	affinity := &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: nodeSelector("a")},
+	}
+	if got := mergeAffinity(affinity, nil); got != affinity {
+		t.Errorf("nil extra: got %v", got)
+	}
+
+	got := mergeAffinity(affinity, extra)
+	if keys := termKeys(got.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution); !reflect.DeepEqual(keys, [][]string{{"a", "b"}}) {
+		t.Errorf("required node affinity not combined: %v", keys)
+	}
+	if got.PodAntiAffinity == nil || len(got.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution) != 1 {
+		t.Errorf("pod anti-affinity not appended: %v", got.PodAntiAffinity)
+	}
+	if keys := termKeys(affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution); !reflect.DeepEqual(keys, [][]string{{"a"}}) {
+		t.Errorf("affinity modified: %v", keys)
+	}
+}
@@ -0,0 +1,39 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package deployment renders the NFD operand resources from the assets
+// given by an AssetsProvider, applies them and checks their readiness, for
+// a NodeFeatureDiscovery object. It only depends on a controller-runtime
+// client, so it can be reused by other operators embedding NFD, and tested
+// with a fake client and in-memory assets.
+//
+// Typical usage, from a reconcile loop, the platform being detected once
+// at startup with DetectPlatform:
+//
+//	var nfd deployment.NFD
+//
+//	if err := nfd.Init(client, scheme, assets, platform, instance); err != nil {
+//		return err
+//	}
+//	for !nfd.Last() {
+//		if err := nfd.Step(); err != nil {
+//			return err
+//		}
+//	}
+//
+// The status of the operands, see Components and Rollouts, is left to the
+// caller to report in the status of the NodeFeatureDiscovery object.
+package deployment
@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// maxDriftPaths bounds the number of drifted fields reported per object
+const maxDriftPaths = 10
+
+// driftReports holds the fields of the live objects that differed from
+// the rendered ones and were reverted, by "<kind>/<name>"
+type driftReports map[string][]string
+
+// specDrift compares the rendered spec of an object with the live one,
+// field by field, and returns the paths of the fields that differ. Only
+// the fields set in the rendered spec are compared, as the live one also
+// holds the defaults set by the API server. Lists are compared item by
+// item, and differ if their lengths do, e.g. when a volume mount was
+// removed.
+func specDrift(desired, live interface{}) ([]string, error) {
+	var d, l interface{}
+	for _, v := range []struct {
+		in  interface{}
+		out *interface{}
+	}{{desired, &d}, {live, &l}} {
+		data, err := json.Marshal(v.in)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, v.out); err != nil {
+			return nil, err
+		}
+	}
+
+	paths := []string{}
+	diffFields("spec", d, l, &paths)
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// diffFields appends to paths the path of the fields set in desired whose
+// value differs in live
+func diffFields(path string, desired, live interface{}, paths *[]string) {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			if !isZero(desired) {
+				*paths = append(*paths, path)
+			}
+			return
+		}
+		for k, v := range d {
+			lv, ok := l[k]
+			if !ok {
+				if !isZero(v) {
+					*paths = append(*paths, path+"."+k)
+				}
+				continue
+			}
+			diffFields(path+"."+k, v, lv, paths)
+		}
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok || len(l) != len(d) {
+			if ok || !isZero(desired) {
+				*paths = append(*paths, path)
+			}
+			return
+		}
+		for i := range d {
+			diffFields(fmt.Sprintf("%s[%d]", path, i), d[i], l[i], paths)
+		}
+	default:
+		if !reflect.DeepEqual(desired, live) && !(live == nil && isZero(desired)) {
+			*paths = append(*paths, path)
+		}
+	}
+}
+
+// isZero returns true for the JSON values the API server may drop, i.e.
+// empty objects and lists, empty strings, false and 0
+func isZero(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(t) == 0
+	case []interface{}:
+		return len(t) == 0
+	case string:
+		return t == ""
+	case bool:
+		return !t
+	case float64:
+		return t == 0
+	}
+	return false
+}
+
+// recordDrift records the fields of the live object that differ from the
+// rendered one, which the update about to be made reverts
+func recordDrift(n NFD, kind, name string, desired, live interface{}) error {
+	if n.drifts == nil {
+		return nil
+	}
+	paths, err := specDrift(desired, live)
+	if err != nil || len(paths) == 0 {
+		return err
+	}
+	if len(paths) > maxDriftPaths {
+		paths = append(paths[:maxDriftPaths], fmt.Sprintf("and %d more", len(paths)-maxDriftPaths))
+	}
+	n.drifts[kind+"/"+name] = paths
+	return nil
+}
+
+// Drifts returns the fields of the live objects, by "<kind>/<name>", that
+// differed from the rendered objects and were reverted since the last
+// call to Init
+func (n *NFD) Drifts() map[string][]string {
+	return n.drifts
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// volatileAnnotations are left out of the hashed manifests: the hash of
+// the certificates differs between clusters even with the same secret
+// names, and the template hash and changes depend on it or on the history
+// of the workload
+var volatileAnnotations = []string{tlsHashAnnotation, templateHashAnnotation, templateChangesAnnotation}
+
+// renderedManifests holds the objects rendered by the control functions
+// during a pass over the states, serialized without the fields set by the
+// cluster, by kind, namespace and name
+type renderedManifests map[string][]byte
+
+// recordManifest records the rendered object for the manifest hash
+func recordManifest(n NFD, kind string, obj client.Object) error {
+	if n.manifests == nil {
+		return nil
+	}
+
+	c := obj.DeepCopyObject().(client.Object)
+	c.SetOwnerReferences(nil)
+	c.SetResourceVersion("")
+	c.SetUID("")
+	c.SetManagedFields(nil)
+	c.SetAnnotations(withoutVolatileAnnotations(c.GetAnnotations()))
+	switch o := c.(type) {
+	case *appsv1.DaemonSet:
+		stripTemplate(&o.Spec.Template)
+	case *appsv1.Deployment:
+		stripTemplate(&o.Spec.Template)
+	}
+
+	// encoding/json sorts the map keys, so the serialization is stable
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	n.manifests[fmt.Sprintf("%s/%s/%s", kind, c.GetNamespace(), c.GetName())] = data
+	return nil
+}
+
+// stripTemplate removes the volatile annotations from a pod template
+func stripTemplate(template *corev1.PodTemplateSpec) {
+	template.Annotations = withoutVolatileAnnotations(template.Annotations)
+}
+
+// withoutVolatileAnnotations returns the annotations without the volatile
+// ones, or nil if none is left
+func withoutVolatileAnnotations(annotations map[string]string) map[string]string {
+	out := map[string]string{}
+	for k, v := range annotations {
+		out[k] = v
+	}
+	for _, k := range volatileAnnotations {
+		delete(out, k)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// ManifestHash returns the hash of the objects rendered since the last
+// call to Init, in a stable order. It only covers all the operand objects
+// once the last state has been applied, and is empty when the first states
+// were skipped by ResumeFrom.
+func (n *NFD) ManifestHash() string {
+	if n.manifests == nil {
+		return ""
+	}
+	keys := make([]string, 0, len(n.manifests))
+	for k := range n.manifests {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(hash, "%s\n%s\n", k, n.manifests[k])
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
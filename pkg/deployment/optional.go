@@ -0,0 +1,216 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"context"
+
+	secv1 "github.com/openshift/api/security/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// optionalStates are the states deploying an optional component, along
+// with a function returning true if the component is enabled. The
+// resources of the state of a disabled component are deleted instead of
+// being applied, so that turning a component off removes it.
+var optionalStates = map[string]func(spec *nfdv1.NodeFeatureDiscoverySpec) bool{
+	"topologyupdater": func(spec *nfdv1.NodeFeatureDiscoverySpec) bool {
+		return spec.TopologyUpdater.Enable
+	},
+	"gc": func(spec *nfdv1.NodeFeatureDiscoverySpec) bool {
+		return spec.GC.Enable
+	},
+	"nodefeatureapi": func(spec *nfdv1.NodeFeatureDiscoverySpec) bool {
+		return spec.EnableNodeFeatureAPI
+	},
+	"certmanager": func(spec *nfdv1.NodeFeatureDiscoverySpec) bool {
+		return spec.TLS.CertManager.Enable
+	},
+}
+
+// masterServiceNeeded returns true if an operand connects to nfd-master
+// over gRPC, through the nfd-master Service. With the NodeFeature API,
+// only nfd-topology-updater does.
+func masterServiceNeeded(spec *nfdv1.NodeFeatureDiscoverySpec) bool {
+	return !spec.EnableNodeFeatureAPI || spec.TopologyUpdater.Enable
+}
+
+// stateEnabled returns true if the given state is to be applied
+func stateEnabled(ins *nfdv1.NodeFeatureDiscovery, state string) bool {
+	enabled, ok := optionalStates[state]
+	return !ok || enabled(&ins.Spec)
+}
+
+// deleteState deletes the resources of the current state created for the
+// NFD instance, see deleteResources
+func deleteState(n NFD) error {
+	return deleteResources(n, n.resources[n.idx], "Component disabled, deleting")
+}
+
+// DeleteOperands deletes the resources of all the states created for the
+// NFD instance, the last state first, while leaving the
+// NodeFeatureDiscovery object in place. Init must have been called.
+func (n *NFD) DeleteOperands() error {
+	for i := len(n.resources) - 1; i >= 0; i-- {
+		if err := deleteResources(*n, n.resources[i], "Operands removed, deleting"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteResources deletes the resources of a state created for the NFD
+// instance. The namespaced resources are only deleted if controlled by the
+// instance and the cluster-scoped ones, which can't be, if they're
+// labelled as managed by the operator for the instance, see
+// deleteClusterScoped. The objects of other kinds, e.g. the CRDs of the
+// nodefeatureapi state, are left in place, as deleting a CRD deletes all
+// its objects.
+func deleteResources(n NFD, res Resources, reason string) error {
+	namespaced := []client.Object{
+		res.ServiceAccount.DeepCopy(),
+		res.Role.DeepCopy(),
+		res.RoleBinding.DeepCopy(),
+		res.ConfigMap.DeepCopy(),
+		res.DaemonSet.DeepCopy(),
+		res.Deployment.DeepCopy(),
+		res.Service.DeepCopy(),
+		res.PodDisruptionBudget.DeepCopy(),
+	}
+	for _, obj := range namespaced {
+		if obj.GetName() == "" {
+			continue
+		}
+		key := types.NamespacedName{Namespace: n.ins.GetNamespace(), Name: InstanceName(n.ins, obj.GetName())}
+		if err := deleteIf(n, key, obj, reason, func(found client.Object) bool {
+			return metav1.IsControlledBy(found, n.ins)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return deleteClusterScoped(n, res, reason)
+}
+
+// DeleteClusterScoped deletes the cluster-scoped resources of all the
+// states created for the NFD instance, which aren't garbage collected
+// along with the NodeFeatureDiscovery object as it can't own them. Init
+// must have been called.
+func (n *NFD) DeleteClusterScoped() error {
+	for _, res := range n.resources {
+		if err := deleteClusterScoped(*n, res, "NodeFeatureDiscovery deleted, deleting"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteClusterScoped deletes the cluster-scoped resources of a state, if
+// they're labelled as managed by the operator for the instance
+func deleteClusterScoped(n NFD, res Resources, reason string) error {
+	clusterScoped := []client.Object{
+		res.ClusterRole.DeepCopy(),
+		res.ClusterRoleBinding.DeepCopy(),
+	}
+	if n.platform.SecurityContextConstraints {
+		clusterScoped = append(clusterScoped, res.SecurityContextConstraints.DeepCopy())
+	}
+	for _, obj := range clusterScoped {
+		if obj.GetName() == "" {
+			continue
+		}
+		key := types.NamespacedName{Name: InstanceName(n.ins, obj.GetName())}
+		if err := deleteIf(n, key, obj, reason, func(found client.Object) bool {
+			return ClusterScopedOwner(found) == n.ins.GetName()
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClusterScopedOwner returns the name of the NodeFeatureDiscovery objects
+// a cluster-scoped resource is labelled as managed for, or "" if it isn't
+// managed by the operator. As the label doesn't tell the namespace, the
+// objects of that name in all namespaces are its owners.
+func ClusterScopedOwner(obj metav1.Object) string {
+	labels := obj.GetLabels()
+	if labels[managedByLabel] != managedByValue {
+		return ""
+	}
+	return labels[appInstLabel]
+}
+
+// deleteIf gets the object with the given key into obj and deletes it if
+// owned returns true for it, logging the reason
+func deleteIf(n NFD, key types.NamespacedName, obj client.Object, reason string, owned func(client.Object) bool) error {
+	err := n.client.Get(context.TODO(), key, obj)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !owned(obj) {
+		return nil
+	}
+
+	log.Info(reason, "Kind", kindOf(obj), "Name", key.Name, "Namespace", key.Namespace)
+	err = n.client.Delete(context.TODO(), obj)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// kindOf returns the kind of the resources deleted by deleteIf, for
+// logging purposes, as the typed objects don't carry it
+func kindOf(obj client.Object) string {
+	switch obj.(type) {
+	case *corev1.ServiceAccount:
+		return "ServiceAccount"
+	case *rbacv1.Role:
+		return "Role"
+	case *rbacv1.RoleBinding:
+		return "RoleBinding"
+	case *rbacv1.ClusterRole:
+		return "ClusterRole"
+	case *rbacv1.ClusterRoleBinding:
+		return "ClusterRoleBinding"
+	case *corev1.ConfigMap:
+		return "ConfigMap"
+	case *appsv1.DaemonSet:
+		return "DaemonSet"
+	case *appsv1.Deployment:
+		return "Deployment"
+	case *corev1.Service:
+		return "Service"
+	case *policyv1beta1.PodDisruptionBudget:
+		return "PodDisruptionBudget"
+	case *secv1.SecurityContextConstraints:
+		return "SecurityContextConstraints"
+	}
+	return ""
+}
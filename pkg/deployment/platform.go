@@ -0,0 +1,78 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	secv1 "github.com/openshift/api/security/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/discovery"
+)
+
+// Platform describes the platform specific APIs served by the cluster
+type Platform struct {
+	// SecurityContextConstraints is true if the OpenShift security API
+	// is served. The SecurityContextConstraints assets are skipped
+	// otherwise.
+	SecurityContextConstraints bool
+
+	// ClusterProxy is true if the OpenShift cluster-wide proxy config is
+	// served, so that the operands follow its changes
+	ClusterProxy bool
+
+	// ConsolePlugin is true if the OpenShift console serves dynamic
+	// plugins, so that the operator declares its own
+	ConsolePlugin bool
+}
+
+// DetectPlatform finds out, through the API discovery, which platform
+// specific APIs are served by the cluster
+func DetectPlatform(dc discovery.DiscoveryInterface) (Platform, error) {
+	p := Platform{}
+
+	var err error
+	p.SecurityContextConstraints, err = served(dc, secv1.SchemeGroupVersion.String(), "SecurityContextConstraints")
+	if err != nil {
+		return p, err
+	}
+	p.ClusterProxy, err = served(dc, ClusterProxyGVK.GroupVersion().String(), ClusterProxyGVK.Kind)
+	if err != nil {
+		return p, err
+	}
+	p.ConsolePlugin, err = served(dc, ConsolePluginGVK.GroupVersion().String(), ConsolePluginGVK.Kind)
+	if err != nil {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// served returns true if the kind is served by the cluster in the given
+// group version
+func served(dc discovery.DiscoveryInterface, groupVersion, kind string) (bool, error) {
+	resources, err := dc.ServerResourcesForGroupVersion(groupVersion)
+	if errors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	for _, r := range resources.APIResources {
+		if r.Kind == kind {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// ClusterProxyGVK is the OpenShift cluster-wide proxy config. It's read
+// through unstructured objects, as its API only exists on OpenShift. The
+// one in use is named ClusterProxyName.
+var ClusterProxyGVK = schema.GroupVersionKind{Group: "config.openshift.io", Version: "v1", Kind: "Proxy"}
+
+// ClusterProxyName is the name of the cluster-wide proxy config
+const ClusterProxyName = "cluster"
+
+// clusterProxy returns the proxy the operands of the NFD instance connect
+// through: the one given in the CR, or else the one of the OpenShift
+// cluster Proxy config. It returns nil if there is none, e.g. when not
+// running on OpenShift.
+func clusterProxy(c client.Client, ins *nfdv1.NodeFeatureDiscovery) (*nfdv1.ProxySpec, error) {
+	if ins.Spec.Operand.Proxy != nil {
+		return ins.Spec.Operand.Proxy, nil
+	}
+
+	proxy := &unstructured.Unstructured{}
+	proxy.SetGroupVersionKind(ClusterProxyGVK)
+	err := c.Get(context.TODO(), types.NamespacedName{Name: ClusterProxyName}, proxy)
+	if meta.IsNoMatchError(err) || errors.IsNotFound(err) || errors.IsForbidden(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// The status holds the effective config, once validated by the
+	// cluster network operator
+	spec := &nfdv1.ProxySpec{}
+	for field, value := range map[string]*string{
+		"httpProxy":  &spec.HTTPProxy,
+		"httpsProxy": &spec.HTTPSProxy,
+		"noProxy":    &spec.NoProxy,
+	} {
+		if *value, _, err = unstructured.NestedString(proxy.Object, "status", field); err != nil {
+			return nil, err
+		}
+	}
+	return spec, nil
+}
+
+// setProxy sets the proxy environment variables in all the containers of
+// the operand pods. The variables the proxy doesn't define are removed, so
+// that clearing the proxy takes effect.
+func setProxy(n NFD, template *corev1.PodTemplateSpec) error {
+	proxy, err := clusterProxy(n.client, n.ins)
+	if err != nil {
+		return err
+	}
+	if proxy == nil {
+		proxy = &nfdv1.ProxySpec{}
+	}
+
+	vars := []corev1.EnvVar{
+		{Name: "HTTP_PROXY", Value: proxy.HTTPProxy},
+		{Name: "HTTPS_PROXY", Value: proxy.HTTPSProxy},
+		{Name: "NO_PROXY", Value: proxy.NoProxy},
+	}
+	spec := &template.Spec
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			for _, v := range vars {
+				containers[i].Env = setEnv(containers[i].Env, v)
+			}
+		}
+	}
+	return nil
+}
+
+// setEnv sets the environment variable in the list, replacing any variable
+// of the same name, or removes it if its value is empty
+func setEnv(env []corev1.EnvVar, v corev1.EnvVar) []corev1.EnvVar {
+	out := env[:0]
+	for _, e := range env {
+		if e.Name != v.Name {
+			out = append(out, e)
+		}
+	}
+	if v.Value != "" {
+		out = append(out, v)
+	}
+	return out
+}
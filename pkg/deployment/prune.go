@@ -0,0 +1,138 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+const (
+	// pruneName is the name of the prune Job and of its ServiceAccount,
+	// ClusterRole and ClusterRoleBinding, suffixed with the instance
+	pruneName = "nfd-prune"
+
+	// pruneDeadline bounds the run time of the prune Job, so that a pod
+	// that can't start, e.g. because of a wrong image, doesn't hold up the
+	// deletion of the NodeFeatureDiscovery object
+	pruneDeadline int64 = 300
+)
+
+// PruneName returns the name of the prune Job of the NFD instance and of
+// its ServiceAccount, ClusterRole and ClusterRoleBinding
+func PruneName(ins *nfdv1.NodeFeatureDiscovery) string {
+	return InstanceName(ins, pruneName)
+}
+
+// PruneServiceAccount renders the ServiceAccount the prune Job runs as
+func PruneServiceAccount(ins *nfdv1.NodeFeatureDiscovery) *corev1.ServiceAccount {
+	obj := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: PruneName(ins), Namespace: ins.GetNamespace()},
+	}
+	setCommonLabels(ins, obj)
+	return obj
+}
+
+// PruneClusterRole renders the ClusterRole of the prune Job, which lists
+// the nodes and removes the NFD labels, annotations and extended
+// resources from them
+func PruneClusterRole(ins *nfdv1.NodeFeatureDiscovery) *rbacv1.ClusterRole {
+	obj := &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: PruneName(ins)},
+		Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get", "list", "patch", "update"}},
+			{APIGroups: []string{""}, Resources: []string{"nodes/status"}, Verbs: []string{"patch", "update"}},
+		},
+	}
+	setCommonLabels(ins, obj)
+	return obj
+}
+
+// PruneClusterRoleBinding renders the binding of the ClusterRole of the
+// prune Job to its ServiceAccount
+func PruneClusterRoleBinding(ins *nfdv1.NodeFeatureDiscovery) *rbacv1.ClusterRoleBinding {
+	obj := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: PruneName(ins)},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     PruneName(ins),
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      PruneName(ins),
+			Namespace: ins.GetNamespace(),
+		}},
+	}
+	setCommonLabels(ins, obj)
+	return obj
+}
+
+// PruneJob renders the Job running "nfd-master -prune", which removes the
+// labels, annotations and extended resources nfd-master of the instance
+// published from all the nodes. It must only run once nfd-master is gone,
+// as it would publish them again.
+func PruneJob(ins *nfdv1.NodeFeatureDiscovery) (*batchv1.Job, error) {
+	readOnly, escalation := true, false
+	backoffLimit, deadline := int32(2), pruneDeadline
+
+	args := []string{"-prune"}
+	if ins.Spec.Instance != "" {
+		args = append(args, "-instance="+ins.Spec.Instance)
+	}
+
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": pruneName}},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: PruneName(ins),
+			RestartPolicy:      corev1.RestartPolicyNever,
+			Containers: []corev1.Container{{
+				Name:    pruneName,
+				Image:   ins.Spec.Operand.ImagePath(),
+				Command: []string{"nfd-master"},
+				Args:    args,
+				SecurityContext: &corev1.SecurityContext{
+					ReadOnlyRootFilesystem:   &readOnly,
+					AllowPrivilegeEscalation: &escalation,
+					Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+				},
+			}},
+		},
+	}
+	if ins.Spec.Operand.ImagePullPolicy != "" {
+		template.Spec.Containers[0].ImagePullPolicy = ins.Spec.Operand.ImagePolicy(ins.Spec.Operand.ImagePullPolicy)
+	}
+	setPodMetadata(ins, &template)
+	if err := setSecurityContext(ins, "nfd-master", &template.Spec); err != nil {
+		return nil, err
+	}
+
+	obj := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: PruneName(ins), Namespace: ins.GetNamespace()},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &deadline,
+			Template:              template,
+		},
+	}
+	setCommonLabels(ins, obj)
+	return obj, nil
+}
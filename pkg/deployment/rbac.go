@@ -0,0 +1,159 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// featureRule is a permission that an operand component only needs when
+// a feature is enabled. The Roles and ClusterRoles of the assets hold the
+// permissions the components always need, and the feature rules are
+// added on top of them, so that each component gets the least privileges.
+type featureRule struct {
+	// role is the name of the Role or ClusterRole asset of the component
+	role string
+
+	// enabled returns true if the feature is enabled
+	enabled func(spec *nfdv1.NodeFeatureDiscoverySpec) bool
+
+	rule rbacv1.PolicyRule
+}
+
+var (
+	// clusterFeatureRules are added to the ClusterRoles of the components
+	clusterFeatureRules = []featureRule{
+		{
+			// Extended resources are advertised in the node status
+			role: "nfd-master",
+			enabled: func(spec *nfdv1.NodeFeatureDiscoverySpec) bool {
+				return len(spec.ResourceLabels) > 0
+			},
+			rule: rbacv1.PolicyRule{
+				APIGroups: []string{""},
+				Resources: []string{"nodes/status"},
+				Verbs:     []string{"patch", "update"},
+			},
+		},
+		{
+			// The taints are given by the NodeFeatureRules
+			role: "nfd-master",
+			enabled: func(spec *nfdv1.NodeFeatureDiscoverySpec) bool {
+				return spec.EnableTaints
+			},
+			rule: rbacv1.PolicyRule{
+				APIGroups: []string{"nfd.k8s-sigs.io"},
+				Resources: []string{"nodefeaturerules"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+		},
+		{
+			// nfd-master publishes the NodeResourceTopology objects
+			// reported by nfd-topology-updater
+			role: "nfd-master",
+			enabled: func(spec *nfdv1.NodeFeatureDiscoverySpec) bool {
+				return spec.TopologyUpdater.Enable
+			},
+			rule: rbacv1.PolicyRule{
+				APIGroups: []string{"topology.node.k8s.io"},
+				Resources: []string{"noderesourcetopologies"},
+				Verbs:     []string{"get", "create", "update"},
+			},
+		},
+		{
+			// nfd-master reads the features published by nfd-worker
+			// in the NodeFeature objects, and applies the rules of
+			// the NodeFeatureRules to them
+			role: "nfd-master",
+			enabled: func(spec *nfdv1.NodeFeatureDiscoverySpec) bool {
+				return spec.EnableNodeFeatureAPI
+			},
+			rule: rbacv1.PolicyRule{
+				APIGroups: []string{"nfd.k8s-sigs.io"},
+				Resources: []string{"nodefeatures", "nodefeaturerules"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+		},
+	}
+
+	// namespacedFeatureRules are added to the Roles of the components
+	namespacedFeatureRules = []featureRule{
+		{
+			// The leader election is done with a Lease
+			role: "nfd-master",
+			enabled: func(spec *nfdv1.NodeFeatureDiscoverySpec) bool {
+				return spec.Master.EnableLeaderElection
+			},
+			rule: rbacv1.PolicyRule{
+				APIGroups: []string{"coordination.k8s.io"},
+				Resources: []string{"leases"},
+				Verbs:     []string{"get", "create", "update"},
+			},
+		},
+		{
+			// nfd-worker publishes its features in the NodeFeature
+			// object of its node, in the NFD namespace
+			role: "nfd-worker",
+			enabled: func(spec *nfdv1.NodeFeatureDiscoverySpec) bool {
+				return spec.EnableNodeFeatureAPI
+			},
+			rule: rbacv1.PolicyRule{
+				APIGroups: []string{"nfd.k8s-sigs.io"},
+				Resources: []string{"nodefeatures"},
+				Verbs:     []string{"get", "create", "update"},
+			},
+		},
+		{
+			// nfd-worker gets its own pod to make it the owner of the
+			// NodeFeature object
+			role: "nfd-worker",
+			enabled: func(spec *nfdv1.NodeFeatureDiscoverySpec) bool {
+				return spec.EnableNodeFeatureAPI
+			},
+			rule: rbacv1.PolicyRule{
+				APIGroups: []string{""},
+				Resources: []string{"pods"},
+				Verbs:     []string{"get"},
+			},
+		},
+	}
+)
+
+// featureClusterRules returns the rules to add to the given ClusterRole
+// asset for the features enabled on the NFD instance
+func featureClusterRules(ins *nfdv1.NodeFeatureDiscovery, role string) []rbacv1.PolicyRule {
+	return enabledRules(clusterFeatureRules, ins, role)
+}
+
+// featureRules returns the rules to add to the given Role asset for the
+// features enabled on the NFD instance
+func featureRules(ins *nfdv1.NodeFeatureDiscovery, role string) []rbacv1.PolicyRule {
+	return enabledRules(namespacedFeatureRules, ins, role)
+}
+
+// enabledRules returns the rules of the given role whose feature is enabled
+func enabledRules(rules []featureRule, ins *nfdv1.NodeFeatureDiscovery, role string) []rbacv1.PolicyRule {
+	enabled := []rbacv1.PolicyRule{}
+	for _, r := range rules {
+		if r.role == role && r.enabled(&ins.Spec) {
+			enabled = append(enabled, *r.rule.DeepCopy())
+		}
+	}
+	return enabled
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// readinessAnnotation declares, on an asset manifest of an arbitrary kind,
+// when the object is considered ready. The supported criteria are "exists"
+// (the default), "fields-match:<path>=<value>[,...]" where the fields at the
+// given dot separated paths must have the given values, e.g.
+// "fields-match:status.phase=Active", and "jsonpath:<expression>=<value>"
+// where the JSONPath expression must evaluate to the given value, e.g.
+// "jsonpath:{.status.conditions[?(@.type=="Ready")].status}=True".
+const readinessAnnotation = "nfd.kubernetes.io/readiness"
+
+// isReady evaluates the readiness criterion declared on the desired object
+// against the live object
+func isReady(desired, live *unstructured.Unstructured) (bool, error) {
+	criterion := desired.GetAnnotations()[readinessAnnotation]
+	if criterion == "" || criterion == "exists" {
+		return true, nil
+	}
+
+	kind, arg := criterion, ""
+	if i := strings.Index(criterion, ":"); i >= 0 {
+		kind, arg = criterion[:i], criterion[i+1:]
+	}
+
+	switch kind {
+	case "fields-match":
+		return fieldsMatch(live, arg)
+	case "jsonpath":
+		return jsonPathMatches(live, arg)
+	}
+	return false, fmt.Errorf("unknown readiness criterion %q in %s annotation", criterion, readinessAnnotation)
+}
+
+// fieldsMatch checks a comma separated list of "<path>=<value>" pairs
+func fieldsMatch(obj *unstructured.Unstructured, arg string) (bool, error) {
+	for _, pair := range strings.Split(arg, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return false, fmt.Errorf("invalid fields-match criterion %q", pair)
+		}
+
+		value, found, err := unstructured.NestedFieldNoCopy(obj.Object, strings.Split(strings.TrimSpace(kv[0]), ".")...)
+		if err != nil {
+			return false, err
+		}
+		if !found || fmt.Sprint(value) != strings.TrimSpace(kv[1]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// jsonPathMatches checks a "<expression>=<value>" pair. The value is split
+// at the last '=' since the expression itself may contain some.
+func jsonPathMatches(obj *unstructured.Unstructured, arg string) (bool, error) {
+	i := strings.LastIndex(arg, "=")
+	if i < 0 {
+		return false, fmt.Errorf("invalid jsonpath criterion %q", arg)
+	}
+	expr, want := arg[:i], arg[i+1:]
+
+	jp := jsonpath.New(readinessAnnotation).AllowMissingKeys(true)
+	if err := jp.Parse(expr); err != nil {
+		return false, err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := jp.Execute(buf, obj.Object); err != nil {
+		return false, err
+	}
+	return buf.String() == want, nil
+}
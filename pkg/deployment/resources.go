@@ -0,0 +1,185 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	secv1 "github.com/openshift/api/security/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/kubectl/pkg/scheme"
+)
+
+// Resources holds objects owned by NFD
+type Resources struct {
+	Namespace                  corev1.Namespace
+	ServiceAccount             corev1.ServiceAccount
+	Role                       rbacv1.Role
+	RoleBinding                rbacv1.RoleBinding
+	ClusterRole                rbacv1.ClusterRole
+	ClusterRoleBinding         rbacv1.ClusterRoleBinding
+	ConfigMap                  corev1.ConfigMap
+	DaemonSet                  appsv1.DaemonSet
+	Deployment                 appsv1.Deployment
+	Pod                        corev1.Pod
+	Service                    corev1.Service
+	PodDisruptionBudget        policyv1beta1.PodDisruptionBudget
+	SecurityContextConstraints secv1.SecurityContextConstraints
+	ConsoleYAMLSample          unstructured.Unstructured
+
+	// Unstructured holds the objects of any other kind. They are
+	// applied generically and their readiness is declared with the
+	// readinessAnnotation.
+	Unstructured []unstructured.Unstructured
+}
+
+// Add3dpartyResourcesToScheme Adds 3rd party resources To the operator
+func Add3dpartyResourcesToScheme(scheme *runtime.Scheme) error {
+
+	if err := secv1.AddToScheme(scheme); err != nil {
+		return err
+	}
+	return nil
+}
+
+// The assets are decoded with the kubectl scheme, which needs the platform
+// specific types too
+func init() {
+	utilruntime.Must(Add3dpartyResourcesToScheme(scheme.Scheme))
+}
+
+// addResourcesControls decodes the manifests of a state and returns the
+// resources along with their control functions. The manifests of the
+// platform specific kinds the cluster doesn't serve are skipped, and so
+// are the manifests that can't be decoded, whose errors are returned
+// along with the other resources.
+func addResourcesControls(manifests [][]byte, p Platform) (Resources, controlFunc, error) {
+
+	// Information about the manifest
+	res := Resources{}
+
+	// A list of control functions for checking the status of a resource
+	ctrl := controlFunc{}
+
+	// s and reg are used later on to parse the manifest YAML
+	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme,
+		scheme.Scheme)
+	reg, _ := regexp.Compile(`\b(\w*kind:\w*)\B.*\b`)
+
+	// decode decodes a manifest into the given object, recording the
+	// error, if any, so that a malformed manifest doesn't keep the other
+	// ones from being applied
+	errs := []error{}
+	decode := func(i int, kind string, m []byte, into runtime.Object) bool {
+		if _, _, err := s.Decode(m, nil, into); err != nil {
+			errs = append(errs, fmt.Errorf("manifest %d (%s): %w", i+1, kind, err))
+			return false
+		}
+		return true
+	}
+
+	// Append the appropriate control function depending on the kind
+	for i, m := range manifests {
+		slce := strings.Split(reg.FindString(string(m)), ":")
+		if len(slce) < 2 || strings.TrimSpace(slce[1]) == "" {
+			errs = append(errs, fmt.Errorf("manifest %d: no kind found", i+1))
+			continue
+		}
+		kind := strings.TrimSpace(slce[1])
+
+		switch kind {
+		case "Namespace":
+			if decode(i, kind, m, &res.Namespace) {
+				ctrl = append(ctrl, Namespace)
+			}
+		case "ServiceAccount":
+			if decode(i, kind, m, &res.ServiceAccount) {
+				ctrl = append(ctrl, ServiceAccount)
+			}
+		case "ClusterRole":
+			if decode(i, kind, m, &res.ClusterRole) {
+				ctrl = append(ctrl, ClusterRole)
+			}
+		case "ClusterRoleBinding":
+			if decode(i, kind, m, &res.ClusterRoleBinding) {
+				ctrl = append(ctrl, ClusterRoleBinding)
+			}
+		case "Role":
+			if decode(i, kind, m, &res.Role) {
+				ctrl = append(ctrl, Role)
+			}
+		case "RoleBinding":
+			if decode(i, kind, m, &res.RoleBinding) {
+				ctrl = append(ctrl, RoleBinding)
+			}
+		case "ConfigMap":
+			if decode(i, kind, m, &res.ConfigMap) {
+				ctrl = append(ctrl, ConfigMap)
+			}
+		case "DaemonSet":
+			if decode(i, kind, m, &res.DaemonSet) {
+				ctrl = append(ctrl, DaemonSet)
+			}
+		case "Deployment":
+			if decode(i, kind, m, &res.Deployment) {
+				ctrl = append(ctrl, Deployment)
+			}
+		case "Service":
+			if decode(i, kind, m, &res.Service) {
+				ctrl = append(ctrl, Service)
+			}
+		case "PodDisruptionBudget":
+			if decode(i, kind, m, &res.PodDisruptionBudget) {
+				ctrl = append(ctrl, PodDisruptionBudget)
+			}
+		case "SecurityContextConstraints":
+			if !p.SecurityContextConstraints {
+				log.Info("SecurityContextConstraints API not available, skipping the asset")
+				continue
+			}
+			if decode(i, kind, m, &res.SecurityContextConstraints) {
+				ctrl = append(ctrl, SecurityContextConstraints)
+			}
+		case "ConsoleYAMLSample":
+			if decode(i, kind, m, &res.ConsoleYAMLSample) {
+				ctrl = append(ctrl, ConsoleYAMLSample)
+			}
+
+		default:
+			obj := unstructured.Unstructured{}
+			if !decode(i, kind, m, &obj) {
+				continue
+			}
+			log.Info("Generic Resource: ", "Kind", kind, "Name", obj.GetName())
+			res.Unstructured = append(res.Unstructured, obj)
+			ctrl = append(ctrl, Unstructured(len(res.Unstructured)-1))
+		}
+
+	}
+
+	return res, ctrl, utilerrors.NewAggregate(errs)
+}
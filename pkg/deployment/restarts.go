@@ -0,0 +1,164 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+const (
+	// templateHashAnnotation holds the hash of the pod template rendered
+	// by the operator on the operand workloads. The live template can't
+	// be compared with the rendered one, as it holds the defaults set by
+	// the API server.
+	templateHashAnnotation = "nfd.kubernetes.io/template-hash"
+
+	// templateChangesAnnotation holds the times, as Unix timestamps, of
+	// the recent changes of the pod template of an operand workload
+	templateChangesAnnotation = "nfd.kubernetes.io/template-changes"
+
+	// templateChangeWindow is the window the pod template changes are
+	// counted over
+	templateChangeWindow = time.Hour
+
+	// defaultMaxTemplateChanges is the number of pod template changes
+	// allowed within the window when not set in the CR
+	defaultMaxTemplateChanges = int(nfdv1.DefaultMaxTemplateChangesPerHour)
+
+	// conditionRolloutThrottled is true when the update of an operand
+	// workload is held back because its pod template changed too often
+	conditionRolloutThrottled conditionsv1.ConditionType = "RolloutThrottled"
+
+	reasonTemplateChangeRateExceeded = "TemplateChangeRateExceeded"
+	reasonTemplateChangeRateNormal   = "TemplateChangeRateNormal"
+)
+
+// setTemplateHash records the hash of the rendered pod template on an
+// operand workload. encoding/json sorts the map keys, so the hash only
+// changes with the content of the template.
+func setTemplateHash(obj metav1.Object, template *corev1.PodTemplateSpec) error {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+
+	annotations := map[string]string{}
+	for k, v := range obj.GetAnnotations() {
+		annotations[k] = v
+	}
+	annotations[templateHashAnnotation] = fmt.Sprintf("%x", sha256.Sum256(data))
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// guardTemplateChange is called before updating an existing operand
+// workload, found, with the rendered one, obj, whose template hash has
+// been set. It counts the changes of the pod template over the last
+// window, as each of them restarts the pods, and returns false once more
+// changes than allowed by the CR were made, so that a flapping template
+// doesn't keep restarting the pods. The update goes through again once
+// the older changes are out of the window.
+func guardTemplateChange(n NFD, kind string, found, obj metav1.Object) (bool, error) {
+	now := time.Now()
+	changes := recentTemplateChanges(found, now)
+
+	hash := obj.GetAnnotations()[templateHashAnnotation]
+	previous, ok := found.GetAnnotations()[templateHashAnnotation]
+	changed := ok && previous != hash
+
+	max := defaultMaxTemplateChanges
+	if m := n.ins.Spec.Upgrade.MaxTemplateChangesPerHour; m != nil {
+		max = int(*m)
+	}
+	throttled := changed && len(changes) >= max
+
+	// Only the workload named in the condition clears it, as others
+	// may be rolling out fine at the same time
+	who := fmt.Sprintf("%s %s", kind, obj.GetName())
+	if throttled {
+		msg := fmt.Sprintf("%s: pod template changed %d times within %s, rollout paused", who, len(changes), templateChangeWindow)
+		return false, setRolloutThrottled(n, corev1.ConditionTrue, reasonTemplateChangeRateExceeded, msg)
+	}
+	cond := conditionsv1.FindStatusCondition(n.ins.Status.Conditions, conditionRolloutThrottled)
+	if cond != nil && cond.Status == corev1.ConditionTrue && strings.HasPrefix(cond.Message, who+":") {
+		if err := setRolloutThrottled(n, corev1.ConditionFalse, reasonTemplateChangeRateNormal, ""); err != nil {
+			return false, err
+		}
+	}
+
+	if changed {
+		changes = append(changes, now.Unix())
+	}
+	stamps := make([]string, 0, len(changes))
+	for _, c := range changes {
+		stamps = append(stamps, strconv.FormatInt(c, 10))
+	}
+	annotations := obj.GetAnnotations()
+	if len(stamps) > 0 {
+		annotations[templateChangesAnnotation] = strings.Join(stamps, ",")
+	} else {
+		delete(annotations, templateChangesAnnotation)
+	}
+	obj.SetAnnotations(annotations)
+	return true, nil
+}
+
+// recentTemplateChanges returns the pod template changes of a workload
+// within the window ending now
+func recentTemplateChanges(obj metav1.Object, now time.Time) []int64 {
+	changes := []int64{}
+	for _, s := range strings.Split(obj.GetAnnotations()[templateChangesAnnotation], ",") {
+		t, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			continue
+		}
+		if now.Sub(time.Unix(t, 0)) < templateChangeWindow {
+			changes = append(changes, t)
+		}
+	}
+	return changes
+}
+
+// setRolloutThrottled sets the RolloutThrottled condition, unless it's
+// already up to date
+func setRolloutThrottled(n NFD, status corev1.ConditionStatus, reason, message string) error {
+	cond := conditionsv1.FindStatusCondition(n.ins.Status.Conditions, conditionRolloutThrottled)
+	if cond != nil && cond.Status == status && cond.Reason == reason && cond.Message == message {
+		return nil
+	}
+
+	log.Info("RolloutThrottled", "status", status, "reason", reason, "message", message)
+	conditionsv1.SetStatusCondition(&n.ins.Status.Conditions, conditionsv1.Condition{
+		Type:    conditionRolloutThrottled,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+	return n.client.Status().Update(context.TODO(), n.ins)
+}
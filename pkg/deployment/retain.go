@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// OrphanOperands removes the owner reference to the NFD instance from the
+// namespaced resources of all the states, so that the garbage collector
+// leaves them in place once the NodeFeatureDiscovery object is gone. The
+// resources controlled by another object are left untouched. Init must
+// have been called.
+func (n *NFD) OrphanOperands() error {
+	for _, res := range n.resources {
+		namespaced := []client.Object{
+			res.ServiceAccount.DeepCopy(),
+			res.Role.DeepCopy(),
+			res.RoleBinding.DeepCopy(),
+			res.ConfigMap.DeepCopy(),
+			res.DaemonSet.DeepCopy(),
+			res.Deployment.DeepCopy(),
+			res.Service.DeepCopy(),
+			res.PodDisruptionBudget.DeepCopy(),
+		}
+		for _, obj := range namespaced {
+			if obj.GetName() == "" {
+				continue
+			}
+			if err := orphan(*n, InstanceName(n.ins, obj.GetName()), obj); err != nil {
+				return err
+			}
+		}
+
+		for i := range res.Unstructured {
+			obj := res.Unstructured[i].DeepCopy()
+			mapping, err := n.client.RESTMapper().RESTMapping(obj.GroupVersionKind().GroupKind(), obj.GroupVersionKind().Version)
+			if meta.IsNoMatchError(err) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+				continue
+			}
+
+			// The cert-manager objects are renamed for the instance, see
+			// setCertManagerResource
+			name := obj.GetName()
+			if obj.GroupVersionKind().Group == certManagerGroup {
+				name = InstanceName(n.ins, name)
+			}
+			if err := orphan(*n, name, obj); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// orphan gets the named object of the NFD namespace into obj and, if it's
+// controlled by the NFD instance, removes its owner reference
+func orphan(n NFD, name string, obj client.Object) error {
+	key := types.NamespacedName{Namespace: n.ins.GetNamespace(), Name: name}
+	err := n.client.Get(context.TODO(), key, obj)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(obj, n.ins) {
+		return nil
+	}
+
+	kind := kindOf(obj)
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		kind = u.GetKind()
+	}
+	log.Info("NodeFeatureDiscovery deleted, orphaning", "Kind", kind, "Name", key.Name, "Namespace", key.Namespace)
+	return RemoveOwnerReference(context.TODO(), n.client, n.ins, obj)
+}
+
+// RemoveOwnerReference removes the owner reference to the NFD instance
+// from obj with a merge patch, which fails if obj changed since it was
+// read
+func RemoveOwnerReference(ctx context.Context, c client.Client, ins *nfdv1.NodeFeatureDiscovery, obj client.Object) error {
+	patch := client.MergeFromWithOptions(obj.DeepCopyObject().(client.Object), client.MergeFromWithOptimisticLock{})
+	refs := []metav1.OwnerReference{}
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID != ins.GetUID() {
+			refs = append(refs, ref)
+		}
+	}
+	obj.SetOwnerReferences(refs)
+	err := c.Patch(ctx, obj, patch)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// securityContexts returns the pod and container security contexts given
+// in the CR for the given operand
+func securityContexts(ins *nfdv1.NodeFeatureDiscovery, name string) (*corev1.PodSecurityContext, *corev1.SecurityContext) {
+	switch name {
+	case "nfd-master":
+		return ins.Spec.Master.PodSecurityContext, ins.Spec.Master.SecurityContext
+	case "nfd-worker":
+		return ins.Spec.Worker.PodSecurityContext, ins.Spec.Worker.SecurityContext
+	case "nfd-topology-updater":
+		return ins.Spec.TopologyUpdater.PodSecurityContext, ins.Spec.TopologyUpdater.SecurityContext
+	case "nfd-gc":
+		return ins.Spec.GC.PodSecurityContext, ins.Spec.GC.SecurityContext
+	}
+	return nil, nil
+}
+
+// setSecurityContext merges the security contexts given in the CR for the
+// given operand over the ones of its asset
+func setSecurityContext(ins *nfdv1.NodeFeatureDiscovery, name string, spec *corev1.PodSpec) error {
+	pod, container := securityContexts(ins, name)
+	if pod != nil {
+		merged := &corev1.PodSecurityContext{}
+		if err := mergeOver(spec.SecurityContext, pod, merged); err != nil {
+			return err
+		}
+		spec.SecurityContext = merged
+	}
+	if container != nil {
+		merged := &corev1.SecurityContext{}
+		if err := mergeOver(spec.Containers[0].SecurityContext, container, merged); err != nil {
+			return err
+		}
+		spec.Containers[0].SecurityContext = merged
+	}
+	return nil
+}
+
+// mergeOver decodes into out the JSON encoding of base with the fields set
+// in override replacing its ones. Nested objects, e.g. the capabilities,
+// are merged the same way, whereas lists are replaced.
+func mergeOver(base, override, out interface{}) error {
+	var b, o map[string]interface{}
+	for _, v := range []struct {
+		in  interface{}
+		out *map[string]interface{}
+	}{{base, &b}, {override, &o}} {
+		data, err := json.Marshal(v.in)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, v.out); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(overlay(b, o))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// overlay sets the fields of override in base, recursively for the
+// objects they both have
+func overlay(base, override map[string]interface{}) map[string]interface{} {
+	if base == nil {
+		base = map[string]interface{}{}
+	}
+	for k, v := range override {
+		if vm, ok := v.(map[string]interface{}); ok {
+			if bm, ok := base[k].(map[string]interface{}); ok {
+				base[k] = overlay(bm, vm)
+				continue
+			}
+		}
+		base[k] = v
+	}
+	return base
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestMergeOver(t *testing.T) {
+	yes, no := true, false
+	base := &corev1.SecurityContext{
+		Privileged:   &no,
+		RunAsNonRoot: &yes,
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+	override := &corev1.SecurityContext{
+		Privileged: &yes,
+		Capabilities: &corev1.Capabilities{
+			Add: []corev1.Capability{"SYS_ADMIN"},
+		},
+	}
+
+	got := &corev1.SecurityContext{}
+	if err := mergeOver(base, override, got); err != nil {
+		t.Fatalf("mergeOver failed: %v", err)
+	}
+	want := &corev1.SecurityContext{
+		Privileged:   &yes,
+		RunAsNonRoot: &yes,
+		Capabilities: &corev1.Capabilities{
+			Add:  []corev1.Capability{"SYS_ADMIN"},
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Lists are replaced rather than merged
+	override = &corev1.SecurityContext{Capabilities: &corev1.Capabilities{Drop: []corev1.Capability{"NET_RAW"}}}
+	got = &corev1.SecurityContext{}
+	if err := mergeOver(base, override, got); err != nil {
+		t.Fatalf("mergeOver failed: %v", err)
+	}
+	if !reflect.DeepEqual(got.Capabilities.Drop, []corev1.Capability{"NET_RAW"}) {
+		t.Errorf("got dropped capabilities %v, want [NET_RAW]", got.Capabilities.Drop)
+	}
+}
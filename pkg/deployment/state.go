@@ -0,0 +1,308 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// log is used to set the logger with a name that describes the actions of
+// functions and arguments in this package
+var log = logf.Log.WithName("controller_nodefeaturediscovery")
+
+// ErrResourceNotReady is returned by Step when a resource of the state
+// isn't ready yet, so that the rollout is resumed on a later reconcile
+var ErrResourceNotReady = errors.New("ResourceNotReady")
+
+// NotReadyError tells which resource of a state isn't ready yet, and why.
+// It matches ErrResourceNotReady with errors.Is.
+type NotReadyError struct {
+	// Kind is the kind of the resource
+	Kind string
+
+	// Name is the name of the resource
+	Name string
+
+	// Reason tells why the resource isn't ready, e.g. "3/120 pods
+	// available"
+	Reason string
+}
+
+// Error implements the error interface
+func (e *NotReadyError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Kind, e.Name, e.Reason)
+}
+
+// Is makes errors.Is match ErrResourceNotReady
+func (e *NotReadyError) Is(target error) bool {
+	return target == ErrResourceNotReady
+}
+
+// AssetsError is returned by Init when the assets can't be read from the
+// provider, e.g. because of an unreadable file. Nothing is cached then, so
+// that the next call to Init reads them again.
+type AssetsError struct {
+	Err error
+}
+
+// Error implements the error interface
+func (e *AssetsError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error of the provider
+func (e *AssetsError) Unwrap() error {
+	return e.Err
+}
+
+// notReady returns the NotReadyError of a resource
+func notReady(kind, name, format string, a ...interface{}) error {
+	return &NotReadyError{Kind: kind, Name: name, Reason: fmt.Sprintf(format, a...)}
+}
+
+// NFD holds the operand resources of a NodeFeatureDiscovery object and
+// applies them, one state (i.e. assets directory) at a time.
+type NFD struct {
+
+	// resources contains information about NFD's resources.
+	resources []Resources
+
+	// controls contains a list of functions for determining if a NFD resource is ready
+	controls []controlFunc
+
+	// states contains the names of the states, as given by the assets
+	// provider
+	states []string
+
+	// decodeErrors holds the errors of the manifests that couldn't be
+	// decoded, and are left out of their state
+	decodeErrors []error
+
+	// client is used to apply the resources and read their status
+	client client.Client
+
+	// scheme is used to set the owner references of the resources
+	scheme *runtime.Scheme
+
+	// platform tells which platform specific resources can be applied
+	platform Platform
+
+	// ins is the NodeFeatureDiscovery struct that contains the Schema
+	// for the nodefeaturediscoveries API
+	ins *nfdv1.NodeFeatureDiscovery
+
+	// idx is the index that is used to step through the 'controls' list
+	// and is set to 0 upon calling 'Init()'
+	idx int
+
+	// manifests holds the objects rendered since the call to Init, for
+	// the manifest hash. It's nil when the states aren't all applied.
+	manifests renderedManifests
+
+	// components holds the outcome of the operand states applied since
+	// the call to Init
+	components map[string]*ComponentResult
+
+	// rollouts holds the status of the nfd-master and nfd-worker
+	// workloads read since the call to Init
+	rollouts *Rollouts
+
+	// drifts holds the manual changes to the live objects reverted since
+	// the call to Init
+	drifts driftReports
+
+	// adoptions holds the workloads deployed outside of the operator
+	// adopted since the call to Init
+	adoptions map[string]string
+
+	// changes holds the objects created or updated since the call to
+	// Init, by state
+	changes map[string][]string
+
+	// labelNsIsolated denies nfd-master the label namespaces not listed
+	// in the CR, as its namespace has a label namespace policy
+	labelNsIsolated bool
+}
+
+// addState decodes the manifests of a state and adds the resources and
+// their control functions to the NFD instance. The manifests that can't
+// be decoded are skipped and their errors recorded.
+func (n *NFD) addState(name string, manifests [][]byte) {
+	res, ctrl, err := addResourcesControls(manifests, n.platform)
+	if err != nil {
+		log.Error(err, "Couldn't decode the assets", "state", name)
+		n.decodeErrors = append(n.decodeErrors, fmt.Errorf("state %q: %w", name, err))
+	}
+	n.controls = append(n.controls, ctrl)
+	n.resources = append(n.resources, res)
+	n.states = append(n.states, name)
+}
+
+// Init initializes an NFD object by populating the fields before
+// attempting to run any kind of check. The assets are only read from the
+// provider on the first call, and must have at least one state.
+func (n *NFD) Init(
+	c client.Client,
+	s *runtime.Scheme,
+	assets AssetsProvider,
+	p Platform,
+	i *nfdv1.NodeFeatureDiscovery,
+) error {
+	n.client = c
+	n.scheme = s
+	n.platform = p
+	n.ins = i
+	n.idx = 0
+	n.manifests = renderedManifests{}
+	n.components = nil
+	n.rollouts = &Rollouts{}
+	n.drifts = driftReports{}
+	n.adoptions = map[string]string{}
+	n.changes = map[string][]string{}
+	n.labelNsIsolated = false
+	if len(n.controls) > 0 {
+		return nil
+	}
+
+	states, err := assets.States(context.TODO())
+	if err != nil {
+		return &AssetsError{Err: fmt.Errorf("could not list the asset states: %w", err)}
+	}
+	if len(states) == 0 {
+		return &AssetsError{Err: fmt.Errorf("no asset states found")}
+	}
+	for _, state := range states {
+		manifests, err := assets.Assets(context.TODO(), state)
+		if err != nil {
+			n.Reset()
+			return &AssetsError{Err: fmt.Errorf("could not read the assets of state %q: %w", state, err)}
+		}
+		n.addState(state, manifests)
+	}
+	return nil
+}
+
+// IsolateLabelNs makes nfd-master deny all the label namespaces but the
+// default ones and spec.extraLabelNs, which the label namespace policy of
+// the namespace of the CR was checked against. It must be called after
+// Init.
+func (n *NFD) IsolateLabelNs() {
+	n.labelNsIsolated = true
+}
+
+// Loaded returns true if the assets were read from the provider, in
+// which case Init doesn't read them again
+func (n *NFD) Loaded() bool {
+	return len(n.controls) > 0
+}
+
+// States returns the names of the states, in the order they're applied
+func (n *NFD) States() []string {
+	return n.states
+}
+
+// Reset drops the assets so that they're read again on the next call to
+// Init.
+func (n *NFD) Reset() {
+	n.resources = nil
+	n.controls = nil
+	n.states = nil
+	n.decodeErrors = nil
+}
+
+// DecodeError returns the errors of the manifests that couldn't be
+// decoded, if any. They aren't applied, but the other manifests are.
+func (n *NFD) DecodeError() error {
+	return utilerrors.NewAggregate(n.decodeErrors)
+}
+
+// State returns the name of the state the next call to Step applies
+func (n *NFD) State() string {
+	if n.idx >= len(n.states) {
+		return ""
+	}
+	return n.states[n.idx]
+}
+
+// ResumeFrom makes the next call to Step apply the given state, skipping
+// the previous ones. It returns false, and doesn't skip any state, if the
+// state is unknown.
+func (n *NFD) ResumeFrom(state string) bool {
+	for i, s := range n.states {
+		if s == state {
+			n.idx = i
+			if i > 0 {
+				n.manifests = nil
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Step performs one step of the resource reconciliation loop, iterating over
+// one set of resource control functions n order to determine if the related
+// resources are ready. It does nothing once the last state is applied.
+func (n *NFD) Step() error {
+	if n.idx >= len(n.controls) {
+		return nil
+	}
+
+	// The resources of a disabled component are removed rather than
+	// applied
+	if !stateEnabled(n.ins, n.states[n.idx]) {
+		if err := deleteState(*n); err != nil {
+			return err
+		}
+		n.setComponentResult(nil)
+		n.idx = n.idx + 1
+		return nil
+	}
+
+	// The control functions tell which resource isn't ready with a
+	// NotReadyError, which is returned as is
+	for _, fs := range n.controls[n.idx] {
+		stat, err := fs(*n)
+		if err != nil {
+			n.setComponentResult(&ComponentResult{Message: err.Error()})
+			return err
+		}
+		if stat != Ready {
+			n.setComponentResult(&ComponentResult{Message: "waiting for the resources to be ready"})
+			return ErrResourceNotReady
+		}
+	}
+	n.setComponentResult(&ComponentResult{Ready: true})
+
+	// Increment the index to handle the next set of control functions
+	n.idx = n.idx + 1
+	return nil
+}
+
+// Last checks if all control functions have been processed.
+func (n *NFD) Last() bool {
+	return n.idx == len(n.controls)
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2020-2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+const serviceAccountManifest = `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: nfd-worker
+`
+
+// failingAssets is an AssetsProvider failing to read the assets of a state
+type failingAssets struct {
+	MemoryAssets
+	fail string
+}
+
+func (a *failingAssets) Assets(ctx context.Context, state string) ([][]byte, error) {
+	if state == a.fail {
+		return nil, errors.New("unreadable")
+	}
+	return a.MemoryAssets.Assets(ctx, state)
+}
+
+func TestInitMemoryAssets(t *testing.T) {
+	assets := &MemoryAssets{}
+	assets.Add("worker", []byte(serviceAccountManifest))
+	assets.Add("master", []byte(serviceAccountManifest))
+
+	n := NFD{}
+	if err := n.Init(nil, nil, assets, Platform{}, &nfdv1.NodeFeatureDiscovery{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if !n.Loaded() {
+		t.Fatal("assets not loaded")
+	}
+	if got := n.States(); len(got) != 2 || got[0] != "worker" || got[1] != "master" {
+		t.Errorf("unexpected states %v", got)
+	}
+	if err := n.DecodeError(); err != nil {
+		t.Errorf("unexpected decode error: %v", err)
+	}
+
+	// The assets are only read once
+	assets.Add("gc", []byte(serviceAccountManifest))
+	if err := n.Init(nil, nil, assets, Platform{}, &nfdv1.NodeFeatureDiscovery{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if got := n.States(); len(got) != 2 {
+		t.Errorf("assets read again, got states %v", got)
+	}
+}
+
+func TestInitDecodeError(t *testing.T) {
+	assets := &MemoryAssets{}
+	assets.Add("worker", []byte(serviceAccountManifest), []byte("kind: ServiceAccount\nmetadata: [\n"))
+
+	n := NFD{}
+	if err := n.Init(nil, nil, assets, Platform{}, &nfdv1.NodeFeatureDiscovery{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if n.DecodeError() == nil {
+		t.Error("expected a decode error")
+	}
+	if len(n.controls[0]) != 1 {
+		t.Errorf("expected the valid manifest to be kept, got %d controls", len(n.controls[0]))
+	}
+}
+
+func TestInitAssetsError(t *testing.T) {
+	assets := &failingAssets{fail: "master"}
+	assets.Add("worker", []byte(serviceAccountManifest))
+	assets.Add("master", []byte(serviceAccountManifest))
+
+	n := NFD{}
+	err := n.Init(nil, nil, assets, Platform{}, &nfdv1.NodeFeatureDiscovery{})
+	assetsErr := &AssetsError{}
+	if !errors.As(err, &assetsErr) {
+		t.Fatalf("expected an AssetsError, got %v", err)
+	}
+
+	// The states read before the failure mustn't be kept, or the next
+	// call to Init would deploy a partial set of assets
+	if n.Loaded() {
+		t.Error("assets loaded after an AssetsError")
+	}
+	if got := n.States(); len(got) != 0 {
+		t.Errorf("states cached after an AssetsError: %v", got)
+	}
+
+	assets.fail = ""
+	if err := n.Init(nil, nil, assets, Platform{}, &nfdv1.NodeFeatureDiscovery{}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if got := n.States(); len(got) != 2 {
+		t.Errorf("unexpected states %v", got)
+	}
+}
+
+func TestInitNoStates(t *testing.T) {
+	n := NFD{}
+	err := n.Init(nil, nil, &MemoryAssets{}, Platform{}, &nfdv1.NodeFeatureDiscovery{})
+	assetsErr := &AssetsError{}
+	if !errors.As(err, &assetsErr) {
+		t.Fatalf("expected an AssetsError, got %v", err)
+	}
+	if n.Loaded() {
+		t.Error("no assets, yet loaded")
+	}
+
+	// Stepping anyway mustn't panic
+	if err := n.Step(); err != nil {
+		t.Errorf("Step failed: %v", err)
+	}
+	if !n.Last() {
+		t.Error("not at the last state")
+	}
+}
+
+func TestStep(t *testing.T) {
+	assets := &MemoryAssets{}
+	assets.Add("worker", []byte(serviceAccountManifest))
+	assets.Add("gc", []byte(serviceAccountManifest))
+	n, c := testNFD(t, assets)
+
+	if got := n.State(); got != "worker" {
+		t.Fatalf("got state %q, want worker", got)
+	}
+	if err := n.Step(); err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	sa := &corev1.ServiceAccount{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "nfd", Name: "nfd-worker"}, sa); err != nil {
+		t.Fatalf("ServiceAccount not applied: %v", err)
+	}
+	if res := n.Components()["worker"]; res == nil || !res.Ready {
+		t.Errorf("worker not reported ready: %+v", res)
+	}
+
+	// The gc is disabled, so its state is skipped and its ServiceAccount,
+	// which has the same name, deleted
+	if err := n.Step(); err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if res, ok := n.Components()["gc"]; !ok || res != nil {
+		t.Errorf("gc not reported disabled: %+v", res)
+	}
+	err := c.Get(context.TODO(), types.NamespacedName{Namespace: "nfd", Name: "nfd-worker"}, sa)
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("ServiceAccount of the disabled state not deleted: %v", err)
+	}
+	if !n.Last() {
+		t.Error("not at the last state")
+	}
+	if got := n.State(); got != "" {
+		t.Errorf("got state %q after the last one", got)
+	}
+	if err := n.Step(); err != nil {
+		t.Errorf("Step after the last state failed: %v", err)
+	}
+}
+
+func TestResumeFrom(t *testing.T) {
+	assets := &MemoryAssets{}
+	assets.Add("worker", []byte(serviceAccountManifest))
+	assets.Add("master", []byte(serviceAccountManifest))
+	n, _ := testNFD(t, assets)
+
+	if n.ResumeFrom("unknown") {
+		t.Error("resumed from an unknown state")
+	}
+	if got := n.State(); got != "worker" {
+		t.Errorf("unknown state skipped states, at %q", got)
+	}
+
+	if !n.ResumeFrom("master") {
+		t.Fatal("couldn't resume from a known state")
+	}
+	if got := n.State(); got != "master" {
+		t.Errorf("got state %q, want master", got)
+	}
+	if err := n.Step(); err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if !n.Last() {
+		t.Error("not at the last state")
+	}
+	if _, ok := n.Components()["worker"]; ok {
+		t.Error("skipped state was applied")
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+const (
+	// certManagerGroup is the API group of the cert-manager resources
+	certManagerGroup = "cert-manager.io"
+
+	// tlsVolume and tlsMountPath are the volume holding the certificate
+	// of an operand and where it's mounted in the container
+	tlsVolume    = "nfd-certs"
+	tlsMountPath = "/etc/kubernetes/node-feature-discovery/certs"
+
+	// tlsCAKey is the key of the CA certificate in the secrets
+	tlsCAKey = "ca.crt"
+
+	// tlsHashAnnotation holds, on the operand pods, the hash of their
+	// certificates, so that the pods are restarted when they're rotated
+	tlsHashAnnotation = "nfd.kubernetes.io/tls-hash"
+
+	// caAsset is the name of the asset Certificate of the self-signed CA
+	caAsset = "nfd-ca"
+)
+
+// certManagerSecrets are the secrets cert-manager issues the certificate
+// of each operand in. nfd-topology-updater connects to nfd-master like
+// nfd-worker does, and shares its certificate.
+var certManagerSecrets = map[string]string{
+	"nfd-master":           "nfd-master-cert",
+	"nfd-worker":           "nfd-worker-cert",
+	"nfd-topology-updater": "nfd-worker-cert",
+}
+
+// tlsSecrets returns the secrets holding the CA certificate and the
+// certificate of the given operand, or false if the operand doesn't use
+// mutual TLS
+func tlsSecrets(ins *nfdv1.NodeFeatureDiscovery, name string) (string, string, bool) {
+	if ins.Spec.TLS.CertManager.Enable {
+		secret, ok := certManagerSecrets[name]
+		secret = InstanceName(ins, secret)
+		return secret, secret, ok
+	}
+
+	refs := ins.Spec.TLS.SecretRefs
+	if refs == nil {
+		return "", "", false
+	}
+	switch name {
+	case "nfd-master":
+		return refs.CA, refs.Master, true
+	case "nfd-worker", "nfd-topology-updater":
+		return refs.CA, refs.Worker, true
+	}
+	return "", "", false
+}
+
+// TLSSecretNames returns the names of the secrets mounted into the operand
+// pods of the NFD instance, in its namespace
+func TLSSecretNames(ins *nfdv1.NodeFeatureDiscovery) []string {
+	names := []string{}
+	for _, name := range []string{"nfd-master", "nfd-worker"} {
+		if ca, cert, ok := tlsSecrets(ins, name); ok {
+			names = append(names, ca, cert)
+		}
+	}
+	return names
+}
+
+// tlsArgs returns the command line flags pointing the given operand at
+// its certificate, key and CA certificate, if it uses mutual TLS
+func tlsArgs(ins *nfdv1.NodeFeatureDiscovery, name string) []string {
+	if _, _, ok := tlsSecrets(ins, name); !ok {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("--ca-file=%s", path.Join(tlsMountPath, tlsCAKey)),
+		fmt.Sprintf("--cert-file=%s", path.Join(tlsMountPath, corev1.TLSCertKey)),
+		fmt.Sprintf("--key-file=%s", path.Join(tlsMountPath, corev1.TLSPrivateKeyKey)),
+	}
+}
+
+// setTLS mounts the CA certificate and the certificate of the given
+// operand into its container, if it uses mutual TLS, and records their
+// hash on the pod template so that the pods pick up rotated certificates
+func setTLS(n NFD, name string, template *corev1.PodTemplateSpec) error {
+	ca, cert, ok := tlsSecrets(n.ins, name)
+	if !ok {
+		return nil
+	}
+
+	hash := sha256.New()
+	for _, ref := range []struct{ secret, key string }{
+		{ca, tlsCAKey},
+		{cert, corev1.TLSCertKey},
+		{cert, corev1.TLSPrivateKeyKey},
+	} {
+		secret := &corev1.Secret{}
+		err := n.client.Get(context.TODO(), types.NamespacedName{Namespace: n.ins.GetNamespace(), Name: ref.secret}, secret)
+		if err != nil {
+			return fmt.Errorf("could not get Secret %q: %w", ref.secret, err)
+		}
+		data, ok := secret.Data[ref.key]
+		if !ok {
+			return fmt.Errorf("key %q not found in Secret %q", ref.key, ref.secret)
+		}
+		hash.Write(data)
+	}
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[tlsHashAnnotation] = fmt.Sprintf("%x", hash.Sum(nil))
+
+	spec := &template.Spec
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: tlsVolume,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{
+					{Secret: &corev1.SecretProjection{
+						LocalObjectReference: corev1.LocalObjectReference{Name: ca},
+						Items:                []corev1.KeyToPath{{Key: tlsCAKey, Path: tlsCAKey}},
+					}},
+					{Secret: &corev1.SecretProjection{
+						LocalObjectReference: corev1.LocalObjectReference{Name: cert},
+						Items: []corev1.KeyToPath{
+							{Key: corev1.TLSCertKey, Path: corev1.TLSCertKey},
+							{Key: corev1.TLSPrivateKeyKey, Path: corev1.TLSPrivateKeyKey},
+						},
+					}},
+				},
+			},
+		},
+	})
+	spec.Containers[0].VolumeMounts = append(spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      tlsVolume,
+		MountPath: tlsMountPath,
+		ReadOnly:  true,
+	})
+	return nil
+}
+
+// setCertManagerResource adapts an Issuer or Certificate of the
+// certmanager state to the NFD instance: the names and references are
+// suffixed with the instance, the nfd-master certificate is made valid for
+// its Service, and the certificates are issued by the issuer given in the
+// CR, if any. It returns false if the object isn't needed, i.e. the
+// self-signed CA when the CR gives an issuer.
+func setCertManagerResource(n NFD, obj *unstructured.Unstructured) (bool, error) {
+	ref := n.ins.Spec.TLS.CertManager.IssuerRef
+	name := obj.GetName()
+
+	switch obj.GetKind() {
+	case "Issuer":
+		if ref != nil {
+			return false, nil
+		}
+		if secret, found, _ := unstructured.NestedString(obj.Object, "spec", "ca", "secretName"); found {
+			if err := unstructured.SetNestedField(obj.Object, InstanceName(n.ins, secret), "spec", "ca", "secretName"); err != nil {
+				return false, err
+			}
+		}
+
+	case "Certificate":
+		if ref != nil && name == caAsset {
+			return false, nil
+		}
+		secret, _, _ := unstructured.NestedString(obj.Object, "spec", "secretName")
+		if err := unstructured.SetNestedField(obj.Object, InstanceName(n.ins, secret), "spec", "secretName"); err != nil {
+			return false, err
+		}
+
+		issuer := map[string]interface{}{}
+		if ref != nil && name != caAsset {
+			kind := ref.Kind
+			if kind == "" {
+				kind = "Issuer"
+			}
+			issuer = map[string]interface{}{"name": ref.Name, "kind": kind, "group": certManagerGroup}
+		} else if current, found, _ := unstructured.NestedMap(obj.Object, "spec", "issuerRef"); found {
+			issuer = current
+			issuer["name"] = InstanceName(n.ins, fmt.Sprint(current["name"]))
+		}
+		if err := unstructured.SetNestedMap(obj.Object, issuer, "spec", "issuerRef"); err != nil {
+			return false, err
+		}
+
+		// The workers connect to nfd-master through its Service
+		if name == "nfd-master" {
+			svc := InstanceName(n.ins, "nfd-master")
+			ns := n.ins.GetNamespace()
+			dnsNames := []interface{}{
+				svc,
+				fmt.Sprintf("%s.%s.svc", svc, ns),
+				fmt.Sprintf("%s.%s.svc.cluster.local", svc, ns),
+			}
+			if err := unstructured.SetNestedSlice(obj.Object, dnsNames, "spec", "dnsNames"); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	obj.SetName(InstanceName(n.ins, name))
+	return true, nil
+}
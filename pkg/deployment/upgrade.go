@@ -0,0 +1,274 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	nfdv1 "github.com/kubernetes-sigs/node-feature-discovery-operator/api/v1"
+)
+
+// Reasons of the Progressing condition during an operand upgrade. An
+// upgrade rolls nfd-master first, then checks that the existing workers
+// still work with it, and only then rolls the workers.
+const (
+	reasonUpgradingMaster  = "UpgradingMaster"
+	reasonUpgradingWorkers = "UpgradingWorkers"
+	reasonUpgradeFailed    = "UpgradeFailed"
+	reasonUpgradeCompleted = "UpgradeCompleted"
+)
+
+// FailingWaitReasons are the container waiting reasons of pods that won't
+// recover by themselves
+var FailingWaitReasons = []string{"CrashLoopBackOff", "ImagePullBackOff", "ErrImagePull"}
+
+// workerUpgradeGate is called before updating an existing operand
+// DaemonSet. When the image of the workers changes, it only lets the
+// update through once the existing workers are known to work with the
+// upgraded nfd-master.
+func workerUpgradeGate(n NFD, name string, found, obj *appsv1.DaemonSet) (ResourceStatus, error) {
+	if name != "nfd-worker" || daemonSetImage(found) == daemonSetImage(obj) {
+		return Ready, nil
+	}
+
+	// The master was upgraded in an earlier state; old workers failing
+	// now means they can't talk to it
+	failing, err := failingPods(n, name, daemonSetImage(found))
+	if err != nil {
+		return NotReady, err
+	}
+	if len(failing) > 0 {
+		msg := fmt.Sprintf("workers failing with the upgraded nfd-master, not upgrading them: %s", strings.Join(failing, ","))
+		if err := setProgressing(n, corev1.ConditionFalse, reasonUpgradeFailed, msg); err != nil {
+			return NotReady, err
+		}
+		return NotReady, fmt.Errorf("%s", msg)
+	}
+
+	return Ready, setProgressing(n, corev1.ConditionTrue, reasonUpgradingWorkers,
+		fmt.Sprintf("rolling out %s", daemonSetImage(obj)))
+}
+
+// upgradeProgress is called after updating an existing operand DaemonSet
+// and holds back the next resources until its upgrade is rolled out
+func upgradeProgress(n NFD, name string, obj *appsv1.DaemonSet) (ResourceStatus, error) {
+	switch name {
+	case "nfd-master":
+		return masterUpgradeProgress(n, daemonSetImage(obj), rolloutComplete(obj),
+			notReady("DaemonSet", obj.Name, "%s", daemonSetProgress(obj)))
+
+	case "nfd-worker":
+		cond := conditionsv1.FindStatusCondition(n.ins.Status.Conditions, conditionsv1.ConditionProgressing)
+		if cond == nil || (cond.Reason != reasonUpgradingWorkers && cond.Reason != reasonUpgradeFailed) {
+			return Ready, nil
+		}
+		if rolloutComplete(obj) {
+			return Ready, setProgressing(n, corev1.ConditionFalse, reasonUpgradeCompleted,
+				fmt.Sprintf("upgraded to %s", daemonSetImage(obj)))
+		}
+
+		// The pods are only updated once deleted by the user, there's
+		// no point in holding the next states back until then
+		if obj.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType {
+			return Ready, nil
+		}
+
+		// The DaemonSet controller stops rolling out once maxUnavailable
+		// pods are failing, report them
+		failing, err := failingPods(n, name, daemonSetImage(obj))
+		if err != nil {
+			return NotReady, err
+		}
+		if len(failing) > 0 {
+			msg := fmt.Sprintf("upgraded workers failing, rollout stalled: %s", strings.Join(failing, ","))
+			if err := setProgressing(n, corev1.ConditionFalse, reasonUpgradeFailed, msg); err != nil {
+				return NotReady, err
+			}
+			return NotReady, notReady("DaemonSet", obj.Name, "%s, %s", daemonSetProgress(obj), msg)
+		}
+		return NotReady, notReady("DaemonSet", obj.Name, "%s", daemonSetProgress(obj))
+	}
+
+	return Ready, nil
+}
+
+// masterUpgradeProgress holds back the workers until the upgraded
+// nfd-master, running the given image, is rolled out. waiting tells how
+// far the rollout is.
+func masterUpgradeProgress(n NFD, image string, complete bool, waiting error) (ResourceStatus, error) {
+	upgrading, err := workerOutdated(n)
+	if err != nil || !upgrading {
+		return Ready, err
+	}
+	if complete {
+		return Ready, nil
+	}
+	if err := setProgressing(n, corev1.ConditionTrue, reasonUpgradingMaster,
+		fmt.Sprintf("rolling out %s", image)); err != nil {
+		return NotReady, err
+	}
+	return NotReady, waiting
+}
+
+// workerOutdated returns true if the worker DaemonSet exists and runs
+// another image than the one requested in the CR
+func workerOutdated(n NFD) (bool, error) {
+	ds := &appsv1.DaemonSet{}
+	err := n.client.Get(context.TODO(), types.NamespacedName{
+		Namespace: n.ins.GetNamespace(), Name: InstanceName(n.ins, "nfd-worker")}, ds)
+	if errors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return daemonSetImage(ds) != n.ins.Spec.Operand.ImagePath(), nil
+}
+
+// rolloutComplete returns true once all the pods of the DaemonSet run the
+// current pod template and are available
+func rolloutComplete(ds *appsv1.DaemonSet) bool {
+	return ds.Status.ObservedGeneration >= ds.Generation &&
+		ds.Status.UpdatedNumberScheduled == ds.Status.DesiredNumberScheduled &&
+		ds.Status.NumberAvailable == ds.Status.DesiredNumberScheduled
+}
+
+// deploymentRolloutComplete returns true once all the replicas of the
+// Deployment run the current pod template and are available
+func deploymentRolloutComplete(d *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	return d.Status.ObservedGeneration >= d.Generation &&
+		d.Status.UpdatedReplicas == replicas &&
+		d.Status.AvailableReplicas == replicas &&
+		d.Status.Replicas == replicas
+}
+
+// daemonSetProgress describes the rollout of a DaemonSet, e.g. "3/120 pods
+// available, 5/120 updated"
+func daemonSetProgress(ds *appsv1.DaemonSet) string {
+	if ds.Status.ObservedGeneration < ds.Generation {
+		return "waiting for the DaemonSet controller to observe the update"
+	}
+	return fmt.Sprintf("%d/%d pods available, %d/%d updated",
+		ds.Status.NumberAvailable, ds.Status.DesiredNumberScheduled,
+		ds.Status.UpdatedNumberScheduled, ds.Status.DesiredNumberScheduled)
+}
+
+// deploymentProgress describes the rollout of a Deployment, e.g. "0/1
+// replicas available, 1/1 updated"
+func deploymentProgress(d *appsv1.Deployment) string {
+	if d.Status.ObservedGeneration < d.Generation {
+		return "waiting for the Deployment controller to observe the update"
+	}
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	return fmt.Sprintf("%d/%d replicas available, %d/%d updated",
+		d.Status.AvailableReplicas, replicas, d.Status.UpdatedReplicas, replicas)
+}
+
+// daemonSetImage returns the image of the operand container
+func daemonSetImage(ds *appsv1.DaemonSet) string {
+	return templateImage(&ds.Spec.Template)
+}
+
+// templateImage returns the image of the operand container of a pod
+// template
+func templateImage(template *corev1.PodTemplateSpec) string {
+	if len(template.Spec.Containers) == 0 {
+		return ""
+	}
+	return template.Spec.Containers[0].Image
+}
+
+// failingPods returns the names of the operand pods running the given
+// image whose containers are stuck failing
+func failingPods(n NFD, app, image string) ([]string, error) {
+	pods := &corev1.PodList{}
+	if err := n.client.List(context.TODO(), pods, client.InNamespace(n.ins.GetNamespace()), PodSelector(n.ins, app)); err != nil {
+		return nil, err
+	}
+
+	failing := []string{}
+	for _, pod := range pods.Items {
+		if len(pod.Spec.Containers) == 0 || pod.Spec.Containers[0].Image != image {
+			continue
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.State.Waiting != nil && containsString(FailingWaitReasons, cs.State.Waiting.Reason) {
+				failing = append(failing, pod.Name)
+				break
+			}
+		}
+	}
+	return failing, nil
+}
+
+// containsString returns true if s is in list
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// UpgradeInProgress returns true if the Progressing condition of the CR
+// tracks an operand upgrade that isn't completed, in which case it must be
+// left to the upgrade orchestration
+func UpgradeInProgress(ins *nfdv1.NodeFeatureDiscovery) bool {
+	cond := conditionsv1.FindStatusCondition(ins.Status.Conditions, conditionsv1.ConditionProgressing)
+	if cond == nil {
+		return false
+	}
+	switch cond.Reason {
+	case reasonUpgradingMaster, reasonUpgradingWorkers, reasonUpgradeFailed:
+		return true
+	}
+	return false
+}
+
+// setProgressing updates the Progressing condition of the CR, unless it
+// already has the given status, reason and message
+func setProgressing(n NFD, status corev1.ConditionStatus, reason, message string) error {
+	cond := conditionsv1.FindStatusCondition(n.ins.Status.Conditions, conditionsv1.ConditionProgressing)
+	if cond != nil && cond.Status == status && cond.Reason == reason && cond.Message == message {
+		return nil
+	}
+
+	log.Info("Progressing", "status", status, "reason", reason, "message", message)
+	conditionsv1.SetStatusCondition(&n.ins.Status.Conditions, conditionsv1.Condition{
+		Type:    conditionsv1.ConditionProgressing,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+	return n.client.Status().Update(context.TODO(), n.ins)
+}